@@ -22,7 +22,7 @@ type AuthHandler struct {
 	clientSecret string
 	redirectURL  string
 	jwtManager   *auth.JWTManager
-	store        *store.Store
+	store        store.UserRepository
 	logger       *slog.Logger
 
 	// CSRF state管理
@@ -34,7 +34,7 @@ type AuthHandler struct {
 func NewAuthHandler(
 	clientID, clientSecret, redirectURL string,
 	jwtManager *auth.JWTManager,
-	st *store.Store,
+	st store.UserRepository,
 	logger *slog.Logger,
 ) *AuthHandler {
 	if logger == nil {
@@ -11,12 +11,12 @@ import (
 
 // CommunityHandler handles community interaction endpoints
 type CommunityHandler struct {
-	store  *store.Store
+	store  store.LikeRepository
 	logger *slog.Logger
 }
 
 // NewCommunityHandler creates a new community handler
-func NewCommunityHandler(store *store.Store, logger *slog.Logger) *CommunityHandler {
+func NewCommunityHandler(store store.LikeRepository, logger *slog.Logger) *CommunityHandler {
 	if logger == nil {
 		logger = slog.Default()
 	}
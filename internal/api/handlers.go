@@ -1,29 +1,48 @@
 package api
 
 import (
+	"archive/zip"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image/png"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/asc-lab/track-designer/internal/core"
+	"github.com/asc-lab/track-designer/internal/middleware"
 	"github.com/asc-lab/track-designer/internal/store"
 	"github.com/go-chi/chi/v5"
 )
 
 type Handler struct {
-	store       *store.Store
-	maxUploadMB int64
+	store           store.Repository
+	maxUploadMB     int64
+	maxRasterPixels int // 0: core.DefaultMaxRasterPixels
 }
 
-func NewHandler(store *store.Store, maxUploadMB int64) *Handler {
+func NewHandler(store store.Repository, maxUploadMB int64, maxRasterPixels int) *Handler {
 	return &Handler{
-		store:       store,
-		maxUploadMB: maxUploadMB,
+		store:           store,
+		maxUploadMB:     maxUploadMB,
+		maxRasterPixels: maxRasterPixels,
 	}
 }
 
+// importTrack reads a track sent by the editor and lays its pieces out
+// with the store's catalogue
+func (h *Handler) importTrack(data []byte) (*core.TrackProject, error) {
+	project, err := core.ImportLegacyJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	project.UseCatalog(h.store.Lab().Catalog)
+	return project, nil
+}
+
 type Response struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
@@ -46,8 +65,11 @@ type UploadRequest struct {
 	Tags           []string        `json:"tags"`
 	UploaderName   string          `json:"uploaderName"`
 	UploaderAvatar string          `json:"uploaderAvatar"`
-	Thumbnail      string          `json:"thumbnail"`
 	Project        json.RawMessage `json:"project"`
+
+	// RejectOverlaps refuses tracks whose swept width overlaps itself
+	// instead of saving them with a warning
+	RejectOverlaps bool `json:"rejectOverlaps"`
 }
 
 func (h *Handler) UploadTrack(w http.ResponseWriter, r *http.Request) {
@@ -64,7 +86,7 @@ func (h *Handler) UploadTrack(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Try to import
-	project, err := core.ImportLegacyJSON(req.Project)
+	project, err := h.importTrack(req.Project)
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, Response{
 			Success: false,
@@ -73,6 +95,26 @@ func (h *Handler) UploadTrack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Check the layout doesn't overlap itself on the floor. Tracks with
+	// unknown piece types can't be swept and are saved unchecked; tracks
+	// too long to sweep are refused.
+	collisions, sweepErr := core.DetectCollisions(project)
+	if errors.Is(sweepErr, core.ErrTrackTooLarge) || errors.Is(sweepErr, core.ErrInvalidPiece) {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid track data: %v", sweepErr),
+		})
+		return
+	}
+	if sweepErr == nil && !collisions.Clear && req.RejectOverlaps {
+		writeJSON(w, http.StatusUnprocessableEntity, Response{
+			Success: false,
+			Data:    collisions,
+			Error:   "Track overlaps itself",
+		})
+		return
+	}
+
 	// Set metadata from request
 	project.ID = GenerateID()
 	project.CreatedAt = time.Now()
@@ -98,9 +140,16 @@ func (h *Handler) UploadTrack(w http.ResponseWriter, r *http.Request) {
 	if req.UploaderAvatar != "" {
 		project.UploaderAvatar = req.UploaderAvatar
 	}
+	// Only a signed-in uploader can update the track later; an ID sent
+	// in the track file counts for nothing
+	project.UploaderID = ""
+	if claims := middleware.GetUserFromContext(r.Context()); claims != nil {
+		project.UploaderID = claims.UserID
+	}
 
-	// Save (with thumbnail)
-	if err := h.store.SaveTrack(project, req.Thumbnail); err != nil {
+	// Save (the thumbnail is rendered from the geometry)
+	rev, err := h.store.SaveTrack(project, revisionInfo(r, project, "Initial upload"))
+	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, Response{
 			Success: false,
 			Error:   "Failed to save track",
@@ -108,16 +157,102 @@ func (h *Handler) UploadTrack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	data := map[string]interface{}{
+		"id":        project.ID,
+		"name":      project.Name,
+		"createdAt": project.CreatedAt,
+		"revision":  rev.Revision,
+	}
+	if sweepErr == nil && !collisions.Clear {
+		data["collisions"] = collisions.Collisions
+	}
+
 	writeJSON(w, http.StatusCreated, Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
+type ValidateRequest struct {
+	Project        json.RawMessage `json:"project"`
+	Tolerance      *core.Tolerance `json:"tolerance,omitempty"`
+	MaxPieces      int             `json:"maxPieces,omitempty"`
+	MaxSuggestions int             `json:"maxSuggestions,omitempty"`
+}
+
+// ValidateTrack handles POST /api/tracks/validate: checks that pieces connect
+// and form a closed loop, and suggests catalogue pieces to close it if not
+func (h *Handler) ValidateTrack(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadMB*1024*1024)
+
+	var req ValidateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "Invalid request format",
+		})
+		return
+	}
+
+	project, err := h.importTrack(req.Project)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid track data: %v", err),
+		})
+		return
+	}
+
+	opts := core.DefaultClosureOptions
+	if req.Tolerance != nil {
+		opts.Tolerance = *req.Tolerance
+	}
+	if req.MaxPieces > 0 {
+		opts.MaxPieces = req.MaxPieces
+	}
+	if req.MaxSuggestions > 0 {
+		opts.MaxSuggestions = req.MaxSuggestions
+	}
+
+	chain, err := core.SolvePoses(project, opts.Tolerance)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid track data: %v", err),
+		})
+		return
+	}
+
+	closure, err := core.ValidateClosure(project, opts)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid track data: %v", err),
+		})
+		return
+	}
+
+	connections, err := core.CheckConnections(project, opts.Tolerance)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid track data: %v", err),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
 		Success: true,
 		Data: map[string]interface{}{
-			"id":        project.ID,
-			"name":      project.Name,
-			"createdAt": project.CreatedAt,
+			"chain":       chain,
+			"closure":     closure,
+			"connections": connections,
 		},
 	})
 }
 
+// ListTracks handles GET /api/tracks: q searches names, descriptions, tags
+// and uploaders, ranked by relevance with a highlighted snippet per track
 func (h *Handler) ListTracks(w http.ResponseWriter, r *http.Request) {
 	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
 	if page < 1 {
@@ -146,13 +281,35 @@ func (h *Handler) ListTracks(w http.ResponseWriter, r *http.Request) {
 	minLength, _ := strconv.Atoi(r.URL.Query().Get("minLength"))
 	maxLength, _ := strconv.Atoi(r.URL.Query().Get("maxLength"))
 
+	// Parse difficulty filters (score 0..100) and sort order
+	minDifficulty, _ := strconv.ParseFloat(r.URL.Query().Get("minDifficulty"), 64)
+	maxDifficulty, _ := strconv.ParseFloat(r.URL.Query().Get("maxDifficulty"), 64)
+	sort := r.URL.Query().Get("sort")
+
+	// Only tracks the signed-in user's inventory covers
+	buildableFor := ""
+	if r.URL.Query().Get("buildable") == "true" {
+		if buildableFor = currentUserID(w, r); buildableFor == "" {
+			return
+		}
+	}
+
 	// Use filtered query if filters are present
 	var tracks []core.TrackMetadata
 	var total int
 	var err error
 
-	if len(tags) > 0 || minLength > 0 || maxLength > 0 {
-		tracks, total, err = h.store.ListTracksWithFilters(page, size, query, tags, minLength, maxLength)
+	if len(tags) > 0 || minLength > 0 || maxLength > 0 || minDifficulty > 0 || maxDifficulty > 0 || sort != "" || buildableFor != "" {
+		tracks, total, err = h.store.ListTracksWithFilters(page, size, store.TrackFilters{
+			Query:         query,
+			Tags:          tags,
+			MinLength:     minLength,
+			MaxLength:     maxLength,
+			MinDifficulty: minDifficulty,
+			MaxDifficulty: maxDifficulty,
+			BuildableFor:  buildableFor,
+			Sort:          sort,
+		})
 	} else {
 		tracks, total, err = h.store.ListTracks(page, size, query)
 	}
@@ -165,6 +322,10 @@ func (h *Handler) ListTracks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	for i := range tracks {
+		tracks[i].Thumbnail = thumbnailURL(tracks[i].ID)
+	}
+
 	writeJSON(w, http.StatusOK, Response{
 		Success: true,
 		Data: map[string]interface{}{
@@ -189,14 +350,33 @@ func (h *Handler) GetTrack(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Add BOM
-	bom := core.GenerateBOM(project)
+	bom := h.store.Lab().GenerateBOM(project)
+
+	data := map[string]interface{}{
+		"project": project,
+		"bom":     bom,
+	}
+
+	// Add rule check (?rules=<profile>, default profile otherwise)
+	profileName := r.URL.Query().Get("rules")
+	if profileName == "" {
+		profileName = core.DefaultRuleProfile
+	}
+	profile, ok := h.store.Lab().RuleProfile(profileName)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Unknown rule profile: %s", profileName),
+		})
+		return
+	}
+	if rules, err := core.EvaluateRules(project, profile); err == nil {
+		data["rules"] = rules
+	}
 
 	writeJSON(w, http.StatusOK, Response{
 		Success: true,
-		Data: map[string]interface{}{
-			"project": project,
-			"bom":     bom,
-		},
+		Data:    data,
 	})
 }
 
@@ -223,6 +403,456 @@ func (h *Handler) DownloadTrack(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(project)
 }
 
+// Thumbnail handles GET /api/tracks/{id}/thumbnail.png. Clients revalidate
+// with the ETag, so a re-saved track shows its new thumbnail straight away.
+func (h *Handler) Thumbnail(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	data, info, err := h.store.Thumbnail(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "Track not found",
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=0, must-revalidate")
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime.UnixNano(), info.Size))
+	http.ServeContent(w, r, "", info.ModTime, bytes.NewReader(data))
+}
+
+func thumbnailURL(id string) string {
+	return "/api/tracks/" + id + "/thumbnail.png"
+}
+
+// ExportSVG handles GET /api/tracks/{id}/export.svg: a true-scale drawing
+// in centimetres (?grid=1&labels=1&legend=1 for extras)
+func (h *Handler) ExportSVG(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	project, err := h.store.GetTrack(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "Track not found",
+		})
+		return
+	}
+
+	opts := core.SVGOptions{
+		Grid:   queryBool(r, "grid"),
+		Labels: queryBool(r, "labels"),
+		Legend: queryBool(r, "legend"),
+	}
+	opts.GridCm, _ = strconv.ParseFloat(r.URL.Query().Get("gridCm"), 64)
+
+	var buf bytes.Buffer
+	if err := core.RenderSVG(&buf, project, opts); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to render track: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s.svg\"", project.ID))
+	w.Write(buf.Bytes())
+}
+
+// ExportPDF handles GET /api/tracks/{id}/export.pdf: a 1:1 template tiled
+// over A4 or A3 sheets (?paper=A3), with an overview page and BOM
+func (h *Handler) ExportPDF(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	project, err := h.store.GetTrack(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "Track not found",
+		})
+		return
+	}
+
+	opts := core.PDFOptions{Paper: r.URL.Query().Get("paper")}
+	if opts.Paper != "" {
+		if _, ok := core.LookupPaper(opts.Paper); !ok {
+			writeJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   fmt.Sprintf("Unsupported paper size: %s", opts.Paper),
+			})
+			return
+		}
+	}
+
+	// Large templates run to hundreds of pages, so they're streamed.
+	// RenderPDF checks the track before writing anything; once the first
+	// page has gone out, an error can only cut the download short.
+	out := &downloadWriter{w: w, contentType: "application/pdf", filename: project.ID + ".pdf"}
+	if err := core.RenderPDF(out, project, opts); err != nil && !out.started {
+		writeJSON(w, http.StatusUnprocessableEntity, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to render track: %v", err),
+		})
+	}
+}
+
+// downloadWriter sets the download headers when the first byte is written,
+// so a handler streaming a file can still answer with JSON if it fails
+// before that
+type downloadWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (d *downloadWriter) Write(p []byte) (int, error) {
+	if !d.started {
+		d.started = true
+		d.w.Header().Set("Content-Type", d.contentType)
+		d.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", d.filename))
+	}
+	return d.w.Write(p)
+}
+
+// ExportBOM handles GET /api/tracks/{id}/export.bom.csv and
+// /api/tracks/{id}/export.bom.xlsx: the BOM for a purchase order, priced
+// if a price list is set up
+func (h *Handler) ExportBOM(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	project, err := h.store.GetTrack(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "Track not found",
+		})
+		return
+	}
+
+	bom := h.store.Lab().GenerateBOM(project)
+	format := chi.URLParam(r, "format")
+	var buf bytes.Buffer
+	switch format {
+	case "csv":
+		err = core.WriteBOMCSV(&buf, project.Name, bom)
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	case "xlsx":
+		err = core.WriteBOMXLSX(&buf, project.Name, bom)
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	default:
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Unsupported BOM format: %s", format),
+		})
+		return
+	}
+	if err != nil {
+		w.Header().Del("Content-Type")
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to write BOM",
+		})
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-bom.%s\"", project.ID, format))
+	w.Write(buf.Bytes())
+}
+
+// ExportROSMap handles GET /api/tracks/{id}/export.ros.zip: an occupancy
+// grid PGM and map_server YAML (?resolution= metres per cell, default 0.01)
+func (h *Handler) ExportROSMap(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	project, err := h.store.GetTrack(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "Track not found",
+		})
+		return
+	}
+
+	opts := core.ROSMapOptions{MaxPixels: h.maxRasterPixels}
+	opts.ResolutionM, _ = strconv.ParseFloat(r.URL.Query().Get("resolution"), 64)
+
+	m, err := core.RenderROSMap(project, opts)
+	if errors.Is(err, core.ErrInvalidOption) {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to render track: %v", err),
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	pgm, _ := zw.Create(project.ID + ".pgm")
+	m.WritePGM(pgm)
+	yaml, _ := zw.Create(project.ID + ".yaml")
+	m.WriteYAML(yaml, project.ID+".pgm")
+	if err := zw.Close(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to write map",
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-ros.zip\"", project.ID))
+	w.Write(buf.Bytes())
+}
+
+// ExportWorld handles GET /api/tracks/{id}/export.world.zip: a Gazebo SDF
+// world and a Webots world sharing one ground texture (?pxPerCm=, default 2)
+func (h *Handler) ExportWorld(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	project, err := h.store.GetTrack(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "Track not found",
+		})
+		return
+	}
+
+	opts := core.WorldOptions{MaxPixels: h.maxRasterPixels}
+	opts.PxPerCm, _ = strconv.ParseFloat(r.URL.Query().Get("pxPerCm"), 64)
+
+	world, err := core.BuildWorld(project, opts)
+	if errors.Is(err, core.ErrInvalidOption) {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to render track: %v", err),
+		})
+		return
+	}
+
+	// Everything sits in one folder so the worlds find the texture
+	texture := project.ID + ".png"
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create(project.ID + "/" + texture)
+	world.WriteTexture(f)
+	f, _ = zw.Create(project.ID + "/" + project.ID + ".sdf")
+	world.WriteSDF(f, texture)
+	f, _ = zw.Create(project.ID + "/" + project.ID + ".wbt")
+	world.WriteWebots(f, texture)
+	if err := zw.Close(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to write world",
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-world.zip\"", project.ID))
+	w.Write(buf.Bytes())
+}
+
+// Texture handles GET /api/tracks/{id}/texture.png: the track as it looks
+// on the floor, for vision training. Query: pxPerCm, track, floor, edge
+// (#RRGGBB), edgeCm, pattern (plain|carpet|tiles|wood), noise and lighting
+// (0..1) and seed.
+func (h *Handler) Texture(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	project, err := h.store.GetTrack(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "Track not found",
+		})
+		return
+	}
+
+	q := r.URL.Query()
+	opts := core.TextureOptions{
+		TrackColor:   q.Get("track"),
+		FloorColor:   q.Get("floor"),
+		EdgeColor:    q.Get("edge"),
+		FloorPattern: q.Get("pattern"),
+		MaxPixels:    h.maxRasterPixels,
+	}
+	opts.PxPerCm, _ = strconv.ParseFloat(q.Get("pxPerCm"), 64)
+	opts.EdgeLineCm, _ = strconv.ParseFloat(q.Get("edgeCm"), 64)
+	opts.Noise, _ = strconv.ParseFloat(q.Get("noise"), 64)
+	opts.Lighting, _ = strconv.ParseFloat(q.Get("lighting"), 64)
+	opts.Seed, _ = strconv.ParseInt(q.Get("seed"), 10, 64)
+
+	tex, err := core.RenderTexture(project, opts)
+	if errors.Is(err, core.ErrInvalidOption) {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to render texture: %v", err),
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, tex.Image); err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to encode texture",
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Px-Per-Cm", strconv.FormatFloat(tex.PxPerCm, 'f', -1, 64))
+	w.Header().Set("X-Origin-Cm", fmt.Sprintf("%g,%g", tex.MinX, tex.MaxY))
+	w.Write(buf.Bytes())
+}
+
+// Profile handles GET /api/tracks/{id}/profile: the centreline sampled
+// every ?step= cm (default 1) with heading and signed curvature, as JSON
+// or, with ?format=csv, as a CSV download
+func (h *Handler) Profile(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	project, err := h.store.GetTrack(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "Track not found",
+		})
+		return
+	}
+
+	step := 1.0
+	if v := r.URL.Query().Get("step"); v != "" {
+		if step, err = strconv.ParseFloat(v, 64); err != nil {
+			writeJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   "Invalid step",
+			})
+			return
+		}
+	}
+
+	profile, err := core.SampleCentreline(project, step)
+	if errors.Is(err, core.ErrInvalidOption) {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "Invalid step",
+		})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to sample track: %v", err),
+		})
+		return
+	}
+
+	if r.URL.Query().Get("format") != "csv" {
+		writeJSON(w, http.StatusOK, Response{
+			Success: true,
+			Data:    profile,
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := profile.WriteCSV(&buf); err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to write profile",
+		})
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-profile.csv\"", project.ID))
+	w.Write(buf.Bytes())
+}
+
+// ExportDXF handles GET /api/tracks/{id}/export.dxf: centreline and edges
+// in centimetres, one layer per piece type
+func (h *Handler) ExportDXF(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	project, err := h.store.GetTrack(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "Track not found",
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := core.RenderDXF(&buf, project); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to render track: %v", err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/dxf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.dxf\"", project.ID))
+	w.Write(buf.Bytes())
+}
+
+// ImportDXF handles POST /api/tracks/import.dxf: converts a DXF drawing
+// (raw body) into a track project. Nothing is saved; the client reviews the
+// result and uploads it as usual.
+func (h *Handler) ImportDXF(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadMB*1024*1024)
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "Failed to read file",
+		})
+		return
+	}
+
+	project, err := core.ImportDXF(data, h.store.Lab().Catalog)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid DXF: %v", err),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    project,
+	})
+}
+
 func (h *Handler) DeleteTrack(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
@@ -283,6 +913,24 @@ func (h *Handler) GetTags(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetCatalog handles GET /api/catalog: the element types and standard parts
+// shared by the editor, BOM and validators
+func (h *Handler) GetCatalog(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    h.store.Lab().Catalog,
+	})
+}
+
+// ListRuleProfiles handles GET /api/rules: the competition rule profiles
+// tracks can be checked against
+func (h *Handler) ListRuleProfiles(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    h.store.Lab().RuleProfiles(),
+	})
+}
+
 // Helper functions
 func splitString(s, sep string) []string {
 	if s == "" {
@@ -306,6 +954,11 @@ func splitString(s, sep string) []string {
 	return result
 }
 
+func queryBool(r *http.Request, key string) bool {
+	v, _ := strconv.ParseBool(r.URL.Query().Get(key))
+	return v
+}
+
 func trimString(s string) string {
 	start := 0
 	end := len(s)
@@ -0,0 +1,278 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asc-lab/track-designer/internal/auth"
+	"github.com/asc-lab/track-designer/internal/core"
+	"github.com/asc-lab/track-designer/internal/middleware"
+	"github.com/asc-lab/track-designer/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// newTestHandler serves a store in a temporary data directory holding
+// projects, saved oldest first a minute apart
+func newTestHandler(t *testing.T, projects ...*core.TrackProject) (*Handler, *store.Store) {
+	t.Helper()
+	s, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	start := time.Now().Add(-time.Hour)
+	for i, p := range projects {
+		p.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		if _, err := s.SaveTrack(p, store.RevisionInfo{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewHandler(s, 10, 0), s
+}
+
+// listTrackIDs calls ListTracks and returns the status and the IDs listed
+func listTrackIDs(t *testing.T, h *Handler, r *http.Request) (int, []string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ListTracks(w, r)
+
+	var resp struct {
+		Data struct {
+			Items []core.TrackMetadata `json:"items"`
+			Total int                  `json:"total"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, track := range resp.Data.Items {
+		ids = append(ids, track.ID)
+	}
+	if w.Code == http.StatusOK && resp.Data.Total != len(ids) {
+		t.Errorf("%s: total %d for %d tracks", r.URL, resp.Data.Total, len(ids))
+	}
+	return w.Code, ids
+}
+
+// track is a track of the given pieces
+func track(id string, pieces ...core.Piece) *core.TrackProject {
+	for i := range pieces {
+		pieces[i].ID = i + 1
+	}
+	return &core.TrackProject{ID: id, Name: id, Pieces: pieces}
+}
+
+func straight(length float64) core.Piece {
+	return core.Piece{Type: "straight", Params: core.PieceParams{Length: length}}
+}
+
+func curve(radius float64) core.Piece {
+	return core.Piece{Type: "curve", Params: core.PieceParams{Radius: radius, Angle: 90, Direction: "left"}}
+}
+
+func TestListTracks_Difficulty(t *testing.T) {
+	h, _ := newTestHandler(t,
+		track("tight", curve(30), curve(30), curve(30), curve(30)),    // 55
+		track("flat", straight(200)),                                  // 0
+		track("wide", curve(100), curve(100), curve(100), curve(100)), // 27.8
+	)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"wide", "flat", "tight"}},
+		{"sort=difficulty", []string{"flat", "wide", "tight"}},
+		{"sort=-difficulty", []string{"tight", "wide", "flat"}},
+		{"sort=likes", []string{"wide", "flat", "tight"}}, // unknown: newest
+		{"minDifficulty=20", []string{"wide", "tight"}},
+		{"maxDifficulty=50&sort=difficulty", []string{"flat", "wide"}},
+		{"minDifficulty=20&maxDifficulty=50", []string{"wide"}},
+		{"minDifficulty=hard", []string{"wide", "flat", "tight"}}, // not a number: no filter
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/tracks?"+tt.query, nil)
+		code, got := listTrackIDs(t, h, r)
+		if code != http.StatusOK || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("?%s: %d %v, want %v", tt.query, code, got, tt.want)
+		}
+	}
+}
+
+func TestListTracks_Buildable(t *testing.T) {
+	h, s := newTestHandler(t,
+		track("short", straight(50)),
+		track("long", straight(50), straight(50), straight(50)),
+	)
+	signedIn := func(query, userID string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api/tracks?"+query, nil)
+		claims := &auth.TokenClaims{UserID: userID}
+		return r.WithContext(context.WithValue(r.Context(), middleware.UserContextKey, claims))
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/tracks?buildable=true", nil)
+	if code, _ := listTrackIDs(t, h, r); code != http.StatusUnauthorized {
+		t.Errorf("buildable signed out: %d, want 401", code)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"buildable=true", []string{}}, // nothing owned yet
+		{"buildable=false", []string{"long", "short"}},
+		{"", []string{"long", "short"}},
+	}
+	for _, tt := range tests {
+		if code, got := listTrackIDs(t, h, signedIn(tt.query, "u1")); code != http.StatusOK || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("?%s: %d %v, want %v", tt.query, code, got, tt.want)
+		}
+	}
+
+	if err := s.SetInventory("u1", core.Inventory{"L50": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if code, got := listTrackIDs(t, h, signedIn("buildable=true", "u1")); code != http.StatusOK || !reflect.DeepEqual(got, []string{"short"}) {
+		t.Errorf("buildable with 2×L50: %d %v", code, got)
+	}
+	if code, got := listTrackIDs(t, h, signedIn("buildable=true", "u2")); code != http.StatusOK || len(got) != 0 {
+		t.Errorf("buildable for another user: %d %v", code, got)
+	}
+}
+
+func TestTexture_Errors(t *testing.T) {
+	h, _ := newTestHandler(t,
+		track("ok", straight(50)),
+		&core.TrackProject{ID: "odd", Name: "odd", Pieces: []core.Piece{
+			{ID: 1, Type: "hovercraft-ramp", Params: core.PieceParams{Length: 50}},
+		}},
+	)
+	router := chi.NewRouter()
+	router.Get("/api/tracks/{id}/texture.png", h.Texture)
+
+	tests := []struct {
+		url  string
+		want int
+	}{
+		{"/api/tracks/ok/texture.png?pxPerCm=1", http.StatusOK},
+		{"/api/tracks/ok/texture.png?pxPerCm=NaN", http.StatusBadRequest},
+		{"/api/tracks/ok/texture.png?pattern=marble", http.StatusBadRequest},
+		{"/api/tracks/odd/texture.png", http.StatusUnprocessableEntity},
+		{"/api/tracks/missing/texture.png", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+		if w.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d: %s", tt.url, w.Code, tt.want, w.Body)
+		}
+	}
+}
+
+func TestRasterExports_PixelLimit(t *testing.T) {
+	h, _ := newTestHandler(t, track("ok", straight(50)))
+	h.maxRasterPixels = 1000
+	router := chi.NewRouter()
+	router.Get("/api/tracks/{id}/texture.png", h.Texture)
+	router.Get("/api/tracks/{id}/export.ros.zip", h.ExportROSMap)
+	router.Get("/api/tracks/{id}/export.world.zip", h.ExportWorld)
+
+	for _, url := range []string{
+		"/api/tracks/ok/texture.png",
+		"/api/tracks/ok/export.ros.zip",
+		"/api/tracks/ok/export.world.zip",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s over the pixel limit = %d, want 400: %s", url, w.Code, w.Body)
+		}
+	}
+}
+
+func TestUpdateAndRollback_Uploader(t *testing.T) {
+	mine := track("mine", straight(50))
+	mine.UploaderID = "u1"
+	h, s := newTestHandler(t, mine)
+	router := chi.NewRouter()
+	router.Put("/api/tracks/{id}", h.UpdateTrack)
+	router.Post("/api/tracks/{id}/revisions/{rev}/rollback", h.RollbackTrack)
+
+	send := func(method, url, body, userID string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, url, strings.NewReader(body))
+		if body == "" {
+			// An empty chunked body, as some clients send
+			r.ContentLength = -1
+			r.TransferEncoding = []string{"chunked"}
+		}
+		if userID != "" {
+			claims := &auth.TokenClaims{UserID: userID}
+			r = r.WithContext(context.WithValue(r.Context(), middleware.UserContextKey, claims))
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	update := `{"project": {"name": "mine", "pieces": [{"id": 1, "type": "straight", "params": {"length": 100}}]}, "message": "longer"}`
+	rollback := "/api/tracks/mine/revisions/1/rollback"
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		userID string
+		want   int
+	}{
+		{"update signed out", http.MethodPut, "/api/tracks/mine", update, "", http.StatusUnauthorized},
+		{"update by someone else", http.MethodPut, "/api/tracks/mine", update, "u2", http.StatusForbidden},
+		{"update a missing track", http.MethodPut, "/api/tracks/missing", update, "u1", http.StatusNotFound},
+		{"update by the uploader", http.MethodPut, "/api/tracks/mine", update, "u1", http.StatusOK},
+		{"rollback signed out", http.MethodPost, rollback, "", "", http.StatusUnauthorized},
+		{"rollback by someone else", http.MethodPost, rollback, "", "u2", http.StatusForbidden},
+		{"rollback with bad JSON", http.MethodPost, rollback, "{", "u1", http.StatusBadRequest},
+		{"rollback with an empty chunked body", http.MethodPost, rollback, "", "u1", http.StatusOK},
+		{"rollback with a message", http.MethodPost, rollback, `{"message": "undo"}`, "u1", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := send(tt.method, tt.url, tt.body, tt.userID); w.Code != tt.want {
+			t.Errorf("%s: %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+
+	revs, err := s.ListTrackRevisions("mine")
+	if err != nil || len(revs) != 4 {
+		t.Fatalf("revisions = %d, %v; want 4", len(revs), err)
+	}
+	if got, _ := s.GetTrack("mine"); got.UploaderID != "u1" || got.Pieces[0].Params.Length != 50 {
+		t.Errorf("after rollback: uploader %q, length %v", got.UploaderID, got.Pieces[0].Params.Length)
+	}
+}
+
+func TestUploadTrack_UploaderID(t *testing.T) {
+	h, s := newTestHandler(t)
+	body := `{"project": {"name": "spoof", "uploaderId": "u1", "pieces": [{"id": 1, "type": "straight", "params": {"length": 50}}]}}`
+
+	r := httptest.NewRequest(http.MethodPost, "/api/tracks", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.UploadTrack(w, r)
+	var resp struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("upload = %d, %v", w.Code, err)
+	}
+	if got, err := s.GetTrack(resp.Data.ID); err != nil || got.UploaderID != "" {
+		t.Errorf("anonymous upload claimed uploader %q, %v", got.UploaderID, err)
+	}
+}
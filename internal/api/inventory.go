@@ -0,0 +1,240 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/asc-lab/track-designer/internal/core"
+	"github.com/go-chi/chi/v5"
+)
+
+// InventoryRequest replaces an inventory, either as counts by BOM code or
+// as text like "12×L50, 8×R50-90"
+type InventoryRequest struct {
+	Items core.Inventory `json:"items,omitempty"`
+	Text  string         `json:"text,omitempty"`
+}
+
+type InventoryItemRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+type BuildabilityRequest struct {
+	Project json.RawMessage `json:"project"`
+}
+
+// GetInventory handles GET /api/inventory: the signed-in user's parts
+func (h *Handler) GetInventory(w http.ResponseWriter, r *http.Request) {
+	userID := currentUserID(w, r)
+	if userID == "" {
+		return
+	}
+
+	inv, err := h.store.GetInventory(userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to get inventory",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    map[string]interface{}{"items": inv},
+	})
+}
+
+// SetInventory handles PUT /api/inventory: replaces the signed-in user's
+// parts
+func (h *Handler) SetInventory(w http.ResponseWriter, r *http.Request) {
+	userID := currentUserID(w, r)
+	if userID == "" {
+		return
+	}
+
+	var req InventoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "Invalid request format",
+		})
+		return
+	}
+
+	inv := req.Items
+	if inv == nil {
+		inv = core.Inventory{}
+	}
+	if req.Text != "" {
+		parsed, err := core.ParseInventory(req.Text)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+		for code, n := range parsed {
+			inv[code] += n
+		}
+	}
+	if err := inv.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := h.store.SetInventory(userID, inv); err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to save inventory",
+		})
+		return
+	}
+
+	h.GetInventory(w, r)
+}
+
+// SetInventoryItem handles PUT /api/inventory/{code}: sets how many of one
+// part the signed-in user owns (0 removes it)
+func (h *Handler) SetInventoryItem(w http.ResponseWriter, r *http.Request) {
+	userID := currentUserID(w, r)
+	if userID == "" {
+		return
+	}
+
+	code := strings.TrimSpace(chi.URLParam(r, "code"))
+	var req InventoryItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || code == "" || req.Quantity < 0 {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "Invalid request format",
+		})
+		return
+	}
+
+	if err := h.store.SetInventoryItem(userID, code, req.Quantity); err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to save inventory",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    map[string]interface{}{"code": code, "quantity": req.Quantity},
+	})
+}
+
+// TrackBuildability handles GET /api/tracks/{id}/buildability: which parts
+// the signed-in user is short of to build a library track
+func (h *Handler) TrackBuildability(w http.ResponseWriter, r *http.Request) {
+	userID := currentUserID(w, r)
+	if userID == "" {
+		return
+	}
+
+	project, err := h.store.GetTrack(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "Track not found",
+		})
+		return
+	}
+
+	h.writeBuildability(w, userID, project)
+}
+
+// CheckBuildability handles POST /api/inventory/check: the same report for
+// a design that hasn't been uploaded
+func (h *Handler) CheckBuildability(w http.ResponseWriter, r *http.Request) {
+	userID := currentUserID(w, r)
+	if userID == "" {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadMB*1024*1024)
+	var req BuildabilityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "Invalid request format",
+		})
+		return
+	}
+
+	project, err := h.importTrack(req.Project)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid track data: %v", err),
+		})
+		return
+	}
+
+	h.writeBuildability(w, userID, project)
+}
+
+func (h *Handler) writeBuildability(w http.ResponseWriter, userID string, project *core.TrackProject) {
+	inv, err := h.store.GetInventory(userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to get inventory",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    core.CheckBuildability(h.store.Lab().GenerateBOM(project), inv),
+	})
+}
+
+// SubstituteParts handles GET /api/tracks/{id}/substitution: the track
+// rewritten to use only parts the signed-in user owns, where possible
+func (h *Handler) SubstituteParts(w http.ResponseWriter, r *http.Request) {
+	userID := currentUserID(w, r)
+	if userID == "" {
+		return
+	}
+
+	project, err := h.store.GetTrack(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "Track not found",
+		})
+		return
+	}
+
+	inv, err := h.store.GetInventory(userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to get inventory",
+		})
+		return
+	}
+
+	report, err := core.SubstituteParts(project, inv, core.DefaultTolerance)
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to substitute parts: %v", err),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    report,
+	})
+}
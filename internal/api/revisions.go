@@ -0,0 +1,317 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/asc-lab/track-designer/internal/core"
+	"github.com/asc-lab/track-designer/internal/middleware"
+	"github.com/asc-lab/track-designer/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// UpdateRequest saves a new version of an existing track
+type UpdateRequest struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Tags        []string        `json:"tags"`
+	Project     json.RawMessage `json:"project"`
+	Message     string          `json:"message"` // what changed, for the history
+
+	RejectOverlaps bool `json:"rejectOverlaps"`
+}
+
+type RollbackRequest struct {
+	Message string `json:"message"`
+}
+
+// revisionInfo credits a revision to the signed-in user, or to the track's
+// uploader for anonymous saves
+func revisionInfo(r *http.Request, project *core.TrackProject, message string) store.RevisionInfo {
+	info := store.RevisionInfo{AuthorName: project.UploaderName, Message: message}
+	if claims := middleware.GetUserFromContext(r.Context()); claims != nil {
+		info.AuthorID = claims.UserID
+		info.AuthorName = claims.Name
+		if info.AuthorName == "" {
+			info.AuthorName = claims.Login
+		}
+	}
+	return info
+}
+
+// revisionParam parses a revision number from the URL or query; ok is
+// false (and a 400 written) if it isn't a positive integer
+func revisionParam(w http.ResponseWriter, value string) (int, bool) {
+	rev, err := strconv.Atoi(value)
+	if err != nil || rev < 1 {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid revision: %s", value),
+		})
+		return 0, false
+	}
+	return rev, true
+}
+
+// writeRevisionError maps a missing track or revision to 404
+func writeRevisionError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, fs.ErrNotExist) {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "Revision not found",
+		})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, Response{
+		Success: false,
+		Error:   message,
+	})
+}
+
+// ownTrack loads a track the signed-in user uploaded, for changing it. It
+// writes 401, 404 or 403 and returns nil otherwise.
+func (h *Handler) ownTrack(w http.ResponseWriter, r *http.Request, id string) *core.TrackProject {
+	userID := currentUserID(w, r)
+	if userID == "" {
+		return nil
+	}
+
+	project, err := h.store.GetTrack(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "Track not found",
+		})
+		return nil
+	}
+	if project.UploaderID != userID {
+		writeJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "Only the uploader can change this track",
+		})
+		return nil
+	}
+	return project
+}
+
+// UpdateTrack handles PUT /api/tracks/{id}: saves a new revision of a
+// track, keeping its ID, creation time and uploader. Only the uploader
+// may update it.
+func (h *Handler) UpdateTrack(w http.ResponseWriter, r *http.Request) {
+	current := h.ownTrack(w, r, chi.URLParam(r, "id"))
+	if current == nil {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadMB*1024*1024)
+	var req UpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "Invalid request format",
+		})
+		return
+	}
+
+	project, err := h.importTrack(req.Project)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid track data: %v", err),
+		})
+		return
+	}
+
+	collisions, sweepErr := core.DetectCollisions(project)
+	if errors.Is(sweepErr, core.ErrTrackTooLarge) || errors.Is(sweepErr, core.ErrInvalidPiece) {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid track data: %v", sweepErr),
+		})
+		return
+	}
+	if sweepErr == nil && !collisions.Clear && req.RejectOverlaps {
+		writeJSON(w, http.StatusUnprocessableEntity, Response{
+			Success: false,
+			Data:    collisions,
+			Error:   "Track overlaps itself",
+		})
+		return
+	}
+
+	project.ID = current.ID
+	project.CreatedAt = current.CreatedAt
+	project.UpdatedAt = time.Now()
+	project.UploaderID = current.UploaderID
+	project.UploaderName = current.UploaderName
+	project.UploaderAvatar = current.UploaderAvatar
+
+	if req.Name != "" {
+		project.Name = req.Name
+	} else if project.Name == "" {
+		project.Name = current.Name
+	}
+	if req.Description != "" {
+		project.Description = req.Description
+	}
+	if req.Tags != nil {
+		project.Tags = req.Tags
+	} else if len(project.Tags) == 0 {
+		project.Tags = current.Tags
+	}
+
+	rev, err := h.store.SaveTrack(project, revisionInfo(r, project, req.Message))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to save track",
+		})
+		return
+	}
+
+	data := map[string]interface{}{
+		"id":        project.ID,
+		"name":      project.Name,
+		"updatedAt": project.UpdatedAt,
+		"revision":  rev.Revision,
+	}
+	if sweepErr == nil && !collisions.Clear {
+		data["collisions"] = collisions.Collisions
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// ListRevisions handles GET /api/tracks/{id}/revisions: a track's history,
+// newest first
+func (h *Handler) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	revisions, err := h.store.ListTrackRevisions(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to list revisions",
+		})
+		return
+	}
+	if len(revisions) == 0 {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "Track not found",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    revisions,
+	})
+}
+
+// GetRevision handles GET /api/tracks/{id}/revisions/{rev}: one version of
+// a track with its BOM
+func (h *Handler) GetRevision(w http.ResponseWriter, r *http.Request) {
+	revision, ok := revisionParam(w, chi.URLParam(r, "rev"))
+	if !ok {
+		return
+	}
+
+	rev, project, err := h.store.GetTrackRevision(chi.URLParam(r, "id"), revision)
+	if err != nil {
+		writeRevisionError(w, err, "Failed to get revision")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"revision": rev,
+			"project":  project,
+			"bom":      h.store.Lab().GenerateBOM(project),
+		},
+	})
+}
+
+// DiffRevisions handles GET /api/tracks/{id}/diff?from=3&to=5: what changed
+// piece by piece between two revisions (to defaults to the latest)
+func (h *Handler) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	from, ok := revisionParam(w, r.URL.Query().Get("from"))
+	if !ok {
+		return
+	}
+	to := 0
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, ok = revisionParam(w, v); !ok {
+			return
+		}
+	}
+
+	fromRev, fromProject, err := h.store.GetTrackRevision(id, from)
+	if err != nil {
+		writeRevisionError(w, err, "Failed to get revision")
+		return
+	}
+	toRev, toProject, err := h.store.GetTrackRevision(id, to)
+	if err != nil {
+		writeRevisionError(w, err, "Failed to get revision")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"from": fromRev,
+			"to":   toRev,
+			"diff": core.DiffTracks(fromProject, toProject),
+		},
+	})
+}
+
+// RollbackTrack handles POST /api/tracks/{id}/revisions/{rev}/rollback:
+// makes an old revision current again as a new revision. Only the
+// uploader may roll a track back.
+func (h *Handler) RollbackTrack(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	revision, ok := revisionParam(w, chi.URLParam(r, "rev"))
+	if !ok {
+		return
+	}
+
+	current := h.ownTrack(w, r, id)
+	if current == nil {
+		return
+	}
+
+	// The body is optional: an empty one, chunked or not, decodes to EOF
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadMB*1024*1024)
+	var req RollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "Invalid request format",
+		})
+		return
+	}
+
+	rev, err := h.store.RollbackTrack(id, revision, revisionInfo(r, current, req.Message))
+	if err != nil {
+		writeRevisionError(w, err, "Failed to roll back track")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    rev,
+	})
+}
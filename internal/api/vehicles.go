@@ -0,0 +1,217 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asc-lab/track-designer/internal/core"
+	"github.com/asc-lab/track-designer/internal/middleware"
+	"github.com/asc-lab/track-designer/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type VehicleRequest struct {
+	Name string `json:"name"`
+	core.VehicleModel
+}
+
+// currentUserID returns the signed-in user, or writes a 401 and returns ""
+func currentUserID(w http.ResponseWriter, r *http.Request) string {
+	claims := middleware.GetUserFromContext(r.Context())
+	if claims == nil || claims.UserID == "" {
+		writeJSON(w, http.StatusUnauthorized, Response{
+			Success: false,
+			Error:   "Sign in required",
+		})
+		return ""
+	}
+	return claims.UserID
+}
+
+// ListVehicles handles GET /api/vehicles: the signed-in user's vehicle
+// profiles
+func (h *Handler) ListVehicles(w http.ResponseWriter, r *http.Request) {
+	userID := currentUserID(w, r)
+	if userID == "" {
+		return
+	}
+
+	profiles, err := h.store.ListVehicleProfiles(userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to list vehicles",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    profiles,
+	})
+}
+
+// SaveVehicle handles POST /api/vehicles (create) and
+// PUT /api/vehicles/{vehicleId} (update)
+func (h *Handler) SaveVehicle(w http.ResponseWriter, r *http.Request) {
+	userID := currentUserID(w, r)
+	if userID == "" {
+		return
+	}
+
+	var req VehicleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "Invalid request format",
+		})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "Vehicle name is required",
+		})
+		return
+	}
+	if err := req.VehicleModel.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid vehicle: %v", err),
+		})
+		return
+	}
+
+	now := time.Now()
+	profile := &store.VehicleProfile{
+		ID:           chi.URLParam(r, "vehicleId"),
+		UserID:       userID,
+		Name:         req.Name,
+		VehicleModel: req.VehicleModel,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	status := http.StatusOK
+	if profile.ID == "" {
+		profile.ID = GenerateID()
+		status = http.StatusCreated
+	} else if existing, err := h.store.GetVehicleProfile(userID, profile.ID); err == nil {
+		profile.CreatedAt = existing.CreatedAt
+	} else {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "Vehicle not found",
+		})
+		return
+	}
+
+	if err := h.store.SaveVehicleProfile(profile); err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to save vehicle",
+		})
+		return
+	}
+
+	writeJSON(w, status, Response{
+		Success: true,
+		Data:    profile,
+	})
+}
+
+// DeleteVehicle handles DELETE /api/vehicles/{vehicleId}
+func (h *Handler) DeleteVehicle(w http.ResponseWriter, r *http.Request) {
+	userID := currentUserID(w, r)
+	if userID == "" {
+		return
+	}
+
+	err := h.store.DeleteVehicleProfile(userID, chi.URLParam(r, "vehicleId"))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "Vehicle not found",
+		})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to delete vehicle",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+	})
+}
+
+// LapTime handles GET /api/tracks/{id}/laptime: the estimated lap time,
+// per-piece speeds and cornering-limited sections. The car is the signed-in
+// user's ?vehicle= profile, or built from ?maxSpeed=&maxLateralAccel=
+// &maxAccel=&maxBrake=, each falling back to the default smart car.
+func (h *Handler) LapTime(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	project, err := h.store.GetTrack(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "Track not found",
+		})
+		return
+	}
+
+	q := r.URL.Query()
+	vehicle := core.DefaultVehicle
+	if vehicleID := q.Get("vehicle"); vehicleID != "" {
+		userID := currentUserID(w, r)
+		if userID == "" {
+			return
+		}
+		profile, err := h.store.GetVehicleProfile(userID, vehicleID)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, Response{
+				Success: false,
+				Error:   "Vehicle not found",
+			})
+			return
+		}
+		vehicle = profile.VehicleModel
+	}
+	for _, p := range []struct {
+		name string
+		dst  *float64
+	}{
+		{"maxSpeed", &vehicle.MaxSpeed},
+		{"maxLateralAccel", &vehicle.MaxLateralAccel},
+		{"maxAccel", &vehicle.MaxAccel},
+		{"maxBrake", &vehicle.MaxBrake},
+	} {
+		if v, err := strconv.ParseFloat(q.Get(p.name), 64); err == nil {
+			*p.dst = v
+		}
+	}
+
+	estimate, err := core.EstimateLapTime(project, vehicle)
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, Response{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to estimate lap time: %v", err),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    estimate,
+	})
+}
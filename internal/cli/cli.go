@@ -0,0 +1,108 @@
+// Package cli holds trackd's offline subcommands. main hands os.Args[1:] to
+// Run before starting the server:
+//
+//	if ok, err := cli.Run(os.Args[1:]); ok {
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		return
+//	}
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/asc-lab/track-designer/internal/core"
+)
+
+// Command is a subcommand run as `trackd <name> [flags] [args]`
+type Command struct {
+	Name  string
+	Usage string // one-line summary shown by `trackd help`
+	Run   func(args []string) error
+}
+
+var commands = map[string]*Command{}
+
+// Register adds a subcommand; called from init in each command's file
+func Register(c *Command) {
+	commands[c.Name] = c
+}
+
+// Stdout is where commands print, swapped out in tests
+var Stdout io.Writer = os.Stdout
+
+// Run dispatches to a subcommand. It returns false when args don't name
+// one (e.g. they're server flags like --port), so main starts the server.
+func Run(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	if args[0] == "help" {
+		printUsage()
+		return true, nil
+	}
+	c, ok := commands[args[0]]
+	if !ok {
+		return false, nil
+	}
+
+	err := c.Run(args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return true, nil
+	}
+	return true, err
+}
+
+func printUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(Stdout, "Usage: trackd [server flags] | trackd <command> [flags]")
+	fmt.Fprintln(Stdout, "\nCommands:")
+	for _, name := range names {
+		fmt.Fprintf(Stdout, "  %-12s %s\n", name, commands[name].Usage)
+	}
+}
+
+// newFlagSet returns a flag set that reports errors instead of exiting
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("trackd "+name, flag.ContinueOnError)
+	fs.SetOutput(Stdout)
+	return fs
+}
+
+// loadTrack reads a track file exported from the editor (or downloaded
+// from the library)
+func loadTrack(path string) (*core.TrackProject, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	project, err := core.ImportLegacyJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return project, nil
+}
+
+// writeFile creates path and hands it to write
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
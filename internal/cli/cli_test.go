@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_NotACommand(t *testing.T) {
+	for _, args := range [][]string{nil, {"--port", "9000"}} {
+		if ok, err := Run(args); ok || err != nil {
+			t.Errorf("Run(%v) = %v, %v; want false, nil", args, ok, err)
+		}
+	}
+}
+
+// writeOval saves a small closed oval as dir/oval.json
+func writeOval(t *testing.T, dir string) string {
+	t.Helper()
+	track := filepath.Join(dir, "oval.json")
+	if err := os.WriteFile(track, []byte(`{
+		"name": "oval",
+		"pieces": [
+			{"id": 1, "type": "straight", "params": {"length": 100}},
+			{"id": 2, "type": "curve", "params": {"radius": 50, "angle": 180}, "x": 200, "y": 0, "rotation": -90},
+			{"id": 3, "type": "straight", "params": {"length": 100}, "x": 200, "y": 200, "rotation": 180},
+			{"id": 4, "type": "curve", "params": {"radius": 50, "angle": 180}, "x": 0, "y": 200, "rotation": 90}
+		]
+	}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return track
+}
+
+func TestRun_ROSMap(t *testing.T) {
+	dir := t.TempDir()
+	track := writeOval(t, dir)
+
+	var out bytes.Buffer
+	Stdout = &out
+	defer func() { Stdout = os.Stdout }()
+
+	ok, err := Run([]string{"rosmap", "-resolution", "0.05", "-o", dir, track})
+	if !ok || err != nil {
+		t.Fatalf("Run() = %v, %v", ok, err)
+	}
+
+	yaml, err := os.ReadFile(filepath.Join(dir, "oval.yaml"))
+	if err != nil {
+		t.Fatalf("missing YAML: %v", err)
+	}
+	if !strings.Contains(string(yaml), "image: oval.pgm\n") || !strings.Contains(string(yaml), "resolution: 0.05\n") {
+		t.Errorf("unexpected YAML:\n%s", yaml)
+	}
+	pgm, err := os.ReadFile(filepath.Join(dir, "oval.pgm"))
+	if err != nil {
+		t.Fatalf("missing PGM: %v", err)
+	}
+	if !bytes.HasPrefix(pgm, []byte("P5\n")) {
+		t.Errorf("PGM header = %q", pgm[:10])
+	}
+
+	if ok, err := Run([]string{"rosmap"}); !ok || err == nil {
+		t.Errorf("Run(rosmap) without a track = %v, %v; want usage error", ok, err)
+	}
+}
+
+func TestRun_Dataset(t *testing.T) {
+	dir := t.TempDir()
+	track := writeOval(t, dir)
+	out := filepath.Join(dir, "set")
+
+	Stdout = &bytes.Buffer{}
+	defer func() { Stdout = os.Stdout }()
+
+	ok, err := Run([]string{"dataset", "-frames", "3", "-width", "32", "-height", "24", "-px-per-cm", "1", "-o", out, track})
+	if !ok || err != nil {
+		t.Fatalf("Run() = %v, %v", ok, err)
+	}
+
+	labels, err := os.ReadFile(filepath.Join(out, "labels.jsonl"))
+	if err != nil {
+		t.Fatalf("missing labels: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(labels)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d labels, want 3", len(lines))
+	}
+	if !strings.Contains(lines[2], `"image":"images/000002.png"`) {
+		t.Errorf("unexpected label: %s", lines[2])
+	}
+	for _, name := range []string{"camera.json", "images/000000.png", "images/000002.png"} {
+		if _, err := os.Stat(filepath.Join(out, name)); err != nil {
+			t.Errorf("missing %s: %v", name, err)
+		}
+	}
+}
+
+func TestRun_Migrate(t *testing.T) {
+	dir := t.TempDir()
+
+	var out bytes.Buffer
+	Stdout = &out
+	defer func() { Stdout = os.Stdout }()
+
+	if _, err := Run([]string{"migrate", "-data", dir, "status"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "0001  pending") || strings.HasSuffix(out.String(), "\n0 pending\n") {
+		t.Errorf("fresh database should be all pending:\n%s", out.String())
+	}
+
+	out.Reset()
+	if _, err := Run([]string{"migrate", "-data", dir, "up"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "applied 0001 ") {
+		t.Errorf("unexpected up output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if _, err := Run([]string{"migrate", "-data", dir, "up"}); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "schema is up to date\n" {
+		t.Errorf("second up = %q", out.String())
+	}
+
+	out.Reset()
+	if _, err := Run([]string{"migrate", "-data", dir, "status"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(out.String(), "\n0 pending\n") {
+		t.Errorf("migrated database has pending migrations:\n%s", out.String())
+	}
+
+	if _, err := Run([]string{"migrate", "-data", dir, "down"}); err == nil {
+		t.Error("expected usage error for unknown action")
+	}
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/asc-lab/track-designer/internal/core"
+)
+
+func init() {
+	Register(&Command{
+		Name:  "dataset",
+		Usage: "render synthetic onboard camera frames with ground-truth labels",
+		Run:   runDataset,
+	})
+}
+
+// runDataset: trackd dataset [flags] -o dir track.json
+//
+// Writes dir/images/000000.png..., dir/labels.jsonl (one label per frame)
+// and dir/camera.json with the camera intrinsics.
+func runDataset(args []string) error {
+	var opts core.DatasetOptions
+	var tex core.TextureOptions
+	fs := newFlagSet("dataset")
+	fs.IntVar(&opts.Frames, "frames", 100, "number of frames")
+	fs.Float64Var(&opts.MaxOffsetCm, "max-offset", 5, "random lateral offset from the centreline (cm, ±)")
+	fs.Float64Var(&opts.MaxYawDeg, "max-yaw", 10, "random heading error (degrees, ±)")
+	fs.Float64Var(&opts.LookaheadCm, "lookahead", 200, "how far ahead labels look (cm)")
+	fs.Int64Var(&opts.Seed, "seed", 0, "random seed for jitter, noise and lighting")
+	fs.Float64Var(&opts.Camera.HeightCm, "cam-height", 20, "camera height above the floor (cm)")
+	fs.Float64Var(&opts.Camera.PitchDeg, "pitch", 30, "camera pitch down from horizontal (degrees)")
+	fs.Float64Var(&opts.Camera.FOVDeg, "fov", 90, "horizontal field of view (degrees)")
+	fs.IntVar(&opts.Camera.Width, "width", 320, "image width (px)")
+	fs.IntVar(&opts.Camera.Height, "height", 240, "image height (px)")
+	fs.Float64Var(&opts.Camera.K1, "k1", 0, "radial distortion k1")
+	fs.Float64Var(&opts.Camera.K2, "k2", 0, "radial distortion k2")
+	fs.Float64Var(&tex.PxPerCm, "px-per-cm", 4, "floor texture resolution")
+	fs.StringVar(&tex.FloorPattern, "pattern", core.FloorPlain, "floor pattern: plain, carpet, tiles or wood")
+	fs.Float64Var(&tex.Noise, "noise", 0, "sensor noise, 0..1")
+	fs.Float64Var(&tex.Lighting, "lighting", 0, "uneven lighting, 0..1")
+	fs.IntVar(&tex.MaxPixels, "max-pixels", core.DefaultMaxRasterPixels, "largest floor texture to render (pixels)")
+	dir := fs.String("o", "dataset", "output directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: trackd dataset [flags] track.json")
+	}
+
+	project, err := loadTrack(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	tex.Seed = opts.Seed
+	texture, err := core.RenderTexture(project, tex)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Join(*dir, "images"), 0755); err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(*dir, "camera.json"), func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(core.NewCamera(opts.Camera, core.Pose{}).Options)
+	}); err != nil {
+		return err
+	}
+
+	labels, err := os.Create(filepath.Join(*dir, "labels.jsonl"))
+	if err != nil {
+		return err
+	}
+	defer labels.Close()
+	enc := json.NewEncoder(labels)
+
+	err = core.GenerateFrames(project, texture, opts, func(f core.Frame) error {
+		f.Label.Image = fmt.Sprintf("images/%06d.png", f.Label.Index)
+		if err := writeFile(filepath.Join(*dir, f.Label.Image), func(w io.Writer) error {
+			return png.Encode(w, f.Image)
+		}); err != nil {
+			return err
+		}
+		return enc.Encode(f.Label)
+	})
+	if err != nil {
+		return err
+	}
+	if err := labels.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, "wrote %d frames to %s\n", opts.Frames, *dir)
+	return nil
+}
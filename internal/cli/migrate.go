@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/asc-lab/track-designer/internal/store"
+)
+
+func init() {
+	Register(&Command{
+		Name:  "migrate",
+		Usage: "show or apply database schema migrations (status|up)",
+		Run:   runMigrate,
+	})
+}
+
+// runMigrate: trackd migrate [-data ./data] [-driver sqlite|postgres]
+// [-database-url url] status|up
+func runMigrate(args []string) error {
+	fs := newFlagSet("migrate")
+	dataDir := fs.String("data", defaultDataDir(), "data directory holding tracks.db")
+	driver := fs.String("driver", envOr("DB_DRIVER", store.DriverSQLite), "database: sqlite or postgres")
+	databaseURL := fs.String("database-url", os.Getenv("DATABASE_URL"), "postgres connection URL")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || (fs.Arg(0) != "status" && fs.Arg(0) != "up") {
+		return fmt.Errorf("usage: trackd migrate [-data dir] [-driver sqlite|postgres] [-database-url url] status|up")
+	}
+
+	if *driver == store.DriverSQLite {
+		if err := os.MkdirAll(*dataDir, 0755); err != nil {
+			return err
+		}
+	}
+	s, err := store.Open(store.Config{
+		DataDir:     *dataDir,
+		Driver:      *driver,
+		DatabaseURL: *databaseURL,
+	})
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if fs.Arg(0) == "up" {
+		applied, err := s.Migrate()
+		for _, m := range applied {
+			fmt.Fprintf(Stdout, "applied %04d %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(Stdout, "schema is up to date")
+		}
+		return nil
+	}
+
+	states, err := s.MigrationStatus()
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, m := range states {
+		applied := "pending"
+		if m.AppliedAt != nil {
+			applied = m.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		} else {
+			pending++
+		}
+		fmt.Fprintf(Stdout, "%04d  %-19s  %s\n", m.Version, applied, m.Name)
+	}
+	fmt.Fprintf(Stdout, "%d pending\n", pending)
+	return nil
+}
+
+// defaultDataDir matches the server's -data default
+func defaultDataDir() string {
+	return envOr("DATA_DIR", "./data")
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
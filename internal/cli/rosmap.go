@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/asc-lab/track-designer/internal/core"
+)
+
+func init() {
+	Register(&Command{
+		Name:  "rosmap",
+		Usage: "export a track as a ROS map (PGM + YAML) for map_server/Nav2",
+		Run:   runROSMap,
+	})
+}
+
+// runROSMap: trackd rosmap [-resolution 0.01] [-margin 20] [-max-pixels n] [-o dir] track.json
+func runROSMap(args []string) error {
+	fs := newFlagSet("rosmap")
+	resolution := fs.Float64("resolution", 0.01, "metres per cell")
+	margin := fs.Float64("margin", 20, "occupied border around the track (cm)")
+	maxPixels := fs.Int("max-pixels", core.DefaultMaxRasterPixels, "largest grid to render (cells)")
+	outDir := fs.String("o", ".", "output directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: trackd rosmap [flags] track.json")
+	}
+
+	project, err := loadTrack(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	m, err := core.RenderROSMap(project, core.ROSMapOptions{ResolutionM: *resolution, MarginCm: *margin, MaxPixels: *maxPixels})
+	if err != nil {
+		return err
+	}
+
+	base := strings.TrimSuffix(filepath.Base(fs.Arg(0)), filepath.Ext(fs.Arg(0)))
+	pgmPath := filepath.Join(*outDir, base+".pgm")
+	yamlPath := filepath.Join(*outDir, base+".yaml")
+
+	if err := writeFile(pgmPath, m.WritePGM); err != nil {
+		return err
+	}
+	if err := writeFile(yamlPath, func(w io.Writer) error {
+		return m.WriteYAML(w, base+".pgm")
+	}); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, "wrote %s (%dx%d cells) and %s\n", pgmPath, m.Width, m.Height, yamlPath)
+	return nil
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/asc-lab/track-designer/internal/core"
+)
+
+func init() {
+	Register(&Command{
+		Name:  "texture",
+		Usage: "paint a track as a top-down PNG texture for vision training",
+		Run:   runTexture,
+	})
+}
+
+// runTexture: trackd texture [flags] track.json
+func runTexture(args []string) error {
+	var opts core.TextureOptions
+	fs := newFlagSet("texture")
+	fs.Float64Var(&opts.PxPerCm, "px-per-cm", 4, "resolution")
+	fs.Float64Var(&opts.MarginCm, "margin", 30, "floor around the track (cm)")
+	fs.StringVar(&opts.TrackColor, "track", "", "track colour (#RRGGBB, default the skin colour)")
+	fs.StringVar(&opts.FloorColor, "floor", "", "floor colour (#RRGGBB)")
+	fs.StringVar(&opts.EdgeColor, "edge", "", "edge line colour (#RRGGBB)")
+	fs.Float64Var(&opts.EdgeLineCm, "edge-cm", 0, "edge line width (cm)")
+	fs.StringVar(&opts.FloorPattern, "pattern", core.FloorPlain, "floor pattern: plain, carpet, tiles or wood")
+	fs.Float64Var(&opts.Noise, "noise", 0, "sensor noise, 0..1")
+	fs.Float64Var(&opts.Lighting, "lighting", 0, "uneven lighting, 0..1")
+	fs.Int64Var(&opts.Seed, "seed", 0, "random seed for noise and lighting")
+	fs.IntVar(&opts.MaxPixels, "max-pixels", core.DefaultMaxRasterPixels, "largest image to render (pixels)")
+	out := fs.String("o", "", "output file (default <track>.png)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: trackd texture [flags] track.json")
+	}
+
+	project, err := loadTrack(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	tex, err := core.RenderTexture(project, opts)
+	if err != nil {
+		return err
+	}
+
+	path := *out
+	if path == "" {
+		path = strings.TrimSuffix(fs.Arg(0), filepath.Ext(fs.Arg(0))) + ".png"
+	}
+	if err := writeFile(path, func(w io.Writer) error {
+		return png.Encode(w, tex.Image)
+	}); err != nil {
+		return err
+	}
+
+	b := tex.Image.Bounds()
+	fmt.Fprintf(Stdout, "wrote %s (%dx%d px, %g px/cm, top-left at %g,%g cm)\n",
+		path, b.Dx(), b.Dy(), tex.PxPerCm, tex.MinX, tex.MaxY)
+	return nil
+}
@@ -10,9 +10,18 @@ import (
 )
 
 type Config struct {
-	Port             string
-	DataDir          string
-	MaxUploadMB      int64
+	Port        string
+	DataDir     string
+	MaxUploadMB int64
+
+	// Largest texture, ROS map or world ground image rendered per
+	// request, in pixels (4 bytes each while rendering)
+	MaxRasterPixels int
+
+	// Database: "sqlite" (DataDir/tracks.db) or "postgres" (DatabaseURL),
+	// passed to store.Config
+	DBDriver    string
+	DatabaseURL string
 
 	// OAuth Configuration
 	GitHubClientID     string
@@ -20,8 +29,19 @@ type Config struct {
 	GitHubCallbackURL  string
 
 	// JWT Configuration
-	JWTSecret          string
-	JWTExpiryHours     int
+	JWTSecret      string
+	JWTExpiryHours int
+
+	// Blob storage for track files and thumbnails: "local" (DataDir) or
+	// "s3", passed to store.OpenBlobStore
+	BlobStore         string
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3Prefix          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3PathStyle       bool
 }
 
 func Load() *Config {
@@ -33,6 +53,7 @@ func Load() *Config {
 	flag.StringVar(&cfg.Port, "port", getEnv("PORT", "8080"), "HTTP port")
 	flag.StringVar(&cfg.DataDir, "data", getEnv("DATA_DIR", "./data"), "Data directory")
 	flag.Int64Var(&cfg.MaxUploadMB, "max-upload", 5, "Max upload size in MB")
+	flag.IntVar(&cfg.MaxRasterPixels, "max-raster-pixels", 16_000_000, "Max pixels in a rendered texture or map")
 	flag.Parse()
 
 	// Load OAuth configuration from environment
@@ -48,10 +69,25 @@ func Load() *Config {
 	}
 	cfg.JWTExpiryHours = 24 * 7 // 7 days default
 
+	// Load database configuration
+	cfg.DBDriver = getEnv("DB_DRIVER", "sqlite")
+	cfg.DatabaseURL = getEnv("DATABASE_URL", "")
+
+	// Load blob storage configuration
+	cfg.BlobStore = getEnv("BLOB_STORE", "local")
+	cfg.S3Endpoint = getEnv("S3_ENDPOINT", "")
+	cfg.S3Region = getEnv("S3_REGION", "us-east-1")
+	cfg.S3Bucket = getEnv("S3_BUCKET", "")
+	cfg.S3Prefix = getEnv("S3_PREFIX", "")
+	cfg.S3AccessKeyID = getEnv("S3_ACCESS_KEY_ID", "")
+	cfg.S3SecretAccessKey = getEnv("S3_SECRET_ACCESS_KEY", "")
+	cfg.S3PathStyle = getEnv("S3_PATH_STYLE", "") == "true"
+
 	// Ensure data directory exists
 	os.MkdirAll(cfg.DataDir, 0755)
 	os.MkdirAll(filepath.Join(cfg.DataDir, "tracks"), 0755)
 	os.MkdirAll(filepath.Join(cfg.DataDir, "exports"), 0755)
+	os.MkdirAll(filepath.Join(cfg.DataDir, "thumbnails"), 0755)
 
 	return cfg
 }
@@ -0,0 +1,169 @@
+package core
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// bomRow is one row of a BOM export; cells are string or float64
+type bomRow []interface{}
+
+// bomRows lays out a BOM for purchase orders: a header, one row per part
+// (and material, when priced) and a total row
+func bomRows(name string, bom *BOMSummary) []bomRow {
+	rows := []bomRow{
+		{"Track", name},
+		{"Total length (m)", bom.TotalLength},
+		{},
+	}
+
+	if bom.Cost == nil {
+		rows = append(rows, bomRow{"Code", "Name", "Quantity"})
+		catalog := bom.partsCatalog()
+		codes := make([]string, 0, len(bom.BOM))
+		for code := range bom.BOM {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			name := code
+			if entry, ok := catalog.Entry(code); ok {
+				name = entry.Name
+			}
+			rows = append(rows, bomRow{code, name, float64(bom.BOM[code])})
+		}
+		return append(rows, bomRow{"Total", "", float64(bom.TotalPieces)})
+	}
+
+	currency := bom.Cost.Currency
+	rows = append(rows, bomRow{"Code", "Name", "Quantity", "Unit", "Unit price " + currency, "Total " + currency})
+	for _, line := range bom.Cost.Lines {
+		row := bomRow{line.Code, line.Name, line.Quantity, line.Unit, "", ""}
+		if line.Priced {
+			row[4], row[5] = line.UnitPrice, line.Total
+		}
+		rows = append(rows, row)
+	}
+	return append(rows, bomRow{"Total", "", "", "", "", bom.Cost.Total})
+}
+
+// csvText keeps a text cell from being read as a formula when the CSV is
+// opened in a spreadsheet: track names come from users,
+// and a cell such as =HYPERLINK(...) would run. A leading ' makes the
+// spreadsheet show it as text.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// xmlText drops the characters XML 1.0 doesn't allow, which would make
+// Excel refuse the whole sheet. Invalid UTF-8 becomes U+FFFD.
+func xmlText(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r',
+			r >= 0x20 && r <= 0xD7FF,
+			r >= 0xE000 && r <= 0xFFFD,
+			r >= 0x10000 && r <= 0x10FFFF:
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// WriteBOMCSV writes the BOM as CSV
+func WriteBOMCSV(out io.Writer, name string, bom *BOMSummary) error {
+	w := csv.NewWriter(out)
+	for _, row := range bomRows(name, bom) {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			switch v := cell.(type) {
+			case float64:
+				record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			case string:
+				record[i] = csvText(v)
+			}
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// WriteBOMXLSX writes the BOM as a one-sheet Excel workbook, with numbers
+// as numeric cells so they can be summed
+func WriteBOMXLSX(out io.Writer, name string, bom *BOMSummary) error {
+	zw := zip.NewWriter(out)
+	files := []struct {
+		name, body string
+	}{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="BOM" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`},
+	}
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, f.body); err != nil {
+			return err
+		}
+	}
+
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	b := bufio.NewWriter(sheet)
+	fmt.Fprint(b, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<cols><col min="1" max="1" width="18" customWidth="1"/><col min="2" max="2" width="28" customWidth="1"/><col min="3" max="6" width="14" customWidth="1"/></cols>
+<sheetData>`)
+	for r, row := range bomRows(name, bom) {
+		fmt.Fprintf(b, `<row r="%d">`, r+1)
+		for c, cell := range row {
+			ref := fmt.Sprintf("%c%d", 'A'+c, r+1)
+			switch v := cell.(type) {
+			case float64:
+				fmt.Fprintf(b, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'f', -1, 64))
+			case string:
+				if v != "" {
+					fmt.Fprintf(b, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, html.EscapeString(xmlText(v)))
+				}
+			}
+		}
+		fmt.Fprint(b, "</row>")
+	}
+	fmt.Fprint(b, "</sheetData>\n</worksheet>\n")
+	if err := b.Flush(); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
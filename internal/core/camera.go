@@ -0,0 +1,185 @@
+package core
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// CameraOptions describes the onboard camera. Zero values fall back to a
+// typical smart-car setup: 20cm up, 30° down, 90° wide, 320x240.
+type CameraOptions struct {
+	HeightCm float64 `json:"heightCm"`
+	PitchDeg float64 `json:"pitchDeg"` // down from horizontal
+	FOVDeg   float64 `json:"fovDeg"`   // horizontal, before distortion
+	Width    int     `json:"width"`
+	Height   int     `json:"height"`
+	K1       float64 `json:"k1"` // radial distortion (Brown-Conrady)
+	K2       float64 `json:"k2"`
+}
+
+func (o CameraOptions) withDefaults() CameraOptions {
+	if o.HeightCm <= 0 {
+		o.HeightCm = 20
+	}
+	if o.PitchDeg == 0 {
+		o.PitchDeg = 30
+	}
+	if o.FOVDeg <= 0 {
+		o.FOVDeg = 90
+	}
+	if o.Width <= 0 {
+		o.Width = 320
+	}
+	if o.Height <= 0 {
+		o.Height = 240
+	}
+	return o
+}
+
+// skyColor fills everything above the horizon
+var skyColor = color.RGBA{R: 200, G: 200, B: 200, A: 255}
+
+// Camera is a pinhole camera with radial distortion, standing on the floor
+// at a pose
+type Camera struct {
+	Options CameraOptions
+	Pose    Pose // on the floor (cm, degrees)
+
+	focal, cx, cy float64
+	pos           [3]float64
+	right         [3]float64 // camera x axis in floor coordinates
+	down          [3]float64 // camera y axis
+	forward       [3]float64 // camera z axis (optical axis)
+
+	// Past this normalised radius strong barrel distortion folds back on
+	// itself, so nothing beyond it is imaged
+	maxR2 float64
+}
+
+// NewCamera places a camera at a floor pose
+func NewCamera(opts CameraOptions, pose Pose) *Camera {
+	opts = opts.withDefaults()
+	c := &Camera{Options: opts, Pose: pose}
+
+	c.focal = float64(opts.Width) / 2 / math.Tan(opts.FOVDeg*math.Pi/360)
+	c.cx = float64(opts.Width) / 2
+	c.cy = float64(opts.Height) / 2
+
+	h := pose.Heading * math.Pi / 180
+	p := opts.PitchDeg * math.Pi / 180
+	fx, fy := math.Cos(h), math.Sin(h)
+
+	c.pos = [3]float64{pose.X, pose.Y, opts.HeightCm}
+	c.right = [3]float64{fy, -fx, 0}
+	c.forward = [3]float64{math.Cos(p) * fx, math.Cos(p) * fy, -math.Sin(p)}
+	c.down = [3]float64{-math.Sin(p) * fx, -math.Sin(p) * fy, -math.Cos(p)}
+
+	c.maxR2 = math.Inf(1)
+	for r2 := 0.0; r2 < 100; r2 += 0.001 {
+		// d(r·s(r))/dr
+		if 1+3*opts.K1*r2+5*opts.K2*r2*r2 <= 0 {
+			c.maxR2 = r2
+			break
+		}
+	}
+	return c
+}
+
+// Render draws what the camera sees of a texture
+func (c *Camera) Render(tex *TrackTexture) *image.RGBA {
+	w, h := c.Options.Width, c.Options.Height
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	// Beyond the texture the floor carries on in the floor colour
+	floor := tex.Image.RGBAAt(0, 0)
+
+	for v := 0; v < h; v++ {
+		for u := 0; u < w; u++ {
+			xu, yu, ok := c.undistort((float64(u)+0.5-c.cx)/c.focal, (float64(v)+0.5-c.cy)/c.focal)
+			if !ok {
+				// Outside the lens' image circle
+				img.SetRGBA(u, v, color.RGBA{A: 255})
+				continue
+			}
+
+			var ray [3]float64
+			for k := 0; k < 3; k++ {
+				ray[k] = xu*c.right[k] + yu*c.down[k] + c.forward[k]
+			}
+
+			px := color.RGBA{A: 255}
+			if ray[2] >= 0 {
+				px = skyColor
+			} else {
+				t := -c.pos[2] / ray[2]
+				x, y := c.pos[0]+t*ray[0], c.pos[1]+t*ray[1]
+				if r, g, b, ok := tex.Sample(x, y); ok {
+					px.R, px.G, px.B = clampByte(r), clampByte(g), clampByte(b)
+				} else {
+					px = floor
+				}
+			}
+			img.SetRGBA(u, v, px)
+		}
+	}
+	return img
+}
+
+// Project maps a floor point to pixel coordinates, returning false if it's
+// behind the camera or outside the image
+func (c *Camera) Project(x, y float64) (float64, float64, bool) {
+	d := [3]float64{x - c.pos[0], y - c.pos[1], -c.pos[2]}
+	X := d[0]*c.right[0] + d[1]*c.right[1] + d[2]*c.right[2]
+	Y := d[0]*c.down[0] + d[1]*c.down[1] + d[2]*c.down[2]
+	Z := d[0]*c.forward[0] + d[1]*c.forward[1] + d[2]*c.forward[2]
+	if Z <= 0 || (X*X+Y*Y)/(Z*Z) >= c.maxR2 {
+		return 0, 0, false
+	}
+
+	xd, yd := c.distort(X/Z, Y/Z)
+	u := c.cx + c.focal*xd
+	v := c.cy + c.focal*yd
+	if u < 0 || v < 0 || u >= float64(c.Options.Width) || v >= float64(c.Options.Height) {
+		return 0, 0, false
+	}
+	return u, v, true
+}
+
+func (c *Camera) distort(x, y float64) (float64, float64) {
+	r2 := x*x + y*y
+	s := 1 + c.Options.K1*r2 + c.Options.K2*r2*r2
+	return x * s, y * s
+}
+
+// undistort inverts distort by bisection on the radius, returning false
+// for points no undistorted ray maps to
+func (c *Camera) undistort(xd, yd float64) (float64, float64, bool) {
+	if c.Options.K1 == 0 && c.Options.K2 == 0 {
+		return xd, yd, true
+	}
+	rd := math.Hypot(xd, yd)
+	if rd == 0 {
+		return 0, 0, true
+	}
+	distorted := func(r float64) float64 {
+		r2 := r * r
+		return r * (1 + c.Options.K1*r2 + c.Options.K2*r2*r2)
+	}
+
+	hi := math.Sqrt(math.Min(c.maxR2, 100))
+	if distorted(hi) < rd {
+		return 0, 0, false
+	}
+	lo := 0.0
+	for i := 0; i < 40; i++ {
+		mid := (lo + hi) / 2
+		if distorted(mid) < rd {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	r := (lo + hi) / 2
+	return xd * r / rd, yd * r / rd, true
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCamera_ProjectUndistort(t *testing.T) {
+	cam := NewCamera(CameraOptions{K1: -0.2, K2: 0.02}, Pose{X: 0, Y: 0, Heading: 0})
+
+	// A point straight ahead on the floor lands on the vertical midline
+	u, v, ok := cam.Project(25, 0)
+	if !ok || math.Abs(u-160) > 1e-9 || v <= 120 {
+		t.Errorf("Project(25, 0) = %v, %v, %v; want u=160 below the centre", u, v, ok)
+	}
+	if _, _, ok := cam.Project(-50, 0); ok {
+		t.Error("expected point behind the camera to be invisible")
+	}
+
+	for _, p := range [][2]float64{{0.1, 0.2}, {-0.5, 0.3}, {0.7, -0.6}} {
+		xd, yd := cam.distort(p[0], p[1])
+		x, y, ok := cam.undistort(xd, yd)
+		if !ok || math.Abs(x-p[0]) > 1e-9 || math.Abs(y-p[1]) > 1e-9 {
+			t.Errorf("undistort(distort(%v)) = %v, %v, %v", p, x, y, ok)
+		}
+	}
+}
+
+func TestCamera_Render(t *testing.T) {
+	project := &TrackProject{
+		Pieces: []Piece{{ID: "a", Type: "straight", Params: PieceParams{Length: 300}}},
+	}
+	tex, err := RenderTexture(project, TextureOptions{PxPerCm: 2, TrackColor: "#00FF00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cam := NewCamera(CameraOptions{PitchDeg: 20}, Pose{X: 20, Y: 0, Heading: 0})
+	img := cam.Render(tex)
+
+	if got := img.RGBAAt(160, 239); got.G != 255 || got.R != 0 {
+		t.Errorf("bottom centre = %v, want track green", got)
+	}
+	if got := img.RGBAAt(160, 0); got != skyColor {
+		t.Errorf("top centre = %v, want sky", got)
+	}
+}
+
+func TestGenerateFrames(t *testing.T) {
+	project := figureEight()
+	tex, err := RenderTexture(project, TextureOptions{PxPerCm: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DatasetOptions{
+		Camera:      CameraOptions{Width: 64, Height: 48},
+		Frames:      8,
+		MaxOffsetCm: 5,
+		MaxYawDeg:   10,
+		Seed:        1,
+	}
+	var frames []Frame
+	if err := GenerateFrames(project, tex, opts, func(f Frame) error {
+		frames = append(frames, f)
+		return nil
+	}); err != nil {
+		t.Fatalf("GenerateFrames() error = %v", err)
+	}
+
+	if len(frames) != 8 {
+		t.Fatalf("got %d frames, want 8", len(frames))
+	}
+	sawElement := false
+	for _, f := range frames {
+		l := f.Label
+		if b := f.Image.Bounds(); b.Dx() != 64 || b.Dy() != 48 {
+			t.Errorf("frame %d size = %v", l.Index, b)
+		}
+		if math.Abs(l.LateralOffsetCm) > 5 || math.Abs(l.HeadingErrorDeg) > 10 {
+			t.Errorf("frame %d jitter out of range: %+v", l.Index, l)
+		}
+		if len(l.Centreline) == 0 {
+			t.Errorf("frame %d sees no centreline", l.Index)
+		}
+		if l.ElementAhead != "" {
+			sawElement = true
+		}
+	}
+	if !sawElement {
+		t.Error("expected the crossroads to show up ahead of some frame")
+	}
+
+	if err := GenerateFrames(project, tex, DatasetOptions{}, func(Frame) error { return nil }); err == nil {
+		t.Error("expected error for zero frames")
+	}
+}
@@ -0,0 +1,256 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Geometry kinds understood by the pose solver
+const (
+	GeometryStraight   = "straight"
+	GeometryArc        = "arc"
+	GeometryCrossing   = "crossing"   // four-way crossroads
+	GeometryRoundabout = "roundabout" // main line with a circular island
+)
+
+var knownGeometries = map[string]bool{
+	GeometryStraight:   true,
+	GeometryArc:        true,
+	GeometryCrossing:   true,
+	GeometryRoundabout: true,
+}
+
+// ElementType describes one kind of track element
+type ElementType struct {
+	Type     string `json:"type"`     // value of Piece.Type
+	Name     string `json:"name"`     // display name
+	Geometry string `json:"geometry"` // how the centreline is laid out
+	// CodeFormat builds a BOM code for pieces not in the catalogue,
+	// e.g. "L{length}" or "R{radius}-{angle}"
+	CodeFormat string `json:"codeFormat"`
+	// Marking elements (zebra, stop line) are painted on the mat and may
+	// only sit between straight-line pieces
+	Marking bool `json:"marking,omitempty"`
+}
+
+// CatalogEntry is one standard part
+type CatalogEntry struct {
+	Code     string      `json:"code"` // BOM code, e.g. "L50"
+	Name     string      `json:"name"`
+	Type     string      `json:"type"`
+	Params   PieceParams `json:"params"`
+	Category string      `json:"category"`
+}
+
+// Catalog is the set of element types and standard parts
+type Catalog struct {
+	Types  []ElementType  `json:"types"`
+	Pieces []CatalogEntry `json:"pieces"`
+}
+
+// DefaultCatalog is the standard parts and the competition special
+// elements. The editor's palette loads from GET /api/catalog and keeps a
+// copy of the standard parts in web/src/trackPieces.ts for when the server
+// can't be reached.
+func DefaultCatalog() *Catalog {
+	return &Catalog{
+		Types: []ElementType{
+			{Type: "straight", Name: "直道", Geometry: GeometryStraight, CodeFormat: "L{length}"},
+			{Type: "curve", Name: "弯道", Geometry: GeometryArc, CodeFormat: "R{radius}-{angle}"},
+			{Type: "crossroads", Name: "十字路口", Geometry: GeometryCrossing, CodeFormat: "X{length}"},
+			{Type: "roundabout", Name: "环岛", Geometry: GeometryRoundabout, CodeFormat: "H{radius}-L{length}"},
+			{Type: "ramp", Name: "坡道", Geometry: GeometryStraight, CodeFormat: "P{length}-{height}"},
+			{Type: "zebra", Name: "斑马线", Geometry: GeometryStraight, CodeFormat: "Z{length}", Marking: true},
+			{Type: "stopline", Name: "停车线", Geometry: GeometryStraight, CodeFormat: "S{length}", Marking: true},
+			{Type: "obstacle", Name: "障碍区", Geometry: GeometryStraight, CodeFormat: "O{length}"},
+		},
+		Pieces: []CatalogEntry{
+			{Code: "L25", Name: "L25 (25cm)", Type: "straight", Params: PieceParams{Length: 25}, Category: "straight"},
+			{Code: "L37.5", Name: "L37.5 (37.5cm)", Type: "straight", Params: PieceParams{Length: 37.5}, Category: "straight"},
+			{Code: "L50", Name: "L50 (50cm)", Type: "straight", Params: PieceParams{Length: 50}, Category: "straight"},
+			{Code: "L75", Name: "L75 (75cm)", Type: "straight", Params: PieceParams{Length: 75}, Category: "straight"},
+			{Code: "L100", Name: "L100 (100cm)", Type: "straight", Params: PieceParams{Length: 100}, Category: "straight"},
+			{Code: "R50-30", Name: "R50-30° (半径50cm)", Type: "curve", Params: PieceParams{Radius: 50, Angle: 30}, Category: "curve"},
+			{Code: "R50-45", Name: "R50-45° (半径50cm)", Type: "curve", Params: PieceParams{Radius: 50, Angle: 45}, Category: "curve"},
+			{Code: "R50-60", Name: "R50-60° (半径50cm)", Type: "curve", Params: PieceParams{Radius: 50, Angle: 60}, Category: "curve"},
+			{Code: "R50-90", Name: "R50-90° (半径50cm)", Type: "curve", Params: PieceParams{Radius: 50, Angle: 90}, Category: "curve"},
+			{Code: "R60-30", Name: "R60-30° (半径60cm)", Type: "curve", Params: PieceParams{Radius: 60, Angle: 30}, Category: "curve"},
+			{Code: "R60-45", Name: "R60-45° (半径60cm)", Type: "curve", Params: PieceParams{Radius: 60, Angle: 45}, Category: "curve"},
+			{Code: "R60-60", Name: "R60-60° (半径60cm)", Type: "curve", Params: PieceParams{Radius: 60, Angle: 60}, Category: "curve"},
+			{Code: "R60-90", Name: "R60-90° (半径60cm)", Type: "curve", Params: PieceParams{Radius: 60, Angle: 90}, Category: "curve"},
+			{Code: "R70-45", Name: "R70-45° (半径70cm)", Type: "curve", Params: PieceParams{Radius: 70, Angle: 45}, Category: "curve"},
+			{Code: "X45", Name: "十字路口 (45cm)", Type: "crossroads", Params: PieceParams{Length: 45}, Category: "special"},
+			{Code: "H50-L100", Name: "环岛 R50 (直道100cm)", Type: "roundabout", Params: PieceParams{Length: 100, Radius: 50}, Category: "special"},
+			{Code: "H70-L100", Name: "环岛 R70 (直道100cm)", Type: "roundabout", Params: PieceParams{Length: 100, Radius: 70}, Category: "special"},
+			{Code: "P100-10", Name: "坡道 (100cm, 高10cm)", Type: "ramp", Params: PieceParams{Length: 100, Height: 10}, Category: "special"},
+			{Code: "Z25", Name: "斑马线 (25cm)", Type: "zebra", Params: PieceParams{Length: 25}, Category: "marking"},
+			{Code: "S5", Name: "停车线 (5cm)", Type: "stopline", Params: PieceParams{Length: 5}, Category: "marking"},
+			{Code: "O50", Name: "障碍区 (50cm)", Type: "obstacle", Params: PieceParams{Length: 50}, Category: "special"},
+		},
+	}
+}
+
+// defaultCatalog is used for projects without a catalogue of their own.
+// It is never modified.
+var defaultCatalog = DefaultCatalog()
+
+// Catalog returns the catalogue the project's piece types and BOM codes
+// come from: the one given to UseCatalog, or the built-in one
+func (p *TrackProject) Catalog() *Catalog {
+	if p.catalog == nil {
+		return defaultCatalog
+	}
+	return p.catalog
+}
+
+// UseCatalog sets the catalogue for the project, normally the one of the
+// lab whose store it was loaded from
+func (p *TrackProject) UseCatalog(c *Catalog) {
+	p.catalog = c
+}
+
+// partsCatalog is the catalogue the BOM's codes come from
+func (b *BOMSummary) partsCatalog() *Catalog {
+	if b.catalog == nil {
+		return defaultCatalog
+	}
+	return b.catalog
+}
+
+// LoadCatalog reads a catalogue file and merges it over the defaults.
+// Types and parts with the same type/code replace the built-in ones, so a
+// lab file only needs to list its own parts. A missing file is not an error.
+func LoadCatalog(path string) (*Catalog, error) {
+	catalog := DefaultCatalog()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return catalog, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var custom Catalog
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("invalid catalog %s: %w", path, err)
+	}
+
+	for _, t := range custom.Types {
+		if t.Type == "" {
+			return nil, fmt.Errorf("invalid catalog %s: element type without a name", path)
+		}
+		if !knownGeometries[t.Geometry] {
+			return nil, fmt.Errorf("invalid catalog %s: type %s has unknown geometry %q", path, t.Type, t.Geometry)
+		}
+		catalog.setType(t)
+	}
+	for _, p := range custom.Pieces {
+		if p.Code == "" {
+			return nil, fmt.Errorf("invalid catalog %s: part without a code", path)
+		}
+		if _, ok := catalog.Type(p.Type); !ok {
+			return nil, fmt.Errorf("invalid catalog %s: part %s has unknown type %q", path, p.Code, p.Type)
+		}
+		catalog.setPiece(p)
+	}
+
+	return catalog, nil
+}
+
+// Type looks up an element type by Piece.Type
+func (c *Catalog) Type(name string) (ElementType, bool) {
+	for _, t := range c.Types {
+		if t.Type == name {
+			return t, true
+		}
+	}
+	return ElementType{}, false
+}
+
+// Entry looks up a standard part by BOM code
+func (c *Catalog) Entry(code string) (CatalogEntry, bool) {
+	for _, p := range c.Pieces {
+		if p.Code == code {
+			return p, true
+		}
+	}
+	return CatalogEntry{}, false
+}
+
+// Code returns the BOM code for a piece: the matching catalogue part's
+// code, or one built from the type's code format
+func (c *Catalog) Code(piece Piece) string {
+	for _, p := range c.Pieces {
+		if p.Type == piece.Type && sameParams(p.Params, piece.Params) {
+			return p.Code
+		}
+	}
+
+	t, ok := c.Type(piece.Type)
+	if !ok || t.CodeFormat == "" {
+		return "UNKNOWN"
+	}
+	return strings.NewReplacer(
+		"{length}", formatParam(piece.Params.Length),
+		"{radius}", formatParam(piece.Params.Radius),
+		"{angle}", formatParam(math.Abs(piece.Params.Angle)),
+		"{height}", formatParam(piece.Params.Height),
+	).Replace(t.CodeFormat)
+}
+
+// Piece returns a piece instance of a catalogue part
+func (e CatalogEntry) Piece() Piece {
+	return Piece{
+		Type:   e.Type,
+		Params: e.Params,
+	}
+}
+
+func (c *Catalog) setType(t ElementType) {
+	for i := range c.Types {
+		if c.Types[i].Type == t.Type {
+			c.Types[i] = t
+			return
+		}
+	}
+	c.Types = append(c.Types, t)
+}
+
+func (c *Catalog) setPiece(p CatalogEntry) {
+	for i := range c.Pieces {
+		if c.Pieces[i].Code == p.Code {
+			c.Pieces[i] = p
+			return
+		}
+	}
+	c.Pieces = append(c.Pieces, p)
+}
+
+// geometryOf resolves a piece type to its geometry kind
+func (c *Catalog) geometryOf(pieceType string) (string, error) {
+	t, ok := c.Type(pieceType)
+	if !ok {
+		return "", fmt.Errorf("unknown piece type: %s", pieceType)
+	}
+	return t.Geometry, nil
+}
+
+// sameParams compares the dimensions that identify a part; curve direction
+// doesn't matter since the same part is flipped over
+func sameParams(a, b PieceParams) bool {
+	const eps = 1e-6
+	return math.Abs(a.Length-b.Length) < eps &&
+		math.Abs(a.Radius-b.Radius) < eps &&
+		math.Abs(a.Height-b.Height) < eps &&
+		math.Abs(math.Abs(a.Angle)-math.Abs(b.Angle)) < eps
+}
+
+func formatParam(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
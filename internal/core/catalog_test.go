@@ -0,0 +1,92 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCatalogCode(t *testing.T) {
+	catalog := DefaultCatalog()
+
+	tests := []struct {
+		piece Piece
+		want  string
+	}{
+		{Piece{Type: "straight", Params: PieceParams{Length: 37.5}}, "L37.5"},
+		{Piece{Type: "curve", Params: PieceParams{Radius: 50, Angle: -90}}, "R50-90"},
+		{Piece{Type: "straight", Params: PieceParams{Length: 12}}, "L12"},
+		{Piece{Type: "wormhole"}, "UNKNOWN"},
+	}
+
+	for _, tt := range tests {
+		if got := catalog.Code(tt.piece); got != tt.want {
+			t.Errorf("Code(%+v) = %s, want %s", tt.piece, got, tt.want)
+		}
+	}
+}
+
+func TestLoadCatalog(t *testing.T) {
+	dir := t.TempDir()
+
+	// Missing file falls back to the defaults
+	catalog, err := LoadCatalog(filepath.Join(dir, "catalog.json"))
+	if err != nil {
+		t.Fatalf("LoadCatalog() error = %v", err)
+	}
+	if len(catalog.Pieces) != len(DefaultCatalog().Pieces) {
+		t.Errorf("expected default catalogue, got %d parts", len(catalog.Pieces))
+	}
+
+	custom := `{
+		"types": [{"type": "flex", "name": "软直道", "geometry": "straight", "codeFormat": "F{length}"}],
+		"pieces": [{"code": "F30", "name": "F30", "type": "flex", "params": {"length": 30}}]
+	}`
+	path := filepath.Join(dir, "catalog.json")
+	if err := os.WriteFile(path, []byte(custom), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	catalog, err = LoadCatalog(path)
+	if err != nil {
+		t.Fatalf("LoadCatalog() error = %v", err)
+	}
+	if _, ok := catalog.Entry("L50"); !ok {
+		t.Error("built-in parts should be kept")
+	}
+	if got := catalog.Code(Piece{Type: "flex", Params: PieceParams{Length: 30}}); got != "F30" {
+		t.Errorf("Code() = %s, want F30", got)
+	}
+
+	bad := `{"types": [{"type": "teleporter", "geometry": "wormhole"}]}`
+	if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCatalog(path); err == nil {
+		t.Error("expected error for unknown geometry")
+	}
+}
+
+func TestTrackProjectCatalog(t *testing.T) {
+	custom := DefaultCatalog()
+	custom.setType(ElementType{Type: "flex", Name: "软直道", Geometry: GeometryStraight, CodeFormat: "F{length}"})
+
+	project := &TrackProject{Pieces: []Piece{{Type: "flex", Params: PieceParams{Length: 30}}}}
+	if _, err := CalculateLength(project); err == nil {
+		t.Error("flex pieces should be unknown to the built-in catalogue")
+	}
+
+	project.UseCatalog(custom)
+	length, err := CalculateLength(project)
+	if err != nil || length != 0.3 {
+		t.Errorf("CalculateLength() = %v, %v; want 0.3", length, err)
+	}
+	if bom := GenerateBOM(project); bom.BOM["F30"] != 1 {
+		t.Errorf("BOM = %v, want F30", bom.BOM)
+	}
+
+	// Other projects keep the built-in catalogue
+	if _, ok := (&TrackProject{}).Catalog().Type("flex"); ok {
+		t.Error("the built-in catalogue was changed")
+	}
+}
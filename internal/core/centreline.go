@@ -0,0 +1,169 @@
+package core
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// CentrelineSample is a point on the track centreline
+type CentrelineSample struct {
+	S         float64 `json:"s"` // distance along the track (cm)
+	X         float64 `json:"x"` // cm
+	Y         float64 `json:"y"`
+	Heading   float64 `json:"heading"`   // degrees
+	Curvature float64 `json:"curvature"` // 1/cm, + turning left
+	Piece     int     `json:"piece"`
+	Type      string  `json:"type"`
+}
+
+// Centreline is the track centreline sampled at a fixed arc-length step
+type Centreline struct {
+	Samples []CentrelineSample `json:"samples"`
+	Length  float64            `json:"lengthCm"`
+	StepCm  float64            `json:"stepCm"`
+	Closed  bool               `json:"closed"`
+}
+
+// centrelineRun is one stretch of the driven line: a placed piece, or the
+// straight pass through a crossroads' side ports
+type centrelineRun struct {
+	index int
+	piece Piece
+	entry Pose
+	start float64
+	laid  float64
+}
+
+// SampleCentreline walks the placed pieces in order and samples every
+// stepCm of arc length, plus the very end. Driving through the side ports
+// of a crossroads counts as part of the crossroads.
+func SampleCentreline(project *TrackProject, stepCm float64) (*Centreline, error) {
+	if err := checkScale("step", stepCm); err != nil {
+		return nil, err
+	}
+	if len(project.Pieces) == 0 {
+		return nil, fmt.Errorf("track has no pieces")
+	}
+
+	chain, err := SolvePoses(project, DefaultTolerance)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := project.Catalog()
+	var runs []centrelineRun
+	s := 0.0
+	end := Pose{}
+	for i, piece := range project.Pieces {
+		length, err := catalog.laidLength(piece)
+		if err != nil {
+			return nil, fmt.Errorf("piece %d: %w", i, err)
+		}
+		runs = append(runs, centrelineRun{index: i, piece: piece, entry: chain.Poses[i].Entry, start: s, laid: length})
+		s += length
+
+		end = chain.Poses[i].Exit
+		if crossing, through, ok := catalog.crossingPassedThrough(project.Pieces, chain.Poses, end, DefaultTolerance); ok {
+			across := math.Hypot(through.X-end.X, through.Y-end.Y)
+			runs = append(runs, centrelineRun{
+				index: crossing,
+				piece: Piece{Type: project.Pieces[crossing].Type, Params: PieceParams{Length: across}},
+				entry: end,
+				start: s,
+				laid:  across,
+			})
+			s += across
+			end = through
+		}
+	}
+
+	if s/stepCm > maxCentrelineSamples {
+		return nil, fmt.Errorf("%w: sample step %gcm is too small for a %gcm track", ErrInvalidOption, stepCm, s)
+	}
+
+	c := &Centreline{Length: s, StepCm: stepCm}
+	c.Closed = len(project.Pieces) > 1 && CompareJoint(end, chain.Poses[0].Entry, DefaultTolerance).Connected
+
+	steps := int(math.Ceil(s/stepCm - 1e-9))
+	r := 0
+	for k := 0; k <= steps; k++ {
+		at := math.Min(float64(k)*stepCm, s)
+		for r < len(runs)-1 && at >= runs[r].start+runs[r].laid {
+			r++
+		}
+		run := runs[r]
+		pose, err := catalog.PoseAt(run.entry, run.piece, at-run.start)
+		if err != nil {
+			return nil, fmt.Errorf("piece %d: %w", run.index, err)
+		}
+		c.Samples = append(c.Samples, CentrelineSample{
+			S: at, X: pose.X, Y: pose.Y, Heading: pose.Heading,
+			Curvature: catalog.curvature(run.piece),
+			Piece:     run.index, Type: run.piece.Type,
+		})
+	}
+
+	return c, nil
+}
+
+// maxCentrelineSamples keeps a tiny step on a big track within reason
+const maxCentrelineSamples = 1_000_000
+
+// curvature is the signed curvature of a piece's driven line (1/cm)
+func (c *Catalog) curvature(piece Piece) float64 {
+	if geometry, _ := c.geometryOf(piece.Type); geometry != GeometryArc || piece.Params.Radius <= 0 {
+		return 0
+	}
+	return piece.Params.turnSign() / piece.Params.Radius
+}
+
+// Ahead returns the samples from index i up to distCm further along the
+// track, wrapping around a closed loop
+func (c *Centreline) Ahead(i int, distCm float64) []CentrelineSample {
+	var ahead []CentrelineSample
+	n := len(c.Samples)
+	if c.Closed {
+		// The last sample repeats the first
+		n--
+	}
+	start := c.Samples[i].S
+	for k := 0; k < n; k++ {
+		j := i + k
+		offset := 0.0
+		if j >= n {
+			if !c.Closed {
+				break
+			}
+			j -= n
+			offset = c.Length
+		}
+		sample := c.Samples[j]
+		if sample.S+offset-start > distCm {
+			break
+		}
+		ahead = append(ahead, sample)
+	}
+	return ahead
+}
+
+// WriteCSV writes one row per sample, with a header row
+func (c *Centreline) WriteCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"s", "x", "y", "heading", "curvature", "piece", "type"})
+	for _, sample := range c.Samples {
+		w.Write([]string{
+			num4(sample.S),
+			num4(sample.X),
+			num4(sample.Y),
+			num4(sample.Heading),
+			strconv.FormatFloat(sample.Curvature, 'g', 6, 64),
+			strconv.Itoa(sample.Piece),
+			sample.Type,
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSampleCentreline(t *testing.T) {
+	c, err := SampleCentreline(figureEight(), 5)
+	if err != nil {
+		t.Fatalf("SampleCentreline() error = %v", err)
+	}
+	if !c.Closed {
+		t.Error("expected figure eight to be closed")
+	}
+	last := c.Samples[len(c.Samples)-1]
+	if math.Abs(last.S-c.Length) > 1e-9 {
+		t.Errorf("last sample at %v, want %v", last.S, c.Length)
+	}
+	for i := 1; i < len(c.Samples)-1; i++ {
+		if got := c.Samples[i].S; math.Abs(got-float64(i)*5) > 1e-9 {
+			t.Fatalf("sample %d at s=%v, want %v", i, got, float64(i)*5)
+		}
+		gap := math.Hypot(c.Samples[i].X-c.Samples[i-1].X, c.Samples[i].Y-c.Samples[i-1].Y)
+		if gap > 5+1e-6 {
+			t.Fatalf("samples %d and %d are %vcm apart", i-1, i, gap)
+		}
+	}
+
+	// Left curves bend at +1/50, right ones at -1/50, and the second
+	// pass through the crossroads is sampled as the crossroads
+	crossings := 0
+	for _, sample := range c.Samples {
+		want := 0.0
+		switch {
+		case sample.Piece >= 2 && sample.Piece <= 4:
+			want = 1.0 / 50
+		case sample.Piece >= 7 && sample.Piece <= 9:
+			want = -1.0 / 50
+		}
+		if math.Abs(sample.Curvature-want) > 1e-12 {
+			t.Errorf("sample at s=%v on piece %d: curvature %v, want %v", sample.S, sample.Piece, sample.Curvature, want)
+		}
+		if sample.Piece == 0 && sample.S > 100 {
+			crossings++
+		}
+	}
+	if crossings == 0 {
+		t.Error("expected samples crossing the crossroads a second time")
+	}
+
+	// Looking ahead from near the end wraps round to the start
+	ahead := c.Ahead(len(c.Samples)-3, 30)
+	if got := ahead[len(ahead)-1]; got.S > 30 {
+		t.Errorf("Ahead() did not wrap, last S = %v", got.S)
+	}
+
+	for _, step := range []float64{0, -1, math.NaN(), math.Inf(1), 0.0001} {
+		if _, err := SampleCentreline(figureEight(), step); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("step %g: error = %v, want ErrInvalidOption", step, err)
+		}
+	}
+}
+
+func TestCentreline_WriteCSV(t *testing.T) {
+	project := &TrackProject{
+		Pieces: []Piece{{ID: "a", Type: "curve", Params: PieceParams{Radius: 50, Angle: 90, Direction: "right"}}},
+	}
+	c, err := SampleCentreline(project, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	// 78.54cm sampled at 0, 30, 60 and the end
+	if len(lines) != 5 {
+		t.Fatalf("got %d lines, want 5:\n%s", len(lines), buf.String())
+	}
+	if lines[0] != "s,x,y,heading,curvature,piece,type" {
+		t.Errorf("header = %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[4], "78.5398,") || !strings.HasSuffix(lines[4], ",-0.02,0,curve") {
+		t.Errorf("last row = %q", lines[4])
+	}
+}
@@ -0,0 +1,219 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ClosureOptions controls loop-closure validation
+type ClosureOptions struct {
+	Tolerance      Tolerance `json:"tolerance"`
+	MaxPieces      int       `json:"maxPieces"`      // longest suggestion searched
+	MaxSuggestions int       `json:"maxSuggestions"` // suggestions returned
+}
+
+// DefaultClosureOptions keeps the search fast enough for an HTTP request
+var DefaultClosureOptions = ClosureOptions{
+	Tolerance:      DefaultTolerance,
+	MaxPieces:      3,
+	MaxSuggestions: 5,
+}
+
+// maxClosurePieces caps the search depth; the standard catalogue has 23
+// oriented parts, so every extra level multiplies the work by 23
+const maxClosurePieces = 4
+
+// ClosureSuggestion is a sequence of catalogue pieces that closes the loop
+type ClosureSuggestion struct {
+	Codes        []string `json:"codes"`
+	Pieces       []Piece  `json:"pieces"`
+	Gap          float64  `json:"gap"`
+	HeadingDelta float64  `json:"headingDelta"`
+}
+
+// ClosureReport tells whether a piece sequence forms a closed circuit
+type ClosureReport struct {
+	Closed       bool                `json:"closed"`
+	Start        Pose                `json:"start"`
+	End          Pose                `json:"end"`
+	Gap          float64             `json:"gap"`
+	HeadingDelta float64             `json:"headingDelta"`
+	Suggestions  []ClosureSuggestion `json:"suggestions"`
+}
+
+// ValidateClosure lays the pieces end to end from the first piece's
+// placement and checks that the last exit returns to the start. When it
+// does not, it searches the standard catalogue for short closing sequences.
+func ValidateClosure(project *TrackProject, opts ClosureOptions) (*ClosureReport, error) {
+	if len(project.Pieces) == 0 {
+		return nil, fmt.Errorf("no pieces to validate")
+	}
+	if opts.MaxPieces <= 0 {
+		opts.MaxPieces = DefaultClosureOptions.MaxPieces
+	}
+	if opts.MaxPieces > maxClosurePieces {
+		opts.MaxPieces = maxClosurePieces
+	}
+	if opts.MaxSuggestions <= 0 {
+		opts.MaxSuggestions = DefaultClosureOptions.MaxSuggestions
+	}
+
+	catalog := project.Catalog()
+	start := catalog.PlacementPose(project.Pieces[0])
+	poses, err := catalog.ChainPoses(project.Pieces, start)
+	if err != nil {
+		return nil, err
+	}
+	end := poses[len(poses)-1].Exit
+	if through, ok := catalog.passThroughCrossing(project.Pieces, poses, end, opts.Tolerance); ok {
+		end = through
+	}
+
+	joint := CompareJoint(end, start, opts.Tolerance)
+	report := &ClosureReport{
+		Closed:       joint.Connected,
+		Start:        start,
+		End:          end,
+		Gap:          joint.Gap,
+		HeadingDelta: joint.HeadingDelta,
+		Suggestions:  []ClosureSuggestion{},
+	}
+
+	if !report.Closed {
+		report.Suggestions = catalog.suggestClosure(end, start, opts)
+	}
+
+	return report, nil
+}
+
+// suggestClosure runs a bounded depth-first search over the oriented
+// catalogue, pruning branches that can no longer reach the target
+func (c *Catalog) suggestClosure(from, target Pose, opts ClosureOptions) []ClosureSuggestion {
+	candidates := c.orientedStandardPieces()
+
+	maxReach := 0.0
+	maxTurn := 0.0
+	for _, candidate := range candidates {
+		maxReach = math.Max(maxReach, c.pieceReach(candidate))
+		maxTurn = math.Max(maxTurn, math.Abs(candidate.Params.Angle))
+	}
+
+	var found []ClosureSuggestion
+	path := make([]Piece, 0, opts.MaxPieces)
+
+	var search func(pose Pose, depth int)
+	search = func(pose Pose, depth int) {
+		if depth > 0 {
+			joint := CompareJoint(pose, target, opts.Tolerance)
+			if joint.Connected {
+				found = append(found, c.newClosureSuggestion(path, from, joint))
+				return
+			}
+		}
+
+		remaining := opts.MaxPieces - depth
+		if remaining == 0 {
+			return
+		}
+		dist := math.Hypot(target.X-pose.X, target.Y-pose.Y)
+		turn := math.Abs(normalizeAngle(target.Heading - pose.Heading))
+		if dist > float64(remaining)*maxReach+opts.Tolerance.GapCm ||
+			turn > float64(remaining)*maxTurn+opts.Tolerance.HeadingDeg {
+			return
+		}
+
+		for _, candidate := range candidates {
+			next, err := c.AdvancePose(pose, candidate)
+			if err != nil {
+				continue
+			}
+			path = append(path, candidate)
+			search(next, depth+1)
+			path = path[:len(path)-1]
+		}
+	}
+	search(from, 0)
+
+	// Fewest pieces first, then best fit
+	sort.SliceStable(found, func(i, j int) bool {
+		if len(found[i].Codes) != len(found[j].Codes) {
+			return len(found[i].Codes) < len(found[j].Codes)
+		}
+		return closureError(found[i]) < closureError(found[j])
+	})
+
+	if len(found) > opts.MaxSuggestions {
+		found = found[:opts.MaxSuggestions]
+	}
+	return found
+}
+
+func (c *Catalog) newClosureSuggestion(path []Piece, from Pose, joint Joint) ClosureSuggestion {
+	s := ClosureSuggestion{
+		Codes:        make([]string, 0, len(path)),
+		Pieces:       make([]Piece, 0, len(path)),
+		Gap:          joint.Gap,
+		HeadingDelta: joint.HeadingDelta,
+	}
+
+	entry := from
+	for _, p := range path {
+		placed := c.placePiece(p, entry)
+		s.Pieces = append(s.Pieces, placed)
+		s.Codes = append(s.Codes, c.Code(p))
+		entry, _ = c.AdvancePose(entry, p)
+	}
+	return s
+}
+
+// orientedStandardPieces returns every catalogue part, with curves in
+// both directions
+func (c *Catalog) orientedStandardPieces() []Piece {
+	pieces := make([]Piece, 0, len(c.Pieces)*2)
+	for _, entry := range c.Pieces {
+		// Only plain track parts; special elements are placed deliberately
+		t, ok := c.Type(entry.Type)
+		if !ok || t.Marking || entry.Category == "special" {
+			continue
+		}
+		if t.Geometry != GeometryStraight && t.Geometry != GeometryArc {
+			continue
+		}
+
+		p := entry.Piece()
+		pieces = append(pieces, p)
+		if t.Geometry == GeometryArc {
+			right := p
+			right.Params.Direction = "right"
+			pieces = append(pieces, right)
+		}
+	}
+	return pieces
+}
+
+// placePiece sets X/Y/Rotation the way the editor stores them, so that the
+// piece starts at entry (inverse of PlacementPose)
+func (c *Catalog) placePiece(piece Piece, entry Pose) Piece {
+	rotation := entry.Heading
+	if geometry, _ := c.geometryOf(piece.Type); geometry == GeometryArc {
+		rotation -= 90 * piece.Params.turnSign()
+	}
+	piece.X = entry.X * PxPerCm
+	piece.Y = entry.Y * PxPerCm
+	piece.Rotation = normalizeAngle(rotation)
+	return piece
+}
+
+// pieceReach is the furthest a piece can move its exit from its entry
+func (c *Catalog) pieceReach(piece Piece) float64 {
+	exit, err := c.AdvancePose(Pose{}, piece)
+	if err != nil {
+		return 0
+	}
+	return math.Hypot(exit.X, exit.Y)
+}
+
+func closureError(s ClosureSuggestion) float64 {
+	return s.Gap + math.Abs(s.HeadingDelta)
+}
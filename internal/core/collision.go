@@ -0,0 +1,422 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DefaultTrackWidthCm matches the editor's track width when no skin is set
+const DefaultTrackWidthCm = 45.0
+
+// sweepStepCm is the centreline sampling step used for collision checks
+const sweepStepCm = 5.0
+
+// Limits on what gets swept. A kilometre of track is far more than any lab
+// floor holds; past that, sampling alone would tie up the request.
+// maxSweepPairs bounds the segment pairs the grid would have compared,
+// which stays near linear in the length unless the track is piled up on
+// itself.
+const (
+	maxSweepLengthCm = 100000.0
+	maxSweepSegments = 20000
+	maxSweepPairs    = 10_000_000
+)
+
+// ErrTrackTooLarge is returned for tracks longer than can be swept
+var ErrTrackTooLarge = errors.New("track too large to check")
+
+// Collision is an overlap between two stretches of track on the floor
+type Collision struct {
+	PieceA   int         `json:"pieceA"` // piece or boundary edge index
+	PieceB   int         `json:"pieceB"`
+	IDA      interface{} `json:"idA,omitempty"`
+	IDB      interface{} `json:"idB,omitempty"`
+	X        float64     `json:"x"` // overlap location (cm)
+	Y        float64     `json:"y"`
+	Distance float64     `json:"distance"` // centreline separation (cm)
+	Overlap  float64     `json:"overlap"`  // how far the track bands overlap (cm)
+}
+
+// CollisionReport lists every place where the swept track overlaps itself
+type CollisionReport struct {
+	TrackWidthCm float64     `json:"trackWidthCm"`
+	Clear        bool        `json:"clear"`
+	Collisions   []Collision `json:"collisions"`
+}
+
+// sweepSegment is a short straight chunk of centreline
+type sweepSegment struct {
+	ax, ay, bx, by float64
+	sa, sb         float64 // arc length at both ends
+	piece          int
+}
+
+// DetectCollisions sweeps the centreline (pieces, or the boundary polyline
+// when there are no pieces) by the track width and reports overlaps between
+// stretches that are not neighbours along the track
+func DetectCollisions(project *TrackProject) (*CollisionReport, error) {
+	width := TrackWidth(project)
+
+	segments, total, closed, err := sweepSegments(project)
+	if err != nil {
+		return nil, err
+	}
+
+	// Two points on a curve of radius >= width/2 that are this far apart
+	// along the track are always at least a track width apart, so anything
+	// closer than that is just the track bending, not an overlap
+	minSeparation := math.Pi * width / 2
+
+	// The track crosses itself on purpose at crossroads
+	crossings := crossingZones(project, width)
+
+	grid := newSweepGrid(segments, width)
+
+	if grid.candidates() > maxSweepPairs {
+		return nil, fmt.Errorf("%w: track is piled up on itself", ErrTrackTooLarge)
+	}
+
+	deepest := make(map[[2]int]Collision)
+	for i := 0; i < len(segments); i++ {
+		a := segments[i]
+		if crossings.has(a.piece) {
+			continue
+		}
+		for _, j := range grid.near(i) {
+			b := segments[j]
+			if crossings.has(b.piece) {
+				continue
+			}
+
+			sep := b.sa - a.sb
+			if closed {
+				sep = math.Min(sep, total-b.sb+a.sa)
+			}
+			if sep <= minSeparation {
+				continue
+			}
+
+			dist, px, py := segmentDistance(a, b)
+			if dist >= width || crossings.covers(px, py) {
+				continue
+			}
+
+			key := [2]int{a.piece, b.piece}
+			c := Collision{
+				PieceA:   a.piece,
+				PieceB:   b.piece,
+				X:        px,
+				Y:        py,
+				Distance: dist,
+				Overlap:  width - dist,
+			}
+			if prev, ok := deepest[key]; !ok || c.Overlap > prev.Overlap {
+				deepest[key] = c
+			}
+		}
+	}
+
+	report := &CollisionReport{
+		TrackWidthCm: width,
+		Collisions:   make([]Collision, 0, len(deepest)),
+	}
+	for _, c := range deepest {
+		if len(project.Pieces) > 0 {
+			c.IDA = project.Pieces[c.PieceA].ID
+			c.IDB = project.Pieces[c.PieceB].ID
+		}
+		report.Collisions = append(report.Collisions, c)
+	}
+	sort.Slice(report.Collisions, func(i, j int) bool {
+		ci, cj := report.Collisions[i], report.Collisions[j]
+		if ci.PieceA != cj.PieceA {
+			return ci.PieceA < cj.PieceA
+		}
+		return ci.PieceB < cj.PieceB
+	})
+	report.Clear = len(report.Collisions) == 0
+
+	return report, nil
+}
+
+// sweepGrid buckets segments by the floor cells they pass through, so only
+// segments in neighbouring cells are compared. Cells are twice the track
+// width and segments are sampled every quarter cell, so two segments closer
+// than a track width always have samples in neighbouring cells.
+type sweepGrid struct {
+	cell  float64
+	cells map[[2]int][]int
+	keys  [][][2]int // cells each segment passes through
+}
+
+func newSweepGrid(segments []sweepSegment, width float64) *sweepGrid {
+	g := &sweepGrid{
+		cell:  math.Max(2*width, 2*sweepStepCm),
+		cells: make(map[[2]int][]int),
+		keys:  make([][][2]int, len(segments)),
+	}
+	spacing := g.cell / 4
+	for i, seg := range segments {
+		n := int(math.Ceil(math.Hypot(seg.bx-seg.ax, seg.by-seg.ay) / spacing))
+		seen := make(map[[2]int]bool)
+		for k := 0; k <= n; k++ {
+			t := 0.0
+			if n > 0 {
+				t = float64(k) / float64(n)
+			}
+			key := g.key(seg.ax+(seg.bx-seg.ax)*t, seg.ay+(seg.by-seg.ay)*t)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			g.keys[i] = append(g.keys[i], key)
+			g.cells[key] = append(g.cells[key], i)
+		}
+	}
+	return g
+}
+
+func (g *sweepGrid) key(x, y float64) [2]int {
+	return [2]int{int(math.Floor(x / g.cell)), int(math.Floor(y / g.cell))}
+}
+
+// candidates is how many segment pairs near would offer, counting each
+// pair from both sides: every cell's segments against those in it and its
+// neighbours
+func (g *sweepGrid) candidates() int {
+	n := 0
+	for key, segs := range g.cells {
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				n += len(segs) * len(g.cells[[2]int{key[0] + dx, key[1] + dy}])
+			}
+		}
+	}
+	return n
+}
+
+// near returns the segments after i that share or neighbour one of its
+// cells, in order
+func (g *sweepGrid) near(i int) []int {
+	seen := make(map[int]bool)
+	var out []int
+	for _, key := range g.keys[i] {
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				for _, j := range g.cells[[2]int{key[0] + dx, key[1] + dy}] {
+					if j > i && !seen[j] {
+						seen[j] = true
+						out = append(out, j)
+					}
+				}
+			}
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// crossingZone is the floor area around a crossroads where the crossing
+// tracks are allowed to overlap
+type crossingZone struct {
+	x, y, radius float64
+}
+
+type crossingSet struct {
+	pieces map[int]bool
+	zones  []crossingZone
+}
+
+func crossingZones(project *TrackProject, width float64) crossingSet {
+	set := crossingSet{pieces: map[int]bool{}}
+	catalog := project.Catalog()
+	for i, piece := range project.Pieces {
+		if geometry, _ := catalog.geometryOf(piece.Type); geometry != GeometryCrossing {
+			continue
+		}
+		mid, err := catalog.PoseAt(catalog.PlacementPose(piece), piece, piece.Params.Length/2)
+		if err != nil {
+			continue
+		}
+		set.pieces[i] = true
+		set.zones = append(set.zones, crossingZone{
+			x:      mid.X,
+			y:      mid.Y,
+			radius: piece.Params.Length/2 + width/2,
+		})
+	}
+	return set
+}
+
+func (c crossingSet) has(piece int) bool {
+	return c.pieces[piece]
+}
+
+func (c crossingSet) covers(x, y float64) bool {
+	for _, z := range c.zones {
+		if math.Hypot(x-z.x, y-z.y) <= z.radius {
+			return true
+		}
+	}
+	return false
+}
+
+// TrackWidth returns the skin's track width, or the editor default
+func TrackWidth(project *TrackProject) float64 {
+	if project.Skin != nil && project.Skin.TrackWidthCm > 0 {
+		return project.Skin.TrackWidthCm
+	}
+	return DefaultTrackWidthCm
+}
+
+// sweepSegments breaks the centreline into short segments, returning them
+// with the total arc length and whether the track closes on itself
+func sweepSegments(project *TrackProject) ([]sweepSegment, float64, bool, error) {
+	if len(project.Pieces) == 0 {
+		if project.Boundary == nil {
+			return nil, 0, false, nil
+		}
+		if len(project.Boundary.Points) > maxSweepSegments {
+			return nil, 0, false, fmt.Errorf("%w: %d boundary points, limit %d",
+				ErrTrackTooLarge, len(project.Boundary.Points), maxSweepSegments)
+		}
+		segments, total := boundarySegments(project.Boundary)
+		if !(total <= maxSweepLengthCm) {
+			return nil, 0, false, fmt.Errorf("%w: %.0f m boundary, limit %.0f m",
+				ErrTrackTooLarge, total/100, maxSweepLengthCm/100)
+		}
+		return segments, total, project.Boundary.Closed, nil
+	}
+
+	// Roundabout islands are not swept: the island lane merges into the
+	// main line by design
+	catalog := project.Catalog()
+	lengths := make([]float64, len(project.Pieces))
+	swept := 0.0
+	for i, piece := range project.Pieces {
+		length, err := catalog.laidLength(piece)
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("piece %d: %w", i, err)
+		}
+		lengths[i] = length
+		swept += math.Abs(length)
+	}
+	if !(swept <= maxSweepLengthCm) || len(project.Pieces) > maxSweepSegments {
+		return nil, 0, false, fmt.Errorf("%w: %.0f m of track in %d pieces, limit %.0f m",
+			ErrTrackTooLarge, swept/100, len(project.Pieces), maxSweepLengthCm/100)
+	}
+
+	chain, err := SolvePoses(project, DefaultTolerance)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	var segments []sweepSegment
+	s := 0.0
+	for i, piece := range project.Pieces {
+		length := lengths[i]
+		entry := chain.Poses[i].Entry
+		steps := int(math.Ceil(length / sweepStepCm))
+		if steps < 1 {
+			steps = 1
+		}
+		prev := entry
+		for k := 1; k <= steps; k++ {
+			along := length * float64(k) / float64(steps)
+			next, err := catalog.PoseAt(entry, piece, along)
+			if err != nil {
+				return nil, 0, false, fmt.Errorf("piece %d: %w", i, err)
+			}
+			segments = append(segments, sweepSegment{
+				ax: prev.X, ay: prev.Y, bx: next.X, by: next.Y,
+				sa:    s + length*float64(k-1)/float64(steps),
+				sb:    s + along,
+				piece: i,
+			})
+			prev = next
+		}
+		s += length
+	}
+
+	last := chain.Poses[len(chain.Poses)-1].Exit
+	closed := len(chain.Poses) > 1 && CompareJoint(last, chain.Poses[0].Entry, DefaultTolerance).Connected
+
+	return segments, s, closed, nil
+}
+
+func boundarySegments(boundary *Boundary) ([]sweepSegment, float64) {
+	scale := 1.0
+	if boundary.Unit == "px" {
+		scale = 1 / PxPerCm
+	}
+
+	points := boundary.Points
+	n := len(points) - 1
+	if boundary.Closed && len(points) > 2 {
+		n = len(points)
+	}
+
+	var segments []sweepSegment
+	s := 0.0
+	for i := 0; i < n; i++ {
+		a := points[i]
+		b := points[(i+1)%len(points)]
+		length := math.Hypot(b.X-a.X, b.Y-a.Y) * scale
+		segments = append(segments, sweepSegment{
+			ax: a.X * scale, ay: a.Y * scale, bx: b.X * scale, by: b.Y * scale,
+			sa: s, sb: s + length,
+			piece: i,
+		})
+		s += length
+	}
+
+	return segments, s
+}
+
+// segmentDistance returns the closest distance between two segments and
+// the midpoint of the closest pair of points
+func segmentDistance(a, b sweepSegment) (float64, float64, float64) {
+	d1x, d1y := a.bx-a.ax, a.by-a.ay
+	d2x, d2y := b.bx-b.ax, b.by-b.ay
+	rx, ry := a.ax-b.ax, a.ay-b.ay
+
+	aa := d1x*d1x + d1y*d1y
+	ee := d2x*d2x + d2y*d2y
+	ff := d2x*rx + d2y*ry
+
+	var s, t float64
+	const eps = 1e-9
+	switch {
+	case aa <= eps && ee <= eps:
+		s, t = 0, 0
+	case aa <= eps:
+		s, t = 0, clamp01(ff/ee)
+	default:
+		c := d1x*rx + d1y*ry
+		if ee <= eps {
+			t, s = 0, clamp01(-c/aa)
+		} else {
+			bb := d1x*d2x + d1y*d2y
+			denom := aa*ee - bb*bb
+			if denom > eps {
+				s = clamp01((bb*ff - c*ee) / denom)
+			}
+			t = (bb*s + ff) / ee
+			if t < 0 {
+				t, s = 0, clamp01(-c/aa)
+			} else if t > 1 {
+				t, s = 1, clamp01((bb-c)/aa)
+			}
+		}
+	}
+
+	p1x, p1y := a.ax+d1x*s, a.ay+d1y*s
+	p2x, p2y := b.ax+d2x*t, b.ay+d2y*t
+	return math.Hypot(p1x-p2x, p1y-p2y), (p1x + p2x) / 2, (p1y + p2y) / 2
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,163 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// hairpin builds L200 -> U-turn of the given radius -> L200 back
+func hairpin(radius float64) *TrackProject {
+	pieces := []Piece{
+		{ID: "a", Type: "straight", Params: PieceParams{Length: 200}},
+		{ID: "b", Type: "curve", Params: PieceParams{Radius: radius, Angle: 180}},
+		{ID: "c", Type: "straight", Params: PieceParams{Length: 200}},
+	}
+	poses, _ := defaultCatalog.ChainPoses(pieces, Pose{})
+	for i := range pieces {
+		pieces[i] = defaultCatalog.placePiece(pieces[i], poses[i].Entry)
+	}
+	return &TrackProject{Pieces: pieces}
+}
+
+func TestDetectCollisions(t *testing.T) {
+	report, err := DetectCollisions(hairpin(30))
+	if err != nil {
+		t.Fatalf("DetectCollisions() error = %v", err)
+	}
+	if !report.Clear {
+		t.Errorf("60cm apart should be clear for 45cm track, got %+v", report.Collisions)
+	}
+
+	report, err = DetectCollisions(hairpin(20))
+	if err != nil {
+		t.Fatalf("DetectCollisions() error = %v", err)
+	}
+	if report.Clear {
+		t.Fatal("40cm apart should overlap for 45cm track")
+	}
+	// The 20cm U-turn is also tighter than half the width, so a/b overlap
+	// too; the straights must be reported as a separate collision
+	var c *Collision
+	for i := range report.Collisions {
+		if report.Collisions[i].IDA == "a" && report.Collisions[i].IDB == "c" {
+			c = &report.Collisions[i]
+		}
+	}
+	if c == nil {
+		t.Fatalf("expected collision between a and c, got %+v", report.Collisions)
+	}
+	if !almostEqual(c.Overlap, 5) {
+		t.Errorf("overlap = %v, want 5", c.Overlap)
+	}
+}
+
+func TestDetectCollisions_Boundary(t *testing.T) {
+	// A bow-tie crosses itself in the middle
+	project := &TrackProject{
+		Boundary: &Boundary{
+			Unit: "cm",
+			Points: []Point{
+				{X: 0, Y: 0}, {X: 400, Y: 400}, {X: 400, Y: 0}, {X: 0, Y: 400},
+			},
+			Closed: true,
+		},
+	}
+
+	report, err := DetectCollisions(project)
+	if err != nil {
+		t.Fatalf("DetectCollisions() error = %v", err)
+	}
+	if report.Clear {
+		t.Fatal("expected crossing to be reported")
+	}
+	c := report.Collisions[0]
+	if c.PieceA != 0 || c.PieceB != 2 {
+		t.Errorf("collision between edges %d and %d, want 0 and 2", c.PieceA, c.PieceB)
+	}
+	if !almostEqual(c.X, 200) || !almostEqual(c.Y, 200) {
+		t.Errorf("collision at (%v, %v), want (200, 200)", c.X, c.Y)
+	}
+}
+
+// straights is n straights of length cm placed end to end
+func straights(n int, length float64) *TrackProject {
+	pieces := make([]Piece, n)
+	for i := range pieces {
+		pieces[i] = Piece{ID: i, Type: "straight", Params: PieceParams{Length: length}}
+	}
+	poses, _ := defaultCatalog.ChainPoses(pieces, Pose{})
+	for i := range pieces {
+		pieces[i] = defaultCatalog.placePiece(pieces[i], poses[i].Entry)
+	}
+	return &TrackProject{Pieces: pieces}
+}
+
+func TestDetectCollisions_TooLarge(t *testing.T) {
+	start := time.Now()
+	_, err := DetectCollisions(straights(1001, 100))
+	if !errors.Is(err, ErrTrackTooLarge) {
+		t.Fatalf("1001 m of straights: error = %v, want ErrTrackTooLarge", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("rejecting took %v", elapsed)
+	}
+
+	// Just under the limit is swept, and quickly
+	start = time.Now()
+	report, err := DetectCollisions(straights(900, 100))
+	if err != nil || !report.Clear {
+		t.Fatalf("900 m straight = %+v, %v", report, err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("sweeping 900 m took %v", elapsed)
+	}
+
+	// The same pieces all placed in one spot would compare every pair
+	stacked := straights(900, 100)
+	for i := range stacked.Pieces {
+		stacked.Pieces[i].X, stacked.Pieces[i].Y = 0, 0
+	}
+	start = time.Now()
+	if _, err := DetectCollisions(stacked); !errors.Is(err, ErrTrackTooLarge) {
+		t.Errorf("stacked pieces: error = %v, want ErrTrackTooLarge", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("rejecting stacked pieces took %v", elapsed)
+	}
+}
+
+func TestDetectCollisions_InvalidPieces(t *testing.T) {
+	tests := []struct {
+		name   string
+		pieces []Piece
+	}{
+		// Lengths that cancel out must not slip under the total
+		{"cancelling straights", []Piece{
+			{Type: "straight", Params: PieceParams{Length: -5000000}},
+			{Type: "straight", Params: PieceParams{Length: 5000000}},
+		}},
+		{"negative straight", []Piece{{Type: "straight", Params: PieceParams{Length: -50}}}},
+		{"zero straight", []Piece{{Type: "straight"}}},
+		{"NaN straight", []Piece{{Type: "straight", Params: PieceParams{Length: math.NaN()}}}},
+		{"infinite radius", []Piece{{Type: "curve", Params: PieceParams{Radius: math.Inf(1), Angle: 90}}}},
+		{"negative radius", []Piece{{Type: "curve", Params: PieceParams{Radius: -50, Angle: 90}}}},
+		{"huge arc", []Piece{{Type: "curve", Params: PieceParams{Radius: 5000, Angle: 36000}}}},
+		{"long piece", []Piece{{Type: "straight", Params: PieceParams{Length: 20000}}}},
+		{"negative roundabout", []Piece{{Type: "roundabout", Params: PieceParams{Length: -100, Radius: 50}}}},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		_, err := DetectCollisions(&TrackProject{Pieces: tt.pieces})
+		if !errors.Is(err, ErrInvalidPiece) {
+			t.Errorf("%s: error = %v, want ErrInvalidPiece", tt.name, err)
+		}
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("%s: rejecting took %v", tt.name, elapsed)
+		}
+		if _, err := SampleCentreline(&TrackProject{Pieces: tt.pieces}, 1); !errors.Is(err, ErrInvalidPiece) {
+			t.Errorf("%s: centreline error = %v, want ErrInvalidPiece", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+)
+
+// DatasetOptions controls synthetic frame generation
+type DatasetOptions struct {
+	Camera      CameraOptions
+	Frames      int     // number of frames, spread evenly along the track
+	MaxOffsetCm float64 // random lateral offset from the centreline, ±
+	MaxYawDeg   float64 // random heading error, ±
+	LookaheadCm float64 // how far ahead labels look, default 200
+	Seed        int64
+}
+
+// FrameLabel is the ground truth for one frame
+type FrameLabel struct {
+	Index   int     `json:"index"`
+	Image   string  `json:"image,omitempty"` // file name, filled in by the writer
+	S       float64 `json:"s"`               // distance along the track (cm)
+	Piece   int     `json:"piece"`
+	X       float64 `json:"x"` // camera position on the floor (cm)
+	Y       float64 `json:"y"`
+	Heading float64 `json:"heading"`
+
+	LateralOffsetCm float64 `json:"lateralOffsetCm"` // + is left of the centreline
+	HeadingErrorDeg float64 `json:"headingErrorDeg"` // + is turned left of the track
+
+	// First special element (crossroads, zebra, ...) within the lookahead
+	ElementAhead      string  `json:"elementAhead,omitempty"`
+	ElementDistanceCm float64 `json:"elementDistanceCm,omitempty"`
+
+	// Visible centreline ahead, nearest first, in pixel coordinates
+	Centreline [][2]float64 `json:"centreline"`
+}
+
+// Frame is one rendered camera image and its labels
+type Frame struct {
+	Image *image.RGBA
+	Label FrameLabel
+}
+
+// GenerateFrames places the camera at poses spread along the centreline,
+// jittered sideways and in heading, and hands each rendered frame to emit
+func GenerateFrames(project *TrackProject, tex *TrackTexture, opts DatasetOptions, emit func(Frame) error) error {
+	if opts.Frames <= 0 {
+		return fmt.Errorf("frame count must be positive")
+	}
+	if opts.LookaheadCm <= 0 {
+		opts.LookaheadCm = 200
+	}
+
+	centreline, err := SampleCentreline(project, 1)
+	if err != nil {
+		return err
+	}
+	samples := centreline.Samples
+	catalog := project.Catalog()
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	for n := 0; n < opts.Frames; n++ {
+		// Evenly spaced along the track, never on the closing sample of a loop
+		i := int(float64(n) * float64(len(samples)-1) / float64(opts.Frames))
+		at := samples[i]
+
+		offset := (rng.Float64()*2 - 1) * opts.MaxOffsetCm
+		yaw := (rng.Float64()*2 - 1) * opts.MaxYawDeg
+		h := at.Heading * math.Pi / 180
+		pose := Pose{
+			X:       at.X - math.Sin(h)*offset,
+			Y:       at.Y + math.Cos(h)*offset,
+			Heading: normalizeAngle(at.Heading + yaw),
+		}
+		cam := NewCamera(opts.Camera, pose)
+
+		label := FrameLabel{
+			Index:           n,
+			S:               at.S,
+			Piece:           at.Piece,
+			X:               pose.X,
+			Y:               pose.Y,
+			Heading:         pose.Heading,
+			LateralOffsetCm: offset,
+			HeadingErrorDeg: yaw,
+			Centreline:      [][2]float64{},
+		}
+
+		for _, ahead := range centreline.Ahead(i, opts.LookaheadCm) {
+			if label.ElementAhead == "" && isSpecialElement(catalog, ahead.Type) {
+				label.ElementAhead = ahead.Type
+				label.ElementDistanceCm = math.Mod(ahead.S-at.S+centreline.Length, centreline.Length)
+			}
+			if u, v, ok := cam.Project(ahead.X, ahead.Y); ok {
+				label.Centreline = append(label.Centreline, [2]float64{roundParam(u), roundParam(v)})
+			}
+		}
+
+		if err := emit(Frame{Image: cam.Render(tex), Label: label}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isSpecialElement reports whether a piece type is anything but plain
+// straight or curved track
+func isSpecialElement(catalog *Catalog, pieceType string) bool {
+	if pieceType == "straight" || pieceType == "curve" {
+		return false
+	}
+	_, ok := catalog.Type(pieceType)
+	return ok
+}
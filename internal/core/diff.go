@@ -0,0 +1,129 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// PieceChange is a piece in both versions whose part or placement changed
+type PieceChange struct {
+	ID     interface{} `json:"id"`
+	Before Piece       `json:"before"`
+	After  Piece       `json:"after"`
+	Fields []string    `json:"fields"` // "type", "params", "position", "rotation"
+}
+
+// TrackDiff compares two versions of a track piece by piece
+type TrackDiff struct {
+	Added     []Piece       `json:"added"`
+	Removed   []Piece       `json:"removed"`
+	Changed   []PieceChange `json:"changed"`
+	Unchanged int           `json:"unchanged"`
+	// Track-level fields that changed: "name", "description", "tags",
+	// "boundary", "skin"
+	Metadata []string `json:"metadata"`
+}
+
+// Empty reports whether the versions have the same pieces and metadata
+func (d *TrackDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.Metadata) == 0
+}
+
+// diffEpsilon ignores float noise from re-serialising positions
+const diffEpsilon = 1e-6
+
+// DiffTracks compares from with to. Pieces are matched by ID, or by index
+// for pieces without one; Added and Changed follow to's order, Removed
+// follows from's.
+func DiffTracks(from, to *TrackProject) *TrackDiff {
+	diff := &TrackDiff{
+		Added:    []Piece{},
+		Removed:  []Piece{},
+		Changed:  []PieceChange{},
+		Metadata: []string{},
+	}
+
+	if from.Name != to.Name {
+		diff.Metadata = append(diff.Metadata, "name")
+	}
+	if from.Description != to.Description {
+		diff.Metadata = append(diff.Metadata, "description")
+	}
+	if !reflect.DeepEqual(from.Tags, to.Tags) && (len(from.Tags) > 0 || len(to.Tags) > 0) {
+		diff.Metadata = append(diff.Metadata, "tags")
+	}
+	if !reflect.DeepEqual(from.Boundary, to.Boundary) {
+		diff.Metadata = append(diff.Metadata, "boundary")
+	}
+	if !reflect.DeepEqual(from.Skin, to.Skin) {
+		diff.Metadata = append(diff.Metadata, "skin")
+	}
+
+	before := map[string]Piece{}
+	fromKeys := pieceKeys(from.Pieces)
+	for i, key := range fromKeys {
+		before[key] = from.Pieces[i]
+	}
+
+	matched := map[string]bool{}
+	for i, key := range pieceKeys(to.Pieces) {
+		after := to.Pieces[i]
+		old, ok := before[key]
+		if !ok {
+			diff.Added = append(diff.Added, after)
+			continue
+		}
+		matched[key] = true
+
+		fields := changedPieceFields(old, after)
+		if len(fields) == 0 {
+			diff.Unchanged++
+			continue
+		}
+		diff.Changed = append(diff.Changed, PieceChange{ID: after.ID, Before: old, After: after, Fields: fields})
+	}
+	for i, key := range fromKeys {
+		if !matched[key] {
+			diff.Removed = append(diff.Removed, from.Pieces[i])
+		}
+	}
+
+	return diff
+}
+
+// pieceKeys identifies pieces across versions. IDs may be numbers or
+// strings (1 and "1" are the same piece); a repeated ID gets a counter.
+func pieceKeys(pieces []Piece) []string {
+	keys := make([]string, len(pieces))
+	seen := map[string]int{}
+	for i, p := range pieces {
+		key := fmt.Sprintf("#%d", i)
+		if p.ID != nil {
+			key = fmt.Sprint(p.ID)
+		}
+		seen[key]++
+		if n := seen[key]; n > 1 {
+			key = fmt.Sprintf("%s~%d", key, n)
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+func changedPieceFields(a, b Piece) []string {
+	var fields []string
+	if a.Type != b.Type {
+		fields = append(fields, "type")
+	}
+	if a.Params != b.Params {
+		fields = append(fields, "params")
+	}
+	if math.Abs(a.X-b.X) > diffEpsilon || math.Abs(a.Y-b.Y) > diffEpsilon {
+		fields = append(fields, "position")
+	}
+	if math.Abs(a.Rotation-b.Rotation) > diffEpsilon {
+		fields = append(fields, "rotation")
+	}
+	return fields
+}
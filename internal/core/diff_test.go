@@ -0,0 +1,57 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffTracks(t *testing.T) {
+	from := &TrackProject{
+		Name: "Oval",
+		Pieces: []Piece{
+			{ID: 1, Type: "straight", Params: PieceParams{Length: 50}},
+			{ID: 2, Type: "curve", Params: PieceParams{Radius: 50, Angle: 90}, X: 50},
+			{ID: 3, Type: "straight", Params: PieceParams{Length: 50}, X: 100, Y: 50, Rotation: 90},
+		},
+	}
+	to := &TrackProject{
+		Name: "Oval v2",
+		Pieces: []Piece{
+			// IDs come back as float64 or strings after a JSON round trip
+			{ID: 1.0, Type: "straight", Params: PieceParams{Length: 50}},
+			{ID: "2", Type: "curve", Params: PieceParams{Radius: 50, Angle: 45}, X: 50},
+			{ID: 4, Type: "straight", Params: PieceParams{Length: 25}, X: 90},
+		},
+	}
+
+	diff := DiffTracks(from, to)
+	if diff.Unchanged != 1 {
+		t.Errorf("unchanged = %d, want 1", diff.Unchanged)
+	}
+	if len(diff.Changed) != 1 || !reflect.DeepEqual(diff.Changed[0].Fields, []string{"params"}) {
+		t.Errorf("changed = %+v", diff.Changed)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].ID != 4 {
+		t.Errorf("added = %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].ID != 3 {
+		t.Errorf("removed = %+v", diff.Removed)
+	}
+	if !reflect.DeepEqual(diff.Metadata, []string{"name"}) {
+		t.Errorf("metadata = %v", diff.Metadata)
+	}
+
+	if same := DiffTracks(from, from); !same.Empty() || same.Unchanged != 3 {
+		t.Errorf("diff with itself = %+v", same)
+	}
+}
+
+func TestDiffTracks_MovedPiece(t *testing.T) {
+	from := &TrackProject{Pieces: []Piece{{ID: "a", Type: "straight", Params: PieceParams{Length: 50}}}}
+	to := &TrackProject{Pieces: []Piece{{ID: "a", Type: "straight", Params: PieceParams{Length: 50}, X: 10, Rotation: 90}}}
+
+	diff := DiffTracks(from, to)
+	if len(diff.Changed) != 1 || !reflect.DeepEqual(diff.Changed[0].Fields, []string{"position", "rotation"}) {
+		t.Errorf("changed = %+v", diff.Changed)
+	}
+}
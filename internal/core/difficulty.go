@@ -0,0 +1,103 @@
+package core
+
+import (
+	"math"
+)
+
+// Difficulty levels, matching the library's self-declared tags
+const (
+	LevelBeginner     = "beginner"
+	LevelIntermediate = "intermediate"
+	LevelAdvanced     = "advanced"
+)
+
+// Difficulty is an objective rating from the track geometry. Score runs
+// from 0 (a plain oval) to 100.
+type Difficulty struct {
+	Score           float64 `json:"score"`
+	Level           string  `json:"level"`
+	MinRadiusCm     float64 `json:"minRadiusCm,omitempty"` // tightest curve, 0 if none
+	CurveChangesPM  float64 `json:"curveChangesPerM"`      // curvature changes per metre
+	SpecialElements int     `json:"specialElements"`
+	StraightRatio   float64 `json:"straightRatio"` // share of the length that's straight
+}
+
+// How each factor maps onto 0..1 before weighting
+const (
+	easyRadiusCm   = 120 // curves this wide or wider add nothing
+	hardRadiusCm   = 30  // this tight or tighter counts fully
+	hardChangesPM  = 1.5
+	hardSpecialCnt = 6
+)
+
+// ComputeDifficulty rates a track by its tightest radius, how often the
+// curvature changes per metre, the number of special elements and how
+// little of it is straight
+func ComputeDifficulty(project *TrackProject) (*Difficulty, error) {
+	centreline, err := SampleCentreline(project, lapStepCm)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Difficulty{}
+	straight, changes := 0.0, 0
+	for i, s := range centreline.Samples {
+		if s.Curvature != 0 {
+			if r := 1 / math.Abs(s.Curvature); d.MinRadiusCm == 0 || r < d.MinRadiusCm {
+				d.MinRadiusCm = r
+			}
+		}
+		if i == 0 {
+			continue
+		}
+		prev := centreline.Samples[i-1]
+		if prev.Curvature == 0 {
+			straight += s.S - prev.S
+		}
+		if s.Curvature != prev.Curvature {
+			changes++
+		}
+	}
+	// A loop also changes curvature where it closes
+	if last := centreline.Samples[len(centreline.Samples)-1]; centreline.Closed && last.Curvature != centreline.Samples[0].Curvature {
+		changes++
+	}
+
+	catalog := project.Catalog()
+	for _, piece := range project.Pieces {
+		if isSpecialElement(catalog, piece.Type) {
+			d.SpecialElements++
+		}
+	}
+
+	if centreline.Length > 0 {
+		d.StraightRatio = roundTo(straight/centreline.Length, 3)
+		d.CurveChangesPM = roundTo(float64(changes)/(centreline.Length/100), 3)
+	}
+	d.MinRadiusCm = roundTo(d.MinRadiusCm, 1)
+
+	radius := 0.0
+	if d.MinRadiusCm > 0 {
+		radius = clamp01((easyRadiusCm - d.MinRadiusCm) / (easyRadiusCm - hardRadiusCm))
+	}
+	score := 0.35*radius +
+		0.25*clamp01(d.CurveChangesPM/hardChangesPM) +
+		0.2*clamp01(float64(d.SpecialElements)/hardSpecialCnt) +
+		0.2*(1-d.StraightRatio)
+	d.Score = roundTo(score*100, 1)
+	d.Level = DifficultyLevel(d.Score)
+
+	return d, nil
+}
+
+// DifficultyLevel buckets a score into beginner, intermediate or advanced
+func DifficultyLevel(score float64) string {
+	switch {
+	case score < 35:
+		return LevelBeginner
+	case score < 65:
+		return LevelIntermediate
+	default:
+		return LevelAdvanced
+	}
+}
@@ -0,0 +1,46 @@
+package core
+
+import "testing"
+
+func TestComputeDifficulty(t *testing.T) {
+	straight := &TrackProject{
+		Pieces: []Piece{{ID: "a", Type: "straight", Params: PieceParams{Length: 200}}},
+	}
+	easy, err := ComputeDifficulty(straight)
+	if err != nil {
+		t.Fatalf("ComputeDifficulty() error = %v", err)
+	}
+	if easy.Score != 0 || easy.Level != LevelBeginner || easy.StraightRatio != 1 || easy.MinRadiusCm != 0 {
+		t.Errorf("straight = %+v, want score 0", easy)
+	}
+
+	hard, err := ComputeDifficulty(figureEight())
+	if err != nil {
+		t.Fatalf("ComputeDifficulty() error = %v", err)
+	}
+	if hard.MinRadiusCm != 50 || hard.SpecialElements != 1 {
+		t.Errorf("figure eight = %+v, want r=50 and one crossroads", hard)
+	}
+	// straight → left bend → straight → right bend → straight → (crossroads) → back
+	if hard.CurveChangesPM <= 0 || hard.StraightRatio <= 0 || hard.StraightRatio >= 1 {
+		t.Errorf("figure eight = %+v", hard)
+	}
+	if hard.Score <= easy.Score || hard.Score > 100 {
+		t.Errorf("figure eight score %v should be above the straight's", hard.Score)
+	}
+
+	// Tighter curves are harder
+	tight := figureEight()
+	for i := range tight.Pieces {
+		if tight.Pieces[i].Type == "curve" {
+			tight.Pieces[i].Params.Radius = 30
+		}
+	}
+	tighter, err := ComputeDifficulty(tight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tighter.Score <= hard.Score {
+		t.Errorf("r=30 score %v should beat r=50 score %v", tighter.Score, hard.Score)
+	}
+}
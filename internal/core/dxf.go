@@ -0,0 +1,538 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DXF layer suffixes for geometry that isn't a piece's centreline
+const (
+	dxfEdgeSuffix   = "_EDGE"
+	dxfLaneSuffix   = "_LANE"   // crossroads crossing lane
+	dxfIslandSuffix = "_ISLAND" // roundabout island
+)
+
+// dxfJoinTolCm is how close two entity ends must be to be chained on import
+const dxfJoinTolCm = 0.5
+
+// RenderDXF writes the track as an R12 ASCII DXF in centimetres. Each piece
+// type gets its own layer with the centreline as LINE/ARC entities, and
+// both track edges go on a matching _EDGE layer.
+func RenderDXF(out io.Writer, project *TrackProject) error {
+	d := &dxfWriter{layers: map[string]int{}}
+	half := TrackWidth(project) / 2
+	catalog := project.Catalog()
+
+	if len(project.Pieces) == 0 {
+		shapes, err := TrackShapes(project)
+		if err != nil {
+			return err
+		}
+		for _, s := range shapes {
+			d.polyline("BOUNDARY", s.Centreline, s.Closed)
+			d.polyline("BOUNDARY"+dxfEdgeSuffix, s.LeftEdge, s.Closed)
+			d.polyline("BOUNDARY"+dxfEdgeSuffix, s.RightEdge, s.Closed)
+		}
+	}
+
+	for i, piece := range project.Pieces {
+		layer := strings.ToUpper(piece.Type)
+		entry := catalog.PlacementPose(piece)
+		geometry, err := catalog.geometryOf(piece.Type)
+		if err != nil {
+			return fmt.Errorf("piece %d: %w", i, err)
+		}
+
+		if geometry == GeometryArc {
+			sign := piece.Params.turnSign()
+			r := piece.Params.Radius
+			h := entry.Heading * math.Pi / 180.0
+			cx := entry.X - sign*r*math.Sin(h)
+			cy := entry.Y + sign*r*math.Cos(h)
+
+			// DXF arcs always run counter-clockwise from start to end
+			startAngle := entry.Heading - 90*sign
+			endAngle := startAngle + math.Abs(piece.Params.Angle)*sign
+			if sign < 0 {
+				startAngle, endAngle = endAngle, startAngle
+			}
+
+			d.arc(layer, cx, cy, r, startAngle, endAngle)
+			d.arc(layer+dxfEdgeSuffix, cx, cy, r+half, startAngle, endAngle)
+			if r > half {
+				d.arc(layer+dxfEdgeSuffix, cx, cy, r-half, startAngle, endAngle)
+			}
+			continue
+		}
+
+		length, err := catalog.laidLength(piece)
+		if err != nil {
+			return fmt.Errorf("piece %d: %w", i, err)
+		}
+		band, err := catalog.sweepShape(entry, piece, length, half)
+		if err != nil {
+			return fmt.Errorf("piece %d: %w", i, err)
+		}
+		d.polyline(layer, band.Centreline, false)
+		d.polyline(layer+dxfEdgeSuffix, band.LeftEdge, false)
+		d.polyline(layer+dxfEdgeSuffix, band.RightEdge, false)
+
+		switch geometry {
+		case GeometryCrossing:
+			ports, err := catalog.PiecePorts(entry, piece)
+			if err != nil {
+				return fmt.Errorf("piece %d: %w", i, err)
+			}
+			left, right := ports[2].Pose, ports[3].Pose
+			d.line(layer+dxfLaneSuffix, right.X, right.Y, left.X, left.Y)
+		case GeometryRoundabout:
+			island := catalog.islandShape(i, entry, piece, half)
+			c := island.Centreline
+			cx := (c[0].X + c[len(c)/2].X) / 2
+			cy := (c[0].Y + c[len(c)/2].Y) / 2
+			d.circle(layer+dxfIslandSuffix, cx, cy, piece.Params.Radius)
+		}
+	}
+
+	return d.flush(out)
+}
+
+type dxfWriter struct {
+	entities bytes.Buffer
+	layers   map[string]int
+}
+
+func (d *dxfWriter) useLayer(name string) {
+	if _, ok := d.layers[name]; ok {
+		return
+	}
+	// Edges grey, everything else cycles through the basic ACI colours
+	color := 8
+	if !strings.HasSuffix(name, dxfEdgeSuffix) {
+		color = 1 + len(d.layers)%6
+	}
+	d.layers[name] = color
+}
+
+func (d *dxfWriter) pair(code int, value string) {
+	fmt.Fprintf(&d.entities, "%d\n%s\n", code, value)
+}
+
+func (d *dxfWriter) line(layer string, x1, y1, x2, y2 float64) {
+	d.useLayer(layer)
+	d.pair(0, "LINE")
+	d.pair(8, layer)
+	d.pair(10, dxfNum(x1))
+	d.pair(20, dxfNum(y1))
+	d.pair(30, "0")
+	d.pair(11, dxfNum(x2))
+	d.pair(21, dxfNum(y2))
+	d.pair(31, "0")
+}
+
+func (d *dxfWriter) arc(layer string, cx, cy, r, start, end float64) {
+	d.useLayer(layer)
+	d.pair(0, "ARC")
+	d.pair(8, layer)
+	d.pair(10, dxfNum(cx))
+	d.pair(20, dxfNum(cy))
+	d.pair(30, "0")
+	d.pair(40, dxfNum(r))
+	d.pair(50, dxfNum(math.Mod(start+360, 360)))
+	d.pair(51, dxfNum(math.Mod(end+360, 360)))
+}
+
+func (d *dxfWriter) circle(layer string, cx, cy, r float64) {
+	d.useLayer(layer)
+	d.pair(0, "CIRCLE")
+	d.pair(8, layer)
+	d.pair(10, dxfNum(cx))
+	d.pair(20, dxfNum(cy))
+	d.pair(30, "0")
+	d.pair(40, dxfNum(r))
+}
+
+func (d *dxfWriter) polyline(layer string, points []Point, closed bool) {
+	for i := 1; i < len(points); i++ {
+		d.line(layer, points[i-1].X, points[i-1].Y, points[i].X, points[i].Y)
+	}
+	if closed && len(points) > 2 {
+		last := points[len(points)-1]
+		d.line(layer, last.X, last.Y, points[0].X, points[0].Y)
+	}
+}
+
+func (d *dxfWriter) flush(out io.Writer) error {
+	w := bufio.NewWriter(out)
+	p := func(code int, value string) {
+		fmt.Fprintf(w, "%d\n%s\n", code, value)
+	}
+
+	p(0, "SECTION")
+	p(2, "HEADER")
+	p(9, "$ACADVER")
+	p(1, "AC1009")
+	p(9, "$INSUNITS")
+	p(70, "5") // centimetres
+	p(0, "ENDSEC")
+
+	names := make([]string, 0, len(d.layers))
+	for name := range d.layers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	p(0, "SECTION")
+	p(2, "TABLES")
+	p(0, "TABLE")
+	p(2, "LAYER")
+	p(70, strconv.Itoa(len(names)))
+	for _, name := range names {
+		p(0, "LAYER")
+		p(2, name)
+		p(70, "0")
+		p(62, strconv.Itoa(d.layers[name]))
+		p(6, "CONTINUOUS")
+	}
+	p(0, "ENDTAB")
+	p(0, "ENDSEC")
+
+	p(0, "SECTION")
+	p(2, "ENTITIES")
+	w.Write(d.entities.Bytes())
+	p(0, "ENDSEC")
+	p(0, "EOF")
+
+	return w.Flush()
+}
+
+func dxfNum(v float64) string {
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
+
+// dxfEntity is a LINE, ARC or CIRCLE read from a DXF file
+type dxfEntity struct {
+	kind   string
+	layer  string
+	x1, y1 float64 // LINE start, ARC/CIRCLE centre
+	x2, y2 float64 // LINE end
+	r      float64
+	a0, a1 float64 // ARC start/end angle (degrees, CCW)
+}
+
+// ImportDXF reads LINE and ARC entities back into pieces. Entities are
+// chained end to end into a sequence; a layer named after a known piece
+// type sets the type, otherwise lines become straights and arcs curves.
+// Edge, lane and island layers written by RenderDXF are skipped, except
+// that a roundabout's island circle restores its radius. Layer names are
+// looked up in catalog, which the project then uses.
+func ImportDXF(data []byte, catalog *Catalog) (*TrackProject, error) {
+	entities, err := parseDXFEntities(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []dxfSegment
+	var islands []dxfEntity
+
+	for _, e := range entities {
+		upper := strings.ToUpper(e.layer)
+		if strings.HasSuffix(upper, dxfIslandSuffix) && e.kind == "CIRCLE" {
+			islands = append(islands, e)
+			continue
+		}
+		if strings.HasSuffix(upper, dxfEdgeSuffix) || strings.HasSuffix(upper, dxfLaneSuffix) || strings.HasSuffix(upper, dxfIslandSuffix) {
+			continue
+		}
+
+		pieceType := strings.ToLower(e.layer)
+		t, ok := catalog.Type(pieceType)
+		switch e.kind {
+		case "LINE":
+			if !ok || t.Geometry == GeometryArc {
+				pieceType = "straight"
+			}
+			segments = append(segments, newLineSegment(e, pieceType))
+		case "ARC":
+			if !ok || t.Geometry != GeometryArc {
+				pieceType = "curve"
+			}
+			segments = append(segments, newArcSegment(e, pieceType))
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("no LINE or ARC entities found")
+	}
+
+	chains := chainSegments(segments)
+
+	project := &TrackProject{
+		Name:      "Imported DXF",
+		Version:   "1.0",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		catalog:   catalog,
+	}
+	id := 1
+	for _, chain := range chains {
+		for _, seg := range chain {
+			piece := seg.piece(catalog, islands)
+			piece.ID = id
+			id++
+			project.Pieces = append(project.Pieces, piece)
+		}
+	}
+
+	return project, nil
+}
+
+// dxfSegment is a centreline entity oriented in the direction of travel
+type dxfSegment struct {
+	entity     dxfEntity
+	pieceType  string
+	start, end Point
+	reversed   bool
+}
+
+func newLineSegment(e dxfEntity, pieceType string) dxfSegment {
+	return dxfSegment{
+		entity:    e,
+		pieceType: pieceType,
+		start:     Point{X: e.x1, Y: e.y1},
+		end:       Point{X: e.x2, Y: e.y2},
+	}
+}
+
+func newArcSegment(e dxfEntity, pieceType string) dxfSegment {
+	a0, a1 := e.a0*math.Pi/180, e.a1*math.Pi/180
+	return dxfSegment{
+		entity:    e,
+		pieceType: pieceType,
+		start:     Point{X: e.x1 + e.r*math.Cos(a0), Y: e.y1 + e.r*math.Sin(a0)},
+		end:       Point{X: e.x1 + e.r*math.Cos(a1), Y: e.y1 + e.r*math.Sin(a1)},
+	}
+}
+
+func (s dxfSegment) flip() dxfSegment {
+	s.start, s.end = s.end, s.start
+	s.reversed = !s.reversed
+	return s
+}
+
+// heading of travel at the start of the segment
+func (s dxfSegment) heading() float64 {
+	if s.entity.kind == "LINE" {
+		return math.Atan2(s.end.Y-s.start.Y, s.end.X-s.start.X) * 180 / math.Pi
+	}
+	// Tangent of a CCW arc is the radial angle + 90; reversed arcs run CW
+	if s.reversed {
+		return normalizeAngle(s.entity.a1 - 90)
+	}
+	return normalizeAngle(s.entity.a0 + 90)
+}
+
+func (s dxfSegment) piece(catalog *Catalog, islands []dxfEntity) Piece {
+	entry := Pose{X: s.start.X, Y: s.start.Y, Heading: s.heading()}
+	piece := Piece{Type: s.pieceType}
+
+	if s.entity.kind == "ARC" {
+		span := math.Mod(s.entity.a1-s.entity.a0+360, 360)
+		if span == 0 {
+			span = 360
+		}
+		piece.Params = PieceParams{Radius: s.entity.r, Angle: roundParam(span)}
+		piece.Params.Radius = roundParam(piece.Params.Radius)
+		if s.reversed {
+			piece.Params.Direction = "right"
+		}
+		return catalog.placePiece(piece, entry)
+	}
+
+	length := math.Hypot(s.end.X-s.start.X, s.end.Y-s.start.Y)
+	piece.Params = PieceParams{Length: roundParam(length)}
+
+	if geometry, _ := catalog.geometryOf(s.pieceType); geometry == GeometryRoundabout {
+		mid := Point{X: (s.start.X + s.end.X) / 2, Y: (s.start.Y + s.end.Y) / 2}
+		for _, island := range islands {
+			d := math.Hypot(island.x1-mid.X, island.y1-mid.Y)
+			if math.Abs(d-island.r) > dxfJoinTolCm {
+				continue
+			}
+			piece.Params.Radius = roundParam(island.r)
+			// Island on the right of the direction of travel?
+			cross := (s.end.X-s.start.X)*(island.y1-s.start.Y) - (s.end.Y-s.start.Y)*(island.x1-s.start.X)
+			if cross < 0 {
+				piece.Params.Direction = "right"
+			}
+			break
+		}
+	}
+
+	return catalog.placePiece(piece, entry)
+}
+
+// chainSegments links segments end to end, flipping them as needed. Each
+// chain is extended forwards and then backwards from its first segment.
+func chainSegments(segments []dxfSegment) [][]dxfSegment {
+	used := make([]bool, len(segments))
+	var chains [][]dxfSegment
+
+	near := func(a, b Point) bool {
+		return math.Hypot(a.X-b.X, a.Y-b.Y) <= dxfJoinTolCm
+	}
+
+	for i := range segments {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		chain := []dxfSegment{segments[i]}
+
+		// forwards
+		for {
+			tail := chain[len(chain)-1].end
+			found := false
+			for j := range segments {
+				if used[j] {
+					continue
+				}
+				if near(segments[j].start, tail) {
+					chain = append(chain, segments[j])
+				} else if near(segments[j].end, tail) {
+					chain = append(chain, segments[j].flip())
+				} else {
+					continue
+				}
+				used[j] = true
+				found = true
+				break
+			}
+			if !found {
+				break
+			}
+		}
+
+		// backwards
+		for {
+			head := chain[0].start
+			found := false
+			for j := range segments {
+				if used[j] {
+					continue
+				}
+				if near(segments[j].end, head) {
+					chain = append([]dxfSegment{segments[j]}, chain...)
+				} else if near(segments[j].start, head) {
+					chain = append([]dxfSegment{segments[j].flip()}, chain...)
+				} else {
+					continue
+				}
+				used[j] = true
+				found = true
+				break
+			}
+			if !found {
+				break
+			}
+		}
+
+		chains = append(chains, chain)
+	}
+
+	return chains
+}
+
+// parseDXFEntities reads the ENTITIES section of an ASCII DXF
+func parseDXFEntities(data []byte) ([]dxfEntity, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	type pair struct {
+		code  int
+		value string
+	}
+	var pairs []pair
+	for scanner.Scan() {
+		codeLine := strings.TrimSpace(scanner.Text())
+		if !scanner.Scan() {
+			break
+		}
+		code, err := strconv.Atoi(codeLine)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DXF group code %q", codeLine)
+		}
+		pairs = append(pairs, pair{code: code, value: strings.TrimSpace(scanner.Text())})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var entities []dxfEntity
+	inEntities := false
+	var current *dxfEntity
+
+	flush := func() {
+		if current != nil {
+			entities = append(entities, *current)
+			current = nil
+		}
+	}
+
+	for i, p := range pairs {
+		if p.code == 0 {
+			flush()
+			switch p.value {
+			case "SECTION":
+				if i+1 < len(pairs) && pairs[i+1].code == 2 {
+					inEntities = pairs[i+1].value == "ENTITIES"
+				}
+			case "ENDSEC":
+				inEntities = false
+			case "LINE", "ARC", "CIRCLE":
+				if inEntities {
+					current = &dxfEntity{kind: p.value, layer: "0"}
+				}
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		f, _ := strconv.ParseFloat(p.value, 64)
+		switch p.code {
+		case 8:
+			current.layer = p.value
+		case 10:
+			current.x1 = f
+		case 20:
+			current.y1 = f
+		case 11:
+			current.x2 = f
+		case 21:
+			current.y2 = f
+		case 40:
+			current.r = f
+		case 50:
+			current.a0 = f
+		case 51:
+			current.a1 = f
+		}
+	}
+	flush()
+
+	return entities, nil
+}
+
+// roundParam drops float noise from coordinates read back from CAD
+func roundParam(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDXFRoundTrip(t *testing.T) {
+	project := figureEight()
+
+	var buf bytes.Buffer
+	if err := RenderDXF(&buf, project); err != nil {
+		t.Fatalf("RenderDXF() error = %v", err)
+	}
+	dxf := buf.String()
+	for _, layer := range []string{"CURVE", "CURVE_EDGE", "STRAIGHT", "CROSSROADS", "CROSSROADS_LANE"} {
+		if !strings.Contains(dxf, "\n8\n"+layer+"\n") {
+			t.Errorf("DXF missing layer %s", layer)
+		}
+	}
+
+	imported, err := ImportDXF(buf.Bytes(), DefaultCatalog())
+	if err != nil {
+		t.Fatalf("ImportDXF() error = %v", err)
+	}
+	if len(imported.Pieces) != len(project.Pieces) {
+		t.Fatalf("imported %d pieces, want %d", len(imported.Pieces), len(project.Pieces))
+	}
+
+	if got, want := GenerateBOM(imported).BOM, GenerateBOM(project).BOM; !reflect.DeepEqual(got, want) {
+		t.Errorf("BOM = %v, want %v", got, want)
+	}
+
+	chain, err := SolvePoses(imported, DefaultTolerance)
+	if err != nil {
+		t.Fatalf("SolvePoses() error = %v", err)
+	}
+	if !chain.Connected {
+		t.Errorf("imported chain not connected: %+v", chain.Joints)
+	}
+	closure, err := ValidateClosure(imported, DefaultClosureOptions)
+	if err != nil {
+		t.Fatalf("ValidateClosure() error = %v", err)
+	}
+	if !closure.Closed {
+		t.Errorf("imported track not closed, gap = %v", closure.Gap)
+	}
+}
+
+func TestImportDXF_PlainCAD(t *testing.T) {
+	// A hand-drawn layer-0 drawing: line then a clockwise arc drawn CCW
+	dxf := strings.Join([]string{
+		"0", "SECTION", "2", "ENTITIES",
+		"0", "LINE", "8", "0", "10", "0", "20", "0", "11", "100", "21", "0",
+		"0", "ARC", "8", "0", "10", "100", "20", "-50", "40", "50", "50", "0", "51", "90",
+		"0", "ENDSEC", "0", "EOF",
+	}, "\n")
+
+	project, err := ImportDXF([]byte(dxf), DefaultCatalog())
+	if err != nil {
+		t.Fatalf("ImportDXF() error = %v", err)
+	}
+	if len(project.Pieces) != 2 {
+		t.Fatalf("got %d pieces, want 2", len(project.Pieces))
+	}
+	line, arc := project.Pieces[0], project.Pieces[1]
+	if line.Type != "straight" || line.Params.Length != 100 {
+		t.Errorf("line = %+v", line)
+	}
+	if arc.Type != "curve" || arc.Params.Radius != 50 || arc.Params.Angle != 90 || arc.Params.Direction != "right" {
+		t.Errorf("arc = %+v", arc)
+	}
+
+	chain, err := SolvePoses(project, DefaultTolerance)
+	if err != nil {
+		t.Fatalf("SolvePoses() error = %v", err)
+	}
+	if !chain.Connected {
+		t.Errorf("chain not connected: %+v", chain.Joints)
+	}
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"fmt"
+	"math"
+)
+
+// Port names
+const (
+	PortEntry = "entry"
+	PortExit  = "exit"
+	PortLeft  = "left"
+	PortRight = "right"
+)
+
+// Port is a connection point of a piece. The heading points out of the
+// piece, so two ports mate when they coincide with opposite headings.
+type Port struct {
+	Name string `json:"name"`
+	Pose Pose   `json:"pose"`
+}
+
+// ConnectionIssue is a broken connection rule on a special element
+type ConnectionIssue struct {
+	Piece   int         `json:"piece"`
+	ID      interface{} `json:"id"`
+	Type    string      `json:"type"`
+	Port    string      `json:"port,omitempty"`
+	Message string      `json:"message"`
+}
+
+// PiecePorts lists the connection points of a piece starting at entry.
+// Crossroads have four ports; everything else has an entry and an exit.
+func (c *Catalog) PiecePorts(entry Pose, piece Piece) ([]Port, error) {
+	exit, err := c.AdvancePose(entry, piece)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := []Port{
+		{Name: PortEntry, Pose: Pose{X: entry.X, Y: entry.Y, Heading: normalizeAngle(entry.Heading + 180)}},
+		{Name: PortExit, Pose: exit},
+	}
+
+	if geometry, _ := c.geometryOf(piece.Type); geometry == GeometryCrossing {
+		half := piece.Params.Length / 2
+		mid, _ := c.PoseAt(entry, piece, half)
+		h := mid.Heading * math.Pi / 180.0
+		ports = append(ports,
+			Port{Name: PortLeft, Pose: Pose{
+				X:       mid.X - half*math.Sin(h),
+				Y:       mid.Y + half*math.Cos(h),
+				Heading: normalizeAngle(mid.Heading + 90),
+			}},
+			Port{Name: PortRight, Pose: Pose{
+				X:       mid.X + half*math.Sin(h),
+				Y:       mid.Y - half*math.Cos(h),
+				Heading: normalizeAngle(mid.Heading - 90),
+			}},
+		)
+	}
+
+	return ports, nil
+}
+
+// portsMate reports whether a piece end meets a port head-on
+func portsMate(a, b Pose, tol Tolerance) bool {
+	flipped := Pose{X: b.X, Y: b.Y, Heading: normalizeAngle(b.Heading + 180)}
+	return CompareJoint(a, flipped, tol).Connected
+}
+
+// passThroughCrossing checks whether exit drives into a side port of a
+// crossroads already laid out in poses, and if so returns the pose leaving
+// the opposite side port
+func (c *Catalog) passThroughCrossing(pieces []Piece, poses []PiecePose, exit Pose, tol Tolerance) (Pose, bool) {
+	_, through, ok := c.crossingPassedThrough(pieces, poses, exit, tol)
+	return through, ok
+}
+
+// crossingPassedThrough is passThroughCrossing that also returns the index
+// of the crossroads driven through
+func (c *Catalog) crossingPassedThrough(pieces []Piece, poses []PiecePose, exit Pose, tol Tolerance) (int, Pose, bool) {
+	for _, pp := range poses {
+		piece := pieces[pp.Index]
+		if geometry, _ := c.geometryOf(piece.Type); geometry != GeometryCrossing {
+			continue
+		}
+
+		ports, err := c.PiecePorts(pp.Entry, piece)
+		if err != nil {
+			continue
+		}
+		for i, port := range ports {
+			if port.Name != PortLeft && port.Name != PortRight {
+				continue
+			}
+			if portsMate(exit, port.Pose, tol) {
+				// left is ports[2], right is ports[3]
+				opposite := ports[5-i]
+				return pp.Index, opposite.Pose, true
+			}
+		}
+	}
+	return 0, Pose{}, false
+}
+
+// CheckConnections applies the connection rules of special elements:
+// crossroads side ports must both be driven through (or both unused),
+// and markings such as zebra crossings must sit between straight pieces
+func CheckConnections(project *TrackProject, tol Tolerance) ([]ConnectionIssue, error) {
+	chain, err := SolvePoses(project, tol)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every piece end that another piece could plug into
+	var ends []Pose
+	for _, pp := range chain.Poses {
+		ends = append(ends, Pose{X: pp.Entry.X, Y: pp.Entry.Y, Heading: normalizeAngle(pp.Entry.Heading + 180)}, pp.Exit)
+	}
+
+	issues := []ConnectionIssue{}
+	catalog := project.Catalog()
+
+	for i, piece := range project.Pieces {
+		t, _ := catalog.Type(piece.Type)
+
+		if t.Geometry == GeometryCrossing {
+			ports, err := catalog.PiecePorts(chain.Poses[i].Entry, piece)
+			if err != nil {
+				return nil, fmt.Errorf("piece %d: %w", i, err)
+			}
+
+			connected := map[string]bool{}
+			for _, port := range ports[2:] {
+				for _, end := range ends {
+					if portsMate(end, port.Pose, tol) {
+						connected[port.Name] = true
+						break
+					}
+				}
+			}
+			if connected[PortLeft] != connected[PortRight] {
+				open := PortLeft
+				if connected[PortLeft] {
+					open = PortRight
+				}
+				issues = append(issues, ConnectionIssue{
+					Piece:   i,
+					ID:      piece.ID,
+					Type:    piece.Type,
+					Port:    open,
+					Message: "crossroads side port is a dead end",
+				})
+			}
+		}
+
+		if t.Marking {
+			for _, n := range []int{i - 1, i + 1} {
+				if n < 0 || n >= len(project.Pieces) {
+					continue
+				}
+				if geometry, _ := catalog.geometryOf(project.Pieces[n].Type); geometry != GeometryStraight {
+					issues = append(issues, ConnectionIssue{
+						Piece:   i,
+						ID:      piece.ID,
+						Type:    piece.Type,
+						Message: fmt.Sprintf("%s must sit between straight pieces", t.Name),
+					})
+					break
+				}
+			}
+		}
+	}
+
+	return issues, nil
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+// figureEight drives through a crossroads, loops left back into its side
+// port, leaves through the other side and loops right back to the start
+func figureEight() *TrackProject {
+	straight := func(id string) Piece {
+		return Piece{ID: id, Type: "straight", Params: PieceParams{Length: 27.5}}
+	}
+	curve := func(id, dir string) Piece {
+		return Piece{ID: id, Type: "curve", Params: PieceParams{Radius: 50, Angle: 90, Direction: dir}}
+	}
+
+	pieces := []Piece{
+		{ID: "x", Type: "crossroads", Params: PieceParams{Length: 45}},
+		straight("a1"), curve("l1", "left"), curve("l2", "left"), curve("l3", "left"), straight("a2"),
+		straight("b1"), curve("r1", "right"), curve("r2", "right"), curve("r3", "right"), straight("b2"),
+	}
+	poses, _ := defaultCatalog.ChainPoses(pieces, Pose{})
+	for i := range pieces {
+		pieces[i] = defaultCatalog.placePiece(pieces[i], poses[i].Entry)
+	}
+	return &TrackProject{Pieces: pieces}
+}
+
+func TestFigureEightThroughCrossroads(t *testing.T) {
+	project := figureEight()
+
+	chain, err := SolvePoses(project, DefaultTolerance)
+	if err != nil {
+		t.Fatalf("SolvePoses() error = %v", err)
+	}
+	if !chain.Connected {
+		t.Errorf("expected connected chain, joints = %+v", chain.Joints)
+	}
+
+	closure, err := ValidateClosure(project, DefaultClosureOptions)
+	if err != nil {
+		t.Fatalf("ValidateClosure() error = %v", err)
+	}
+	if !closure.Closed {
+		t.Errorf("expected closed loop, gap = %v", closure.Gap)
+	}
+
+	issues, err := CheckConnections(project, DefaultTolerance)
+	if err != nil {
+		t.Fatalf("CheckConnections() error = %v", err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("unexpected issues: %+v", issues)
+	}
+
+	collisions, err := DetectCollisions(project)
+	if err != nil {
+		t.Fatalf("DetectCollisions() error = %v", err)
+	}
+	if !collisions.Clear {
+		t.Errorf("crossing at the crossroads should not count as overlap: %+v", collisions.Collisions)
+	}
+}
+
+func TestCheckConnections_DeadEnd(t *testing.T) {
+	// Stop halfway round the left loop: the left port is used, the right isn't
+	project := figureEight()
+	project.Pieces = project.Pieces[:6]
+
+	issues, err := CheckConnections(project, DefaultTolerance)
+	if err != nil {
+		t.Fatalf("CheckConnections() error = %v", err)
+	}
+	if len(issues) != 1 || issues[0].Port != PortRight {
+		t.Errorf("expected dead-end right port, got %+v", issues)
+	}
+}
+
+func TestCheckConnections_MarkingOnCurve(t *testing.T) {
+	pieces := []Piece{
+		{ID: 1, Type: "straight", Params: PieceParams{Length: 50}},
+		{ID: 2, Type: "zebra", Params: PieceParams{Length: 25}},
+		{ID: 3, Type: "curve", Params: PieceParams{Radius: 50, Angle: 90}},
+	}
+	poses, _ := defaultCatalog.ChainPoses(pieces, Pose{})
+	for i := range pieces {
+		pieces[i] = defaultCatalog.placePiece(pieces[i], poses[i].Entry)
+	}
+
+	issues, err := CheckConnections(&TrackProject{Pieces: pieces}, DefaultTolerance)
+	if err != nil {
+		t.Fatalf("CheckConnections() error = %v", err)
+	}
+	if len(issues) != 1 || issues[0].ID != 2 {
+		t.Errorf("expected zebra issue, got %+v", issues)
+	}
+}
+
+func TestSpecialElementLengthAndBOM(t *testing.T) {
+	project := &TrackProject{
+		Pieces: []Piece{
+			{Type: "roundabout", Params: PieceParams{Length: 100, Radius: 50}},
+			{Type: "ramp", Params: PieceParams{Length: 100, Height: 10}},
+			{Type: "crossroads", Params: PieceParams{Length: 45}},
+		},
+	}
+
+	length, err := CalculateLength(project)
+	if err != nil {
+		t.Fatalf("CalculateLength() error = %v", err)
+	}
+	want := math.Round(100+2*math.Pi*50+100+45) / 100
+	if length != want {
+		t.Errorf("CalculateLength() = %v, want %v", length, want)
+	}
+
+	bom := GenerateBOM(project)
+	for _, code := range []string{"H50-L100", "P100-10", "X45"} {
+		if bom.BOM[code] != 1 {
+			t.Errorf("BOM[%s] = %d, want 1 (bom = %v)", code, bom.BOM[code], bom.BOM)
+		}
+	}
+}
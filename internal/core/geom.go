@@ -8,19 +8,15 @@ import (
 // CalculateLength computes the total track length in meters
 func CalculateLength(project *TrackProject) (float64, error) {
 	totalCm := 0.0
+	catalog := project.Catalog()
 
 	// Calculate from pieces (legacy)
 	for _, piece := range project.Pieces {
-		switch piece.Type {
-		case "straight":
-			totalCm += piece.Params.Length
-		case "curve":
-			// Arc length = radius * angle_in_radians
-			angleRad := piece.Params.Angle * math.Pi / 180.0
-			totalCm += piece.Params.Radius * angleRad
-		default:
-			return 0, fmt.Errorf("unknown piece type: %s", piece.Type)
+		length, err := catalog.PieceLength(piece)
+		if err != nil {
+			return 0, err
 		}
+		totalCm += length
 	}
 
 	// Calculate from boundary (if present and no pieces)
@@ -64,9 +60,10 @@ func calculatePolylineLength(boundary *Boundary) float64 {
 // GenerateBOM creates a bill of materials
 func GenerateBOM(project *TrackProject) *BOMSummary {
 	bom := make(map[string]int)
+	catalog := project.Catalog()
 	
 	for _, piece := range project.Pieces {
-		key := generateBOMKey(piece)
+		key := catalog.Code(piece)
 		bom[key]++
 	}
 
@@ -77,16 +74,6 @@ func GenerateBOM(project *TrackProject) *BOMSummary {
 		TotalLength: fmt.Sprintf("%.2f", length),
 		BOM:         bom,
 		Details:     project.Pieces,
-	}
-}
-
-func generateBOMKey(piece Piece) string {
-	switch piece.Type {
-	case "straight":
-		return fmt.Sprintf("L%.0f", piece.Params.Length)
-	case "curve":
-		return fmt.Sprintf("R%.0f-%.0f", piece.Params.Radius, piece.Params.Angle)
-	default:
-		return "UNKNOWN"
+		catalog:     catalog,
 	}
 }
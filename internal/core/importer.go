@@ -91,6 +91,9 @@ func parsePiecesFromInterface(items []interface{}) []Piece {
 				Length: getFloatField(params, "length", 0),
 				Radius: getFloatField(params, "radius", 0),
 				Angle:  getFloatField(params, "angle", 0),
+				Height: getFloatField(params, "height", 0),
+
+				Direction: getStringField(params, "direction", ""),
 			}
 		}
 
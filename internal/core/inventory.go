@@ -0,0 +1,93 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Inventory is how many of each part (by BOM code) a lab owns
+type Inventory map[string]int
+
+// PartCheck compares what a track needs of one part with what's owned
+type PartCheck struct {
+	Code      string `json:"code"`
+	Name      string `json:"name,omitempty"` // catalogue name, if it's a standard part
+	Required  int    `json:"required"`
+	Owned     int    `json:"owned"`
+	Shortfall int    `json:"shortfall"`
+}
+
+// BuildabilityReport says whether a track can be built from an inventory
+type BuildabilityReport struct {
+	Buildable      bool        `json:"buildable"`
+	Parts          []PartCheck `json:"parts"` // by code
+	TotalShortfall int         `json:"totalShortfall"`
+}
+
+// CheckBuildability compares a bill of materials with an inventory
+func CheckBuildability(bom *BOMSummary, inv Inventory) *BuildabilityReport {
+	catalog := bom.partsCatalog()
+	report := &BuildabilityReport{Buildable: true, Parts: []PartCheck{}}
+
+	for code, required := range bom.BOM {
+		check := PartCheck{Code: code, Required: required, Owned: inv[code]}
+		if entry, ok := catalog.Entry(code); ok {
+			check.Name = entry.Name
+		}
+		if check.Owned < required {
+			check.Shortfall = required - check.Owned
+			report.TotalShortfall += check.Shortfall
+			report.Buildable = false
+		}
+		report.Parts = append(report.Parts, check)
+	}
+	sort.Slice(report.Parts, func(i, j int) bool {
+		return report.Parts[i].Code < report.Parts[j].Code
+	})
+
+	return report
+}
+
+var inventoryItem = regexp.MustCompile(`^(\d+)\s*[x×*]\s*(\S+)$`)
+
+// ParseInventory reads an inventory written the way people list parts:
+// "12×L50, 8×R50-90" (x or * work too; commas, semicolons or newlines
+// between items). Repeated codes add up.
+func ParseInventory(text string) (Inventory, error) {
+	inv := Inventory{}
+	items := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ';' || r == '\n' || r == '，'
+	})
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		m := inventoryItem.FindStringSubmatch(item)
+		if m == nil {
+			return nil, fmt.Errorf("invalid inventory item %q, want e.g. 12×L50", item)
+		}
+		count, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid inventory item %q: %w", item, err)
+		}
+		inv[m[2]] += count
+	}
+	return inv, nil
+}
+
+// Validate checks that counts aren't negative and codes aren't empty
+func (inv Inventory) Validate() error {
+	for code, n := range inv {
+		if strings.TrimSpace(code) == "" {
+			return fmt.Errorf("inventory has a part without a code")
+		}
+		if n < 0 {
+			return fmt.Errorf("negative count for %s", code)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package core
+
+import "testing"
+
+func TestParseInventory(t *testing.T) {
+	inv, err := ParseInventory("12×L50, 8x R50-90;\n2*X45, 3×L50")
+	if err != nil {
+		t.Fatalf("ParseInventory() error = %v", err)
+	}
+	want := Inventory{"L50": 15, "R50-90": 8, "X45": 2}
+	if len(inv) != len(want) {
+		t.Fatalf("got %v, want %v", inv, want)
+	}
+	for code, n := range want {
+		if inv[code] != n {
+			t.Errorf("%s = %d, want %d", code, inv[code], n)
+		}
+	}
+
+	if _, err := ParseInventory("L50"); err == nil {
+		t.Error("expected error for an item without a count")
+	}
+}
+
+func TestCheckBuildability(t *testing.T) {
+	bom := GenerateBOM(figureEight())
+
+	report := CheckBuildability(bom, Inventory{"X45": 1, "L25": 0, "R50-90": 6})
+	if report.Buildable {
+		t.Fatal("expected the figure eight to be short of straights")
+	}
+	// Four 27.5cm straights aren't standard parts, so none are owned
+	for _, part := range report.Parts {
+		switch part.Code {
+		case "L27.5":
+			if part.Required != 4 || part.Shortfall != 4 || part.Name != "" {
+				t.Errorf("L27.5 = %+v", part)
+			}
+		case "R50-90":
+			if part.Required != 6 || part.Shortfall != 0 || part.Name == "" {
+				t.Errorf("R50-90 = %+v", part)
+			}
+		}
+	}
+	if report.TotalShortfall != 4 {
+		t.Errorf("total shortfall = %d, want 4", report.TotalShortfall)
+	}
+
+	full := CheckBuildability(bom, Inventory{"X45": 1, "L27.5": 4, "R50-90": 8})
+	if !full.Buildable || full.TotalShortfall != 0 {
+		t.Errorf("expected buildable, got %+v", full)
+	}
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// Lab is one lab's config: its parts catalogue, competition rule profiles
+// and price list. Each store loads its own from its data directory.
+type Lab struct {
+	Catalog *Catalog
+	Rules   map[string]*RuleProfile
+	Prices  *PriceList // nil when BOMs go unpriced
+}
+
+// DefaultLab is the built-in catalogue and rules, without prices
+func DefaultLab() *Lab {
+	return &Lab{
+		Catalog: DefaultCatalog(),
+		Rules:   DefaultRuleProfiles(),
+	}
+}
+
+// LoadLab reads the lab config files in dataDir: catalog.json, rules/*.json
+// and prices.json. Missing files leave the defaults in place.
+func LoadLab(dataDir string) (*Lab, error) {
+	// Lab-specific parts live next to the database
+	catalog, err := LoadCatalog(filepath.Join(dataDir, "catalog.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Competition rule profiles, one JSON file per season/group
+	rules, err := LoadRuleProfiles(filepath.Join(dataDir, "rules"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Optional price list for BOM costs
+	prices, err := LoadPriceList(filepath.Join(dataDir, "prices.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Lab{Catalog: catalog, Rules: rules, Prices: prices}, nil
+}
+
+// RuleProfile looks up a profile by name
+func (l *Lab) RuleProfile(name string) (*RuleProfile, bool) {
+	p, ok := l.Rules[name]
+	return p, ok
+}
+
+// RuleProfiles returns all profiles sorted by name
+func (l *Lab) RuleProfiles() []*RuleProfile {
+	profiles := make([]*RuleProfile, 0, len(l.Rules))
+	for _, p := range l.Rules {
+		profiles = append(profiles, p)
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+	return profiles
+}
+
+// GenerateBOM is GenerateBOM with the parts priced from the lab's price
+// list, if it has one
+func (l *Lab) GenerateBOM(project *TrackProject) *BOMSummary {
+	bom := GenerateBOM(project)
+	if l.Prices != nil {
+		bom.Cost = PriceBOM(bom, l.Prices)
+	}
+	return bom
+}
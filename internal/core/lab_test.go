@@ -0,0 +1,57 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadLab(t *testing.T) {
+	dir := t.TempDir()
+
+	// No config files: the built-in catalogue and rules, unpriced
+	lab, err := LoadLab(dir)
+	if err != nil {
+		t.Fatalf("LoadLab() error = %v", err)
+	}
+	if lab.Prices != nil || len(lab.Catalog.Pieces) != len(DefaultCatalog().Pieces) {
+		t.Errorf("expected the defaults, got %+v", lab)
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, "rules"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"rules/2025-camera.json": `{"minCurveRadiusCm": 60}`,
+		"prices.json":            `{"currency": "CNY", "parts": {"L50": 3}}`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lab, err = LoadLab(dir)
+	if err != nil {
+		t.Fatalf("LoadLab() error = %v", err)
+	}
+	if _, ok := lab.RuleProfile("2025-camera"); !ok {
+		t.Error("rule profile not loaded")
+	}
+	profiles := lab.RuleProfiles()
+	for i := 1; i < len(profiles); i++ {
+		if profiles[i-1].Name > profiles[i].Name {
+			t.Errorf("profiles not sorted: %s before %s", profiles[i-1].Name, profiles[i].Name)
+		}
+	}
+
+	project := &TrackProject{Pieces: []Piece{{Type: "straight", Params: PieceParams{Length: 50}}}}
+	if cost := lab.GenerateBOM(project).Cost; cost == nil || cost.Total != 3 {
+		t.Errorf("cost = %+v, want 3 CNY", cost)
+	}
+
+	// Each lab is separate
+	if DefaultLab().Prices != nil {
+		t.Error("loading a lab changed the defaults")
+	}
+}
@@ -0,0 +1,239 @@
+package core
+
+import (
+	"fmt"
+	"math"
+)
+
+// VehicleModel is the point-mass car used for lap-time estimates. Speeds
+// are in m/s and accelerations in m/s².
+type VehicleModel struct {
+	MaxSpeed        float64 `json:"maxSpeed"`
+	MaxLateralAccel float64 `json:"maxLateralAccel"` // grip in corners
+	MaxAccel        float64 `json:"maxAccel"`
+	MaxBrake        float64 `json:"maxBrake"` // deceleration, positive
+}
+
+// DefaultVehicle is a typical 1:10 smart car
+var DefaultVehicle = VehicleModel{
+	MaxSpeed:        3,
+	MaxLateralAccel: 4,
+	MaxAccel:        3,
+	MaxBrake:        5,
+}
+
+// Validate checks that every limit is a positive number
+func (v VehicleModel) Validate() error {
+	for _, f := range []struct {
+		name  string
+		value float64
+	}{
+		{"maxSpeed", v.MaxSpeed},
+		{"maxLateralAccel", v.MaxLateralAccel},
+		{"maxAccel", v.MaxAccel},
+		{"maxBrake", v.MaxBrake},
+	} {
+		if !(f.value > 0) || math.IsInf(f.value, 0) {
+			return fmt.Errorf("%s must be positive", f.name)
+		}
+	}
+	return nil
+}
+
+// What holds the car back at a point on the lap
+const (
+	LimitTopSpeed   = "topSpeed"
+	LimitCornering  = "cornering"
+	LimitBraking    = "braking"
+	LimitAccelerate = "accelerating"
+)
+
+// LapSegment is the speed summary over one piece (or one pass through a
+// crossroads)
+type LapSegment struct {
+	Piece    int     `json:"piece"`
+	Type     string  `json:"type"`
+	StartCm  float64 `json:"startCm"`
+	LengthCm float64 `json:"lengthCm"`
+	TimeS    float64 `json:"timeS"`
+	MinSpeed float64 `json:"minSpeed"` // m/s
+	MaxSpeed float64 `json:"maxSpeed"`
+	AvgSpeed float64 `json:"avgSpeed"`
+	Limit    string  `json:"limit"` // what holds the car back for most of the segment
+}
+
+// LimitingSection is a stretch where cornering grip caps the speed,
+// i.e. where a grippier car would gain time
+type LimitingSection struct {
+	StartCm  float64 `json:"startCm"`
+	EndCm    float64 `json:"endCm"`
+	Piece    int     `json:"piece"` // piece at the slowest point
+	Type     string  `json:"type"`
+	MinSpeed float64 `json:"minSpeed"`
+}
+
+// LapEstimate is the result of EstimateLapTime
+type LapEstimate struct {
+	Vehicle  VehicleModel      `json:"vehicle"`
+	LapTimeS float64           `json:"lapTimeS"`
+	LengthCm float64           `json:"lengthCm"`
+	Closed   bool              `json:"closed"` // flying lap; otherwise a standing start
+	AvgSpeed float64           `json:"avgSpeed"`
+	Segments []LapSegment      `json:"segments"`
+	Limiting []LimitingSection `json:"limiting"`
+}
+
+// lapStepCm is the centreline resolution of the speed profile
+const lapStepCm = 2
+
+// EstimateLapTime works out the fastest speed profile the vehicle can
+// drive along the centreline: capped by top speed and cornering grip, then
+// a forward pass for acceleration and a backward pass for braking. Closed
+// tracks are timed as a flying lap, open ones from a standing start.
+func EstimateLapTime(project *TrackProject, vehicle VehicleModel) (*LapEstimate, error) {
+	if err := vehicle.Validate(); err != nil {
+		return nil, err
+	}
+	centreline, err := SampleCentreline(project, lapStepCm)
+	if err != nil {
+		return nil, err
+	}
+	samples := centreline.Samples
+	n := len(samples)
+
+	// Speed caps per sample (m/s)
+	corner := make([]float64, n)
+	limit := make([]float64, n)
+	for i, s := range samples {
+		corner[i] = math.Inf(1)
+		if k := math.Abs(s.Curvature) * 100; k > 0 {
+			corner[i] = math.Sqrt(vehicle.MaxLateralAccel / k)
+		}
+		limit[i] = math.Min(vehicle.MaxSpeed, corner[i])
+	}
+
+	ds := func(i int) float64 { return (samples[i+1].S - samples[i].S) / 100 }
+
+	// On a loop the speed at the line depends on the end of the lap, so
+	// go round twice and keep the second lap
+	laps := 1
+	if centreline.Closed {
+		laps = 2
+	}
+
+	v := make([]float64, n)
+	copy(v, limit)
+	if !centreline.Closed {
+		v[0] = 0
+	}
+	for lap := 0; lap < laps; lap++ {
+		if lap > 0 {
+			v[0] = math.Min(v[0], v[n-1])
+		}
+		for i := 0; i+1 < n; i++ {
+			v[i+1] = math.Min(v[i+1], math.Sqrt(v[i]*v[i]+2*vehicle.MaxAccel*ds(i)))
+		}
+	}
+	braked := make([]bool, n)
+	for lap := 0; lap < laps; lap++ {
+		if lap > 0 {
+			v[n-1] = math.Min(v[n-1], v[0])
+		}
+		for i := n - 2; i >= 0; i-- {
+			if b := math.Sqrt(v[i+1]*v[i+1] + 2*vehicle.MaxBrake*ds(i)); b < v[i] {
+				v[i] = b
+				braked[i] = true
+			}
+		}
+	}
+	if centreline.Closed {
+		v[0] = math.Min(v[0], v[n-1])
+		v[n-1] = v[0]
+	}
+
+	reason := func(i int) string {
+		const eps = 1e-6
+		switch {
+		case v[i] >= corner[i]-eps && corner[i] <= vehicle.MaxSpeed:
+			return LimitCornering
+		case v[i] >= vehicle.MaxSpeed-eps:
+			return LimitTopSpeed
+		case braked[i]:
+			return LimitBraking
+		default:
+			return LimitAccelerate
+		}
+	}
+
+	est := &LapEstimate{
+		Vehicle:  vehicle,
+		LengthCm: centreline.Length,
+		Closed:   centreline.Closed,
+		Segments: []LapSegment{},
+		Limiting: []LimitingSection{},
+	}
+
+	var seg *LapSegment
+	var votes map[string]float64
+	closeSegment := func() {
+		if seg == nil {
+			return
+		}
+		best := 0.0
+		for r, d := range votes {
+			if d > best || (d == best && r < seg.Limit) {
+				seg.Limit, best = r, d
+			}
+		}
+		if seg.TimeS > 0 {
+			seg.AvgSpeed = seg.LengthCm / 100 / seg.TimeS
+		}
+		est.Segments = append(est.Segments, *seg)
+	}
+
+	var section *LimitingSection
+	for i := 0; i+1 < n; i++ {
+		d := ds(i)
+		// Constant acceleration between samples
+		dt := 0.0
+		if avg := (v[i] + v[i+1]) / 2; avg > 0 {
+			dt = d / avg
+		}
+		est.LapTimeS += dt
+
+		s := samples[i]
+		if seg == nil || seg.Piece != s.Piece {
+			closeSegment()
+			seg = &LapSegment{Piece: s.Piece, Type: s.Type, StartCm: s.S, MinSpeed: math.Inf(1)}
+			votes = map[string]float64{}
+		}
+		seg.LengthCm += d * 100
+		seg.TimeS += dt
+		seg.MinSpeed = math.Min(seg.MinSpeed, math.Min(v[i], v[i+1]))
+		seg.MaxSpeed = math.Max(seg.MaxSpeed, math.Max(v[i], v[i+1]))
+		r := reason(i)
+		votes[r] += d
+
+		if r == LimitCornering {
+			if section == nil {
+				section = &LimitingSection{StartCm: s.S, Piece: s.Piece, Type: s.Type, MinSpeed: v[i]}
+			}
+			section.EndCm = samples[i+1].S
+			if v[i] < section.MinSpeed {
+				section.MinSpeed, section.Piece, section.Type = v[i], s.Piece, s.Type
+			}
+		} else if section != nil {
+			est.Limiting = append(est.Limiting, *section)
+			section = nil
+		}
+	}
+	closeSegment()
+	if section != nil {
+		est.Limiting = append(est.Limiting, *section)
+	}
+
+	if est.LapTimeS > 0 {
+		est.AvgSpeed = centreline.Length / 100 / est.LapTimeS
+	}
+	return est, nil
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEstimateLapTime_StandingStart(t *testing.T) {
+	project := &TrackProject{
+		Pieces: []Piece{{ID: "a", Type: "straight", Params: PieceParams{Length: 100}}},
+	}
+
+	est, err := EstimateLapTime(project, DefaultVehicle)
+	if err != nil {
+		t.Fatalf("EstimateLapTime() error = %v", err)
+	}
+	if est.Closed {
+		t.Error("a single straight is not a loop")
+	}
+	// 1m at 3 m/s² from rest, never reaching top speed
+	if want := math.Sqrt(2.0 / 3); math.Abs(est.LapTimeS-want) > 1e-6 {
+		t.Errorf("lap time = %v, want %v", est.LapTimeS, want)
+	}
+	if len(est.Segments) != 1 || est.Segments[0].Limit != LimitAccelerate {
+		t.Errorf("segments = %+v", est.Segments)
+	}
+	if len(est.Limiting) != 0 {
+		t.Errorf("expected no limiting sections, got %+v", est.Limiting)
+	}
+}
+
+func TestEstimateLapTime_Loop(t *testing.T) {
+	est, err := EstimateLapTime(figureEight(), DefaultVehicle)
+	if err != nil {
+		t.Fatalf("EstimateLapTime() error = %v", err)
+	}
+	if !est.Closed {
+		t.Fatal("expected a flying lap on the figure eight")
+	}
+
+	// Two 270° bends at r=50cm, each capped at sqrt(4 / 2) m/s
+	corner := math.Sqrt(2)
+	if len(est.Limiting) != 2 {
+		t.Fatalf("limiting sections = %+v, want the two bends", est.Limiting)
+	}
+	for _, l := range est.Limiting {
+		if l.Type != "curve" || math.Abs(l.MinSpeed-corner) > 1e-9 {
+			t.Errorf("limiting section %+v, want a curve at %v m/s", l, corner)
+		}
+	}
+
+	total := 0.0
+	for _, seg := range est.Segments {
+		total += seg.TimeS
+		if seg.Type == "curve" && (seg.Limit != LimitCornering || seg.AvgSpeed > corner+0.01) {
+			t.Errorf("curve segment %+v should be held to the cornering speed", seg)
+		}
+	}
+	if math.Abs(total-est.LapTimeS) > 1e-9 {
+		t.Errorf("segment times sum to %v, lap time %v", total, est.LapTimeS)
+	}
+
+	// A grippier car is faster
+	grippy := DefaultVehicle
+	grippy.MaxLateralAccel *= 2
+	faster, err := EstimateLapTime(figureEight(), grippy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if faster.LapTimeS >= est.LapTimeS {
+		t.Errorf("grippier car lap %v, not faster than %v", faster.LapTimeS, est.LapTimeS)
+	}
+
+	if _, err := EstimateLapTime(figureEight(), VehicleModel{MaxSpeed: 1}); err == nil {
+		t.Error("expected error for a vehicle without limits")
+	}
+}
@@ -27,6 +27,8 @@ type TrackProject struct {
 
 	// Legacy model: discrete pieces
 	Pieces []Piece `json:"pieces,omitempty"`
+
+	catalog *Catalog // see UseCatalog
 }
 
 // Boundary defines the track area as a polygon
@@ -62,14 +64,23 @@ type PieceParams struct {
 	Length float64 `json:"length,omitempty"` // for straight
 	Radius float64 `json:"radius,omitempty"` // for curve
 	Angle  float64 `json:"angle,omitempty"`  // for curve
+
+	Height float64 `json:"height,omitempty"` // for ramp
+
+	// Direction is "left" (default) or "right" for curves, and the island
+	// side for roundabouts
+	Direction string `json:"direction,omitempty"`
 }
 
 // BOMSummary provides bill of materials
 type BOMSummary struct {
-	TotalPieces int               `json:"totalPieces"`
-	TotalLength string            `json:"totalLength"` // in meters, 2 decimals
-	BOM         map[string]int    `json:"bom"`
-	Details     []Piece           `json:"details,omitempty"`
+	TotalPieces int            `json:"totalPieces"`
+	TotalLength string         `json:"totalLength"` // in meters, 2 decimals
+	BOM         map[string]int `json:"bom"`
+	Details     []Piece        `json:"details,omitempty"`
+	Cost        *BOMCost       `json:"cost,omitempty"` // when a price list is set up
+
+	catalog *Catalog // the codes' parts
 }
 
 // TrackMetadata for storage and listing
@@ -84,8 +95,10 @@ type TrackMetadata struct {
 	CreatedAt      time.Time `json:"createdAt"`
 	TotalPieces    int       `json:"totalPieces"`
 	TotalLength    string    `json:"totalLength"`
-	TotalLengthCm  int       `json:"totalLengthCm"` // for filtering
+	TotalLengthCm  int       `json:"totalLengthCm"`        // for filtering
+	Difficulty     *float64  `json:"difficulty,omitempty"` // ComputeDifficulty score, nil if it can't be rated
 	Thumbnail      string    `json:"thumbnail,omitempty"`
 	Likes          int       `json:"likes"`
 	Downloads      int       `json:"downloads"`
+	Snippet        string    `json:"snippet,omitempty"` // search results: HTML-escaped text with the matches in <mark>
 }
@@ -0,0 +1,768 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// PaperSize is a sheet size in portrait orientation
+type PaperSize struct {
+	Name     string  `json:"name"`
+	WidthCm  float64 `json:"widthCm"`
+	HeightCm float64 `json:"heightCm"`
+}
+
+var paperSizes = map[string]PaperSize{
+	"A4": {Name: "A4", WidthCm: 21.0, HeightCm: 29.7},
+	"A3": {Name: "A3", WidthCm: 29.7, HeightCm: 42.0},
+}
+
+// LookupPaper finds a supported paper size by name (case-insensitive)
+func LookupPaper(name string) (PaperSize, bool) {
+	p, ok := paperSizes[strings.ToUpper(name)]
+	return p, ok
+}
+
+// PDFOptions controls how the track is split into printed tiles
+type PDFOptions struct {
+	Paper     string  // "A4" (default) or "A3"
+	MarginCm  float64 // unprinted border on each sheet, default 1
+	OverlapCm float64 // strip shared by neighbouring tiles, default 2
+}
+
+// PDFTile is one printed sheet. Min/Max is the floor area (cm) it shows.
+type PDFTile struct {
+	Row  int     `json:"row"`
+	Col  int     `json:"col"`
+	Name string  `json:"name"` // e.g. "B3": row letter, column number
+	MinX float64 `json:"minX"`
+	MinY float64 `json:"minY"`
+	MaxX float64 `json:"maxX"`
+	MaxY float64 `json:"maxY"`
+
+	shapes []Shape // on the tile, when planned for a track
+}
+
+// TilePlan is the tiling of the track for one paper size
+type TilePlan struct {
+	Paper     PaperSize `json:"paper"`
+	Landscape bool      `json:"landscape"`
+	Rows      int       `json:"rows"`
+	Cols      int       `json:"cols"`
+	Tiles     []PDFTile `json:"tiles"`
+}
+
+// pdfPaddingCm is left around the track so edge lines aren't cut off
+const pdfPaddingCm = 5.0
+
+// ptPerCm converts centimetres to PDF points
+const ptPerCm = 72 / 2.54
+
+// maxPDFTiles is the most sheets one template prints. Past that the track
+// is better printed on a plotter than taped together from paper.
+const maxPDFTiles = 1000
+
+// maxPDFGridTiles bounds the grid over the track's bounding box. Only the
+// sheets with track on them are printed, so the grid may be a lot larger
+// than maxPDFTiles for long thin tracks.
+const maxPDFGridTiles = 10_000
+
+// PlanTiles splits the floor area into overlapping tiles, picking whichever
+// orientation needs fewer sheets. Tiles are ordered row by row from the top
+// left, as they are laid out on the floor.
+func PlanTiles(minX, minY, maxX, maxY float64, opts PDFOptions) (*TilePlan, error) {
+	return planTiles(minX, minY, maxX, maxY, opts, nil)
+}
+
+// planTiles is PlanTiles keeping only the tiles some of shapes are on (all
+// of them if shapes is nil), each with the shapes it shows. Rows and Cols
+// are still the whole grid, so the tile names match their place on the
+// floor.
+func planTiles(minX, minY, maxX, maxY float64, opts PDFOptions, shapes []Shape) (*TilePlan, error) {
+	opts, paper, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	var best *TilePlan
+	for _, landscape := range []bool{false, true} {
+		w, h := paper.WidthCm, paper.HeightCm
+		if landscape {
+			w, h = h, w
+		}
+		g := tileGrid{minX: minX, maxY: maxY, printW: w - 2*opts.MarginCm, printH: h - 2*opts.MarginCm}
+		g.stepX = g.printW - opts.OverlapCm
+		g.stepY = g.printH - opts.OverlapCm
+		if g.stepX <= 0 || g.stepY <= 0 {
+			return nil, fmt.Errorf("margin and overlap leave no room on %s paper", paper.Name)
+		}
+
+		cols := math.Max(1, math.Ceil((maxX-minX-opts.OverlapCm)/g.stepX))
+		rows := math.Max(1, math.Ceil((maxY-minY-opts.OverlapCm)/g.stepY))
+		if !(rows*cols <= maxPDFGridTiles) {
+			return nil, fmt.Errorf("track is too large to print: %.0f x %.0f cm", maxX-minX, maxY-minY)
+		}
+		g.rows, g.cols = int(rows), int(cols)
+
+		var on map[int][]Shape
+		if shapes != nil {
+			on = g.shapesByTile(shapes)
+		}
+		plan := &TilePlan{Paper: paper, Landscape: landscape, Rows: g.rows, Cols: g.cols}
+		for r := 0; r < g.rows; r++ {
+			for c := 0; c < g.cols; c++ {
+				shown := on[r*g.cols+c]
+				if shapes != nil && shown == nil {
+					continue
+				}
+				tile := g.tile(r, c)
+				tile.shapes = shown
+				plan.Tiles = append(plan.Tiles, tile)
+			}
+		}
+		if best == nil || len(plan.Tiles) < len(best.Tiles) {
+			best = plan
+		}
+	}
+
+	return best, nil
+}
+
+func (opts PDFOptions) withDefaults() (PDFOptions, PaperSize, error) {
+	if opts.Paper == "" {
+		opts.Paper = "A4"
+	}
+	if opts.MarginCm <= 0 {
+		opts.MarginCm = 1
+	}
+	if opts.OverlapCm <= 0 {
+		opts.OverlapCm = 2
+	}
+	paper, ok := LookupPaper(opts.Paper)
+	if !ok {
+		return opts, PaperSize{}, fmt.Errorf("unsupported paper size: %s", opts.Paper)
+	}
+	return opts, paper, nil
+}
+
+// tileName is the row letter(s) followed by the 1-based column
+func tileName(row, col int) string {
+	letters := ""
+	for n := row; n >= 0; n = n/26 - 1 {
+		letters = string(rune('A'+n%26)) + letters
+	}
+	return fmt.Sprintf("%s%d", letters, col+1)
+}
+
+// RenderPDF writes a printable template of the track: an overview page with
+// the tile layout and BOM, then one page per tile at 1:1 scale. Each tile
+// has registration marks in the overlap strip so neighbouring sheets can
+// be lined up, and a 10cm bar to check the printer didn't rescale.
+func RenderPDF(out io.Writer, project *TrackProject, opts PDFOptions) error {
+	opts, _, err := opts.withDefaults()
+	if err != nil {
+		return err
+	}
+
+	shapes, err := TrackShapes(project)
+	if err != nil {
+		return err
+	}
+	if len(shapes) == 0 {
+		return fmt.Errorf("track has no pieces or boundary to draw")
+	}
+
+	minX, minY, maxX, maxY := ShapesBounds(shapes)
+	minX -= pdfPaddingCm
+	minY -= pdfPaddingCm
+	maxX += pdfPaddingCm
+	maxY += pdfPaddingCm
+
+	// Sheets with no track on them are left out
+	plan, err := planTiles(minX, minY, maxX, maxY, opts, shapes)
+	if err != nil {
+		return err
+	}
+	if len(plan.Tiles) > maxPDFTiles {
+		return fmt.Errorf("track needs %d %s sheets, more than the %d one template can have",
+			len(plan.Tiles), plan.Paper.Name, maxPDFTiles)
+	}
+
+	half := TrackWidth(project) / 2
+	title := project.Name
+	if title == "" || !isPDFText(title) {
+		title = "Track " + project.ID
+	}
+
+	// Pages are written as they are drawn, so only one is held at a time
+	doc := newPDFDocument(out)
+	doc.addPage(plan.Paper.WidthCm*ptPerCm, plan.Paper.HeightCm*ptPerCm,
+		overviewPage(project, title, shapes, plan, half, minX, minY, maxX, maxY))
+
+	pageW, pageH := plan.Paper.WidthCm, plan.Paper.HeightCm
+	if plan.Landscape {
+		pageW, pageH = pageH, pageW
+	}
+	for _, tile := range plan.Tiles {
+		doc.addPage(pageW*ptPerCm, pageH*ptPerCm, tilePage(title, tile.shapes, plan, tile, half, opts))
+	}
+
+	return doc.finish()
+}
+
+// tileGrid is where the tiles of one orientation lie on the floor: row 0
+// at the top, column 0 on the left
+type tileGrid struct {
+	minX, maxY     float64 // top-left corner
+	printW, printH float64 // floor area on one sheet
+	stepX, stepY   float64 // print size less the overlap
+	rows, cols     int
+}
+
+func (g tileGrid) tile(r, c int) PDFTile {
+	x0 := g.minX + float64(c)*g.stepX
+	y1 := g.maxY - float64(r)*g.stepY
+	return PDFTile{
+		Row:  r,
+		Col:  c,
+		Name: tileName(r, c),
+		MinX: x0,
+		MinY: y1 - g.printH,
+		MaxX: x0 + g.printW,
+		MaxY: y1,
+	}
+}
+
+// indexRange clamps the tile indices from lo up to hi to the n in the grid
+func indexRange(lo, hi float64, n int) (int, int) {
+	return int(math.Max(lo, 0)), int(math.Min(hi, float64(n-1)))
+}
+
+// colRange is the columns whose tiles, widened by pad, overlap x0..x1
+func (g tileGrid) colRange(x0, x1, pad float64) (int, int) {
+	return indexRange(
+		math.Ceil((x0-pad-g.printW-g.minX)/g.stepX),
+		math.Floor((x1+pad-g.minX)/g.stepX), g.cols)
+}
+
+// shapesByTile is shapesOnTile for every tile of the grid at once, keyed
+// by row*cols+col. Each edge segment only visits the tiles along it, and
+// tiles lying inside a shape are found by scanning each row of tile
+// centres across the shape's rings, so the work grows with the track
+// rather than with its bounding box.
+func (g tileGrid) shapesByTile(shapes []Shape) map[int][]Shape {
+	pad := edgeLineCm
+	on := map[int][]Shape{}
+	last := map[int]int{} // 1 + index of the shape last added to a tile
+	add := func(i, r, c int) {
+		k := r*g.cols + c
+		if last[k] != i+1 {
+			last[k] = i + 1
+			on[k] = append(on[k], shapes[i])
+		}
+	}
+
+	for i, s := range shapes {
+		rings := [][]Point{s.Outline()}
+		if s.Closed {
+			rings = [][]Point{s.LeftEdge, s.RightEdge}
+		}
+
+		crossings := map[int][]float64{} // tile centre x where rings cross each row
+		for _, ring := range rings {
+			for k := range ring {
+				a, b := ring[k], ring[(k+1)%len(ring)]
+				g.addSegment(a, b, pad, func(r, c int) { add(i, r, c) })
+				g.crossRows(a, b, crossings)
+			}
+		}
+
+		// Tile centres between the 1st and 2nd crossing, 3rd and 4th...
+		// are inside the shape (even-odd, as pointInRing)
+		for r, xs := range crossings {
+			sort.Float64s(xs)
+			for k := 0; k+1 < len(xs); k += 2 {
+				c0, c1 := indexRange(
+					math.Ceil((xs[k]-g.minX-g.printW/2)/g.stepX),
+					math.Floor((xs[k+1]-g.minX-g.printW/2)/g.stepX), g.cols)
+				for c := c0; c <= c1; c++ {
+					add(i, r, c)
+				}
+			}
+		}
+	}
+	return on
+}
+
+// addSegment calls hit for each tile, widened by pad, that segment ab
+// passes through. Only tiles along the segment are tested: it is clipped
+// to each row's band, and the columns that part spans are the candidates.
+func (g tileGrid) addSegment(a, b Point, pad float64, hit func(r, c int)) {
+	// Candidates reach a little further so that rounding can't drop a
+	// tile the segment only just touches; segmentHitsRect decides
+	reach := pad + 1e-6
+
+	r0, r1 := indexRange(
+		math.Ceil((g.maxY-g.printH-reach-math.Max(a.Y, b.Y))/g.stepY),
+		math.Floor((g.maxY+reach-math.Min(a.Y, b.Y))/g.stepY), g.rows)
+	for r := r0; r <= r1; r++ {
+		y1 := g.maxY - float64(r)*g.stepY
+		top, bottom := y1+reach, y1-g.printH-reach
+
+		x0, x1 := math.Min(a.X, b.X), math.Max(a.X, b.X)
+		if dy := b.Y - a.Y; dy != 0 {
+			t0, t1 := (bottom-a.Y)/dy, (top-a.Y)/dy
+			if t0 > t1 {
+				t0, t1 = t1, t0
+			}
+			t0, t1 = math.Max(t0, 0), math.Min(t1, 1)
+			if t0 > t1 {
+				continue // the segment ends before the band
+			}
+			x0, x1 = a.X+t0*(b.X-a.X), a.X+t1*(b.X-a.X)
+			if x0 > x1 {
+				x0, x1 = x1, x0
+			}
+		}
+
+		c0, c1 := g.colRange(x0, x1, reach)
+		for c := c0; c <= c1; c++ {
+			x0 := g.minX + float64(c)*g.stepX
+			if segmentHitsRect(a, b, x0-pad, y1-g.printH-pad, x0+g.printW+pad, y1+pad) {
+				hit(r, c)
+			}
+		}
+	}
+}
+
+// crossRows records where segment ab crosses the line through each row's
+// tile centres
+func (g tileGrid) crossRows(a, b Point, crossings map[int][]float64) {
+	centre := g.maxY - g.printH/2
+	r0, r1 := indexRange(
+		math.Ceil((centre-math.Max(a.Y, b.Y))/g.stepY),
+		math.Floor((centre-math.Min(a.Y, b.Y))/g.stepY), g.rows)
+	for r := r0; r <= r1; r++ {
+		y := centre - float64(r)*g.stepY
+		if (a.Y > y) != (b.Y > y) {
+			crossings[r] = append(crossings[r], a.X+(y-a.Y)*(b.X-a.X)/(b.Y-a.Y))
+		}
+	}
+}
+
+// shapesOnTile returns the shapes whose footprint, edge lines included,
+// shows on the tile
+func shapesOnTile(shapes []Shape, tile PDFTile) []Shape {
+	pad := edgeLineCm
+	minX, minY, maxX, maxY := tile.MinX-pad, tile.MinY-pad, tile.MaxX+pad, tile.MaxY+pad
+
+	var on []Shape
+	for _, s := range shapes {
+		rings := [][]Point{s.Outline()}
+		if s.Closed {
+			rings = [][]Point{s.LeftEdge, s.RightEdge}
+		}
+
+		touches := false
+		for _, ring := range rings {
+			for i := 0; i < len(ring) && !touches; i++ {
+				touches = segmentHitsRect(ring[i], ring[(i+1)%len(ring)], minX, minY, maxX, maxY)
+			}
+		}
+		// No edge crosses the tile, but it may lie inside the band
+		if !touches {
+			cx, cy := (minX+maxX)/2, (minY+maxY)/2
+			for _, ring := range rings {
+				if pointInRing(ring, cx, cy) {
+					touches = !touches
+				}
+			}
+		}
+		if touches {
+			on = append(on, s)
+		}
+	}
+	return on
+}
+
+// segmentHitsRect reports whether segment ab has any point inside the
+// rectangle (Liang-Barsky clipping)
+func segmentHitsRect(a, b Point, minX, minY, maxX, maxY float64) bool {
+	t0, t1 := 0.0, 1.0
+	dx, dy := b.X-a.X, b.Y-a.Y
+	for _, edge := range [4][2]float64{
+		{-dx, a.X - minX},
+		{dx, maxX - a.X},
+		{-dy, a.Y - minY},
+		{dy, maxY - a.Y},
+	} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return false
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 {
+			t0 = math.Max(t0, t)
+		} else {
+			t1 = math.Min(t1, t)
+		}
+		if t0 > t1 {
+			return false
+		}
+	}
+	return true
+}
+
+// pointInRing is the even-odd test for a point in a closed polygon
+func pointInRing(ring []Point, x, y float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Y > y) != (b.Y > y) && x < a.X+(y-a.Y)*(b.X-a.X)/(b.Y-a.Y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func overviewPage(project *TrackProject, title string, shapes []Shape, plan *TilePlan, half, minX, minY, maxX, maxY float64) []byte {
+	var c pdfCanvas
+	pageW := plan.Paper.WidthCm * ptPerCm
+	pageH := plan.Paper.HeightCm * ptPerCm
+	margin := 1.5 * ptPerCm
+
+	bom := GenerateBOM(project)
+	orientation := "portrait"
+	if plan.Landscape {
+		orientation = "landscape"
+	}
+
+	y := pageH - margin - 16
+	c.text(pdfFontBold, 16, margin, y, title)
+	y -= 18
+	c.text(pdfFontRegular, 10, margin, y, fmt.Sprintf("%d pieces, %sm, %.0f x %.0f cm", bom.TotalPieces, bom.TotalLength, maxX-minX, maxY-minY))
+	y -= 14
+	c.text(pdfFontRegular, 10, margin, y, fmt.Sprintf("%d tiles (%d rows x %d columns) on %s %s, printed at 100%% scale",
+		len(plan.Tiles), plan.Rows, plan.Cols, plan.Paper.Name, orientation))
+
+	// Scaled drawing with the tile grid over it
+	areaTop := y - 20
+	areaH := pageH*0.55 - 20
+	areaW := pageW - 2*margin
+	scale := math.Min(areaW/(maxX-minX), areaH/(maxY-minY))
+	tf := pdfTransform{
+		scale: scale,
+		wx:    minX,
+		wy:    maxY,
+		px:    margin + (areaW-(maxX-minX)*scale)/2,
+		py:    areaTop,
+	}
+	drawTrack(&c, shapes, tf, half)
+
+	c.op("0.8 0 0 RG 0.5 w")
+	for _, tile := range plan.Tiles {
+		x0, y0 := tf.pt(Point{X: tile.MinX, Y: tile.MinY})
+		x1, y1 := tf.pt(Point{X: tile.MaxX, Y: tile.MaxY})
+		c.op("%s %s %s %s re S", num(x0), num(y0), num(x1-x0), num(y1-y0))
+	}
+	c.op("0.8 0 0 rg")
+	for _, tile := range plan.Tiles {
+		x0, _ := tf.pt(Point{X: tile.MinX, Y: tile.MinY})
+		_, y1 := tf.pt(Point{X: tile.MaxX, Y: tile.MaxY})
+		c.text(pdfFontBold, 8, x0+3, y1-10, tile.Name)
+	}
+	c.op("0 g")
+
+	// BOM table, flowing into more columns if needed
+	codes := make([]string, 0, len(bom.BOM))
+	for code := range bom.BOM {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	y = areaTop - areaH - 30
+	c.text(pdfFontBold, 12, margin, y, "Bill of materials")
+	top := y - 18
+	x := margin
+	y = top
+	for _, code := range codes {
+		if y < margin {
+			x += 5 * ptPerCm
+			y = top
+		}
+		c.text(pdfFontRegular, 10, x, y, pdfSafe(code))
+		c.text(pdfFontRegular, 10, x+3*ptPerCm, y, fmt.Sprintf("x %d", bom.BOM[code]))
+		y -= 14
+	}
+
+	return c.bytes()
+}
+
+func tilePage(title string, shapes []Shape, plan *TilePlan, tile PDFTile, half float64, opts PDFOptions) []byte {
+	var c pdfCanvas
+	m := opts.MarginCm * ptPerCm
+	w := (tile.MaxX - tile.MinX) * ptPerCm
+	h := (tile.MaxY - tile.MinY) * ptPerCm
+	tf := pdfTransform{scale: ptPerCm, wx: tile.MinX, wy: tile.MaxY, px: m, py: m + h}
+
+	// Everything on the floor is clipped to the printable area
+	c.op("q %s %s %s %s re W n", num(m), num(m), num(w), num(h))
+	drawTrack(&c, shapes, tf, half)
+
+	// Registration marks sit in the middle of each overlap strip, so the
+	// same mark is printed on every sheet that shares the strip
+	ov := opts.OverlapCm / 2
+	c.op("0 G 0.5 w")
+	for _, mx := range []float64{tile.MinX + ov, tile.MaxX - ov} {
+		for _, my := range []float64{tile.MinY + ov, tile.MaxY - ov} {
+			x, y := tf.pt(Point{X: mx, Y: my})
+			registrationMark(&c, x, y)
+		}
+	}
+	c.op("Q")
+
+	// Cut guide around the printable area
+	c.op("0.6 G 0.3 w [2 2] 0 d %s %s %s %s re S [] 0 d", num(m), num(m), num(w), num(h))
+
+	// Label and scale check, inside the printable area clear of the marks
+	c.op("0 g")
+	labelX := m + 1.5*ptPerCm
+	labelY := m + h - 0.8*ptPerCm
+	c.text(pdfFontBold, 14, labelX, labelY-6, tile.Name)
+	c.text(pdfFontRegular, 8, labelX+1.2*ptPerCm, labelY-5, fmt.Sprintf("row %d/%d, column %d/%d - %s - 1:1",
+		tile.Row+1, plan.Rows, tile.Col+1, plan.Cols, title))
+
+	barX := m + 1.5*ptPerCm
+	barY := m + 1*ptPerCm
+	c.op("0 G 1 w %s %s m %s %s l S", num(barX), num(barY), num(barX+10*ptPerCm), num(barY))
+	for i := 0; i <= 10; i++ {
+		tick := 3.0
+		if i%5 == 0 {
+			tick = 6
+		}
+		x := barX + float64(i)*ptPerCm
+		c.op("%s %s m %s %s l S", num(x), num(barY), num(x), num(barY+tick))
+	}
+	c.text(pdfFontRegular, 8, barX+10*ptPerCm+4, barY, "10 cm")
+
+	return c.bytes()
+}
+
+// drawTrack strokes the edge lines (at their real width when printed 1:1),
+// a dashed centreline and the special element markings
+func drawTrack(c *pdfCanvas, shapes []Shape, tf pdfTransform, half float64) {
+	c.op("1 j 1 J")
+	for _, s := range shapes {
+		switch s.Type {
+		case "stopline":
+			c.op("0 g")
+			c.path(tf, s.Outline(), true)
+			c.op("f")
+		case "obstacle":
+			c.op("0.61 0.64 0.69 rg")
+			c.path(tf, s.Outline(), true)
+			c.op("f")
+		case "zebra":
+			c.op("0 g")
+			for _, stripe := range zebraStripes(s, half) {
+				c.path(tf, stripe, true)
+				c.op("f")
+			}
+		}
+	}
+
+	c.op("0 G %s w", num(math.Max(edgeLineCm*tf.scale, 0.3)))
+	for _, s := range shapes {
+		for _, edge := range [][]Point{s.LeftEdge, s.RightEdge} {
+			c.path(tf, edge, s.Closed)
+			c.op("S")
+		}
+	}
+
+	c.op("0.5 G 0.5 w [6 4] 0 d")
+	for _, s := range shapes {
+		c.path(tf, s.Centreline, s.Closed)
+		c.op("S")
+	}
+	c.op("[] 0 d 0 g")
+}
+
+// registrationMark draws a crosshair in a circle centred on (x, y)
+func registrationMark(c *pdfCanvas, x, y float64) {
+	r := 0.4 * ptPerCm
+	arm := 0.8 * ptPerCm
+	c.op("%s %s m %s %s l S", num(x-arm), num(y), num(x+arm), num(y))
+	c.op("%s %s m %s %s l S", num(x), num(y-arm), num(x), num(y+arm))
+
+	// Four Bézier quarter circles
+	k := 0.5523 * r
+	c.op("%s %s m", num(x+r), num(y))
+	c.op("%s %s %s %s %s %s c", num(x+r), num(y+k), num(x+k), num(y+r), num(x), num(y+r))
+	c.op("%s %s %s %s %s %s c", num(x-k), num(y+r), num(x-r), num(y+k), num(x-r), num(y))
+	c.op("%s %s %s %s %s %s c", num(x-r), num(y-k), num(x-k), num(y-r), num(x), num(y-r))
+	c.op("%s %s %s %s %s %s c S", num(x+k), num(y-r), num(x+r), num(y-k), num(x+r), num(y))
+}
+
+// pdfTransform maps floor cm to page points: world (wx, wy) lands on page
+// (px, py), with y pointing up in both
+type pdfTransform struct {
+	scale  float64
+	wx, wy float64
+	px, py float64
+}
+
+func (tf pdfTransform) pt(p Point) (float64, float64) {
+	return tf.px + (p.X-tf.wx)*tf.scale, tf.py + (p.Y-tf.wy)*tf.scale
+}
+
+// Fonts available on every page: the standard Helvetica faces
+const (
+	pdfFontRegular = "F1"
+	pdfFontBold    = "F2"
+)
+
+// pdfCanvas builds a page content stream
+type pdfCanvas struct {
+	buf bytes.Buffer
+}
+
+func (c *pdfCanvas) op(format string, args ...interface{}) {
+	fmt.Fprintf(&c.buf, format, args...)
+	c.buf.WriteByte('\n')
+}
+
+func (c *pdfCanvas) path(tf pdfTransform, points []Point, closed bool) {
+	for i, p := range points {
+		x, y := tf.pt(p)
+		if i == 0 {
+			c.op("%s %s m", num(x), num(y))
+		} else {
+			c.op("%s %s l", num(x), num(y))
+		}
+	}
+	if closed && len(points) > 0 {
+		c.op("h")
+	}
+}
+
+func (c *pdfCanvas) text(font string, size, x, y float64, s string) {
+	c.op("BT /%s %s Tf %s %s Td (%s) Tj ET", font, num(size), num(x), num(y), pdfSafe(s))
+}
+
+func (c *pdfCanvas) bytes() []byte {
+	return c.buf.Bytes()
+}
+
+// isPDFText reports whether s can be shown with the standard fonts
+func isPDFText(s string) bool {
+	for _, r := range s {
+		if r < 0x20 || r > 0x7E {
+			return false
+		}
+	}
+	return true
+}
+
+// pdfSafe escapes a string literal, replacing anything the standard
+// fonts can't show
+func pdfSafe(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7E:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// pdfDocument writes objects as they are added and the cross-reference
+// table at the end. Objects 1-4 are the catalog, page tree and the two
+// fonts; the page tree is written last, once all pages are known.
+type pdfDocument struct {
+	w       *bufio.Writer
+	offset  int
+	offsets []int // by object number - 1
+	pages   []int
+}
+
+func newPDFDocument(out io.Writer) *pdfDocument {
+	d := &pdfDocument{w: bufio.NewWriter(out)}
+	d.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	d.add([]byte("<< /Type /Catalog /Pages 2 0 R >>"))
+	d.offsets = append(d.offsets, 0) // page tree, written by finish
+	d.add([]byte("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>"))
+	d.add([]byte("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>"))
+	return d
+}
+
+func (d *pdfDocument) printf(format string, args ...interface{}) {
+	n, _ := fmt.Fprintf(d.w, format, args...)
+	d.offset += n
+}
+
+// add writes the next object and returns its number
+func (d *pdfDocument) add(body []byte) int {
+	d.offsets = append(d.offsets, 0)
+	return d.put(len(d.offsets), body)
+}
+
+// put writes object n
+func (d *pdfDocument) put(n int, body []byte) int {
+	d.offsets[n-1] = d.offset
+	d.printf("%d 0 obj\n", n)
+	written, _ := d.w.Write(body)
+	d.offset += written
+	d.printf("\nendobj\n")
+	return n
+}
+
+func (d *pdfDocument) addPage(widthPt, heightPt float64, content []byte) {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(content)
+	zw.Close()
+
+	var stream bytes.Buffer
+	fmt.Fprintf(&stream, "<< /Length %d /Filter /FlateDecode >>\nstream\n", z.Len())
+	stream.Write(z.Bytes())
+	stream.WriteString("\nendstream")
+	contents := d.add(stream.Bytes())
+
+	page := d.add([]byte(fmt.Sprintf(
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+		num(widthPt), num(heightPt), pdfFontRegular, pdfFontBold, contents)))
+	d.pages = append(d.pages, page)
+}
+
+// finish writes the page tree, cross-reference table and trailer
+func (d *pdfDocument) finish() error {
+	kids := make([]string, len(d.pages))
+	for i, page := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+	}
+	d.put(2, []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages))))
+
+	xref := d.offset
+	d.printf("xref\n0 %d\n0000000000 65535 f \n", len(d.offsets)+1)
+	for _, off := range d.offsets {
+		d.printf("%010d 00000 n \n", off)
+	}
+	d.printf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(d.offsets)+1, xref)
+
+	return d.w.Flush()
+}
@@ -0,0 +1,201 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlanTiles(t *testing.T) {
+	// 70 x 30cm on A4 with 1cm margins and 2cm overlap: portrait steps are
+	// 17 x 25.7 (4 x 2 sheets), landscape 25.7 x 17 (3 x 2 sheets)
+	plan, err := PlanTiles(0, 0, 70, 30, PDFOptions{})
+	if err != nil {
+		t.Fatalf("PlanTiles() error = %v", err)
+	}
+	if !plan.Landscape || plan.Cols != 3 || plan.Rows != 2 || len(plan.Tiles) != 6 {
+		t.Fatalf("plan = landscape %v, %dx%d, %d tiles", plan.Landscape, plan.Rows, plan.Cols, len(plan.Tiles))
+	}
+
+	first, last := plan.Tiles[0], plan.Tiles[len(plan.Tiles)-1]
+	if first.Name != "A1" || last.Name != "B3" {
+		t.Errorf("tile names = %s..%s", first.Name, last.Name)
+	}
+	if !almostEqual(first.MinX, 0) || !almostEqual(first.MaxY, 30) {
+		t.Errorf("first tile = %+v", first)
+	}
+	if last.MaxX < 70 || last.MinY > 0 {
+		t.Errorf("last tile %+v doesn't reach the far corner", last)
+	}
+	// Neighbours share the overlap strip
+	if !almostEqual(first.MaxX-plan.Tiles[1].MinX, 2) {
+		t.Errorf("overlap = %v, want 2", first.MaxX-plan.Tiles[1].MinX)
+	}
+
+	if _, err := PlanTiles(0, 0, 100, 50, PDFOptions{Paper: "Letter"}); err == nil {
+		t.Error("expected error for unsupported paper")
+	}
+}
+
+func TestTileName(t *testing.T) {
+	for _, tc := range []struct {
+		row, col int
+		want     string
+	}{
+		{0, 0, "A1"}, {1, 4, "B5"}, {25, 0, "Z1"}, {26, 1, "AA2"},
+	} {
+		if got := tileName(tc.row, tc.col); got != tc.want {
+			t.Errorf("tileName(%d, %d) = %s, want %s", tc.row, tc.col, got, tc.want)
+		}
+	}
+}
+
+func TestRenderPDF(t *testing.T) {
+	project := figureEight()
+	project.ID = "fig8"
+	project.Name = "八字"
+
+	var buf bytes.Buffer
+	if err := RenderPDF(&buf, project, PDFOptions{Paper: "A3"}); err != nil {
+		t.Fatalf("RenderPDF() error = %v", err)
+	}
+	pdf := buf.String()
+
+	if !strings.HasPrefix(pdf, "%PDF-1.4") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatal("missing PDF header or trailer")
+	}
+
+	// startxref must point at the cross-reference table
+	i := strings.LastIndex(pdf, "startxref\n")
+	end := strings.Index(pdf[i+10:], "\n")
+	offset, err := strconv.Atoi(pdf[i+10 : i+10+end])
+	if err != nil {
+		t.Fatalf("bad startxref: %v", err)
+	}
+	if !strings.HasPrefix(pdf[offset:], "xref\n") {
+		t.Errorf("startxref %d doesn't point at xref", offset)
+	}
+
+	// Overview plus one page per tile with track on it
+	shapes, _ := TrackShapes(project)
+	minX, minY, maxX, maxY := ShapesBounds(shapes)
+	plan, _ := planTiles(minX-pdfPaddingCm, minY-pdfPaddingCm, maxX+pdfPaddingCm, maxY+pdfPaddingCm, PDFOptions{Paper: "A3"}, shapes)
+	if got, want := strings.Count(pdf, "/Type /Page /"), len(plan.Tiles)+1; got != want {
+		t.Errorf("got %d pages, want %d", got, want)
+	}
+	if !strings.Contains(pdf, "/Count "+strconv.Itoa(len(plan.Tiles)+1)) {
+		t.Error("page tree count doesn't match pages")
+	}
+}
+
+func TestRenderPDF_SkipsEmptyTiles(t *testing.T) {
+	// A 4m square loop: the sheets in the middle have no track on them
+	project := &TrackProject{
+		ID: "square",
+		Boundary: &Boundary{
+			Unit:   "cm",
+			Points: []Point{{X: 0, Y: 0}, {X: 400, Y: 0}, {X: 400, Y: 400}, {X: 0, Y: 400}},
+			Closed: true,
+		},
+	}
+	shapes, _ := TrackShapes(project)
+	minX, minY, maxX, maxY := ShapesBounds(shapes)
+	full, _ := PlanTiles(minX, minY, maxX, maxY, PDFOptions{})
+	kept, _ := planTiles(minX, minY, maxX, maxY, PDFOptions{}, shapes)
+	if len(kept.Tiles) >= len(full.Tiles) {
+		t.Fatalf("kept %d of %d tiles", len(kept.Tiles), len(full.Tiles))
+	}
+	for _, tile := range full.Tiles {
+		centre := (tile.MinX+tile.MaxX)/2 > 100 && (tile.MinX+tile.MaxX)/2 < 300 &&
+			(tile.MinY+tile.MaxY)/2 > 100 && (tile.MinY+tile.MaxY)/2 < 300
+		if centre && len(shapesOnTile(shapes, tile)) > 0 {
+			t.Errorf("tile %s in the middle of the loop has track on it", tile.Name)
+		}
+	}
+
+	// A tile entirely inside a wide band still has track on it
+	wide := []Shape{{
+		LeftEdge:  []Point{{X: 0, Y: 100}, {X: 1000, Y: 100}},
+		RightEdge: []Point{{X: 0, Y: -100}, {X: 1000, Y: -100}},
+	}}
+	if len(shapesOnTile(wide, PDFTile{MinX: 400, MinY: -10, MaxX: 420, MaxY: 10})) != 1 {
+		t.Error("tile inside the band was skipped")
+	}
+}
+
+// lTrack is n L10 straights, a quarter turn and n more L10 straights
+func lTrack(n int) *TrackProject {
+	pieces := make([]Piece, 0, 2*n+1)
+	for i := 0; i < 2*n+1; i++ {
+		pieces = append(pieces, Piece{ID: i, Type: "straight", Params: PieceParams{Length: 10}})
+	}
+	pieces[n] = Piece{ID: n, Type: "curve", Params: PieceParams{Radius: 50, Angle: 90}}
+	poses, _ := defaultCatalog.ChainPoses(pieces, Pose{})
+	for i := range pieces {
+		pieces[i] = defaultCatalog.placePiece(pieces[i], poses[i].Entry)
+	}
+	return &TrackProject{ID: "l", Pieces: pieces}
+}
+
+func TestShapesByTile(t *testing.T) {
+	square, _ := TrackShapes(&TrackProject{Boundary: &Boundary{
+		Unit:   "cm",
+		Points: []Point{{X: 0, Y: 0}, {X: 400, Y: 0}, {X: 400, Y: 400}, {X: 0, Y: 400}},
+		Closed: true,
+	}})
+	l, _ := TrackShapes(lTrack(100))
+	wide := []Shape{{
+		LeftEdge:  []Point{{X: 0, Y: 100}, {X: 300, Y: 100}},
+		RightEdge: []Point{{X: 0, Y: -100}, {X: 300, Y: -100}},
+	}}
+
+	// The index must agree with shapesOnTile on every tile
+	for name, shapes := range map[string][]Shape{"square loop": square, "L-shaped": l, "wide band": wide} {
+		minX, minY, maxX, maxY := ShapesBounds(shapes)
+		g := tileGrid{minX: minX - 5, maxY: maxY + 5, printW: 19, printH: 27.7, stepX: 17, stepY: 25.7}
+		g.cols = int(math.Ceil((maxX - minX + 10) / g.stepX))
+		g.rows = int(math.Ceil((maxY - minY + 10) / g.stepY))
+
+		on := g.shapesByTile(shapes)
+		for r := 0; r < g.rows; r++ {
+			for c := 0; c < g.cols; c++ {
+				tile := g.tile(r, c)
+				if got, want := len(on[r*g.cols+c]), len(shapesOnTile(shapes, tile)); got != want {
+					t.Errorf("%s: tile %s has %d shapes, want %d", name, tile.Name, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestRenderPDF_LongTrack(t *testing.T) {
+	// 4000 L10 pieces in an L: 200m each way spans far more grid than
+	// can be printed, and is turned down without visiting every tile
+	start := time.Now()
+	if err := RenderPDF(io.Discard, lTrack(2000), PDFOptions{}); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("400 m L-shaped track: error = %v, want too large to print", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("RenderPDF took %v", elapsed)
+	}
+
+	// 2 x 10m fits the grid; only the sheets along the L are printed
+	start = time.Now()
+	if err := RenderPDF(io.Discard, lTrack(100), PDFOptions{Paper: "A3"}); err != nil {
+		t.Errorf("20 m L-shaped track: error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("RenderPDF took %v", elapsed)
+	}
+}
+
+func TestRenderPDF_TooManyPages(t *testing.T) {
+	project := straights(300, 100)
+	if err := RenderPDF(io.Discard, project, PDFOptions{}); err == nil || !strings.Contains(err.Error(), "sheets") {
+		t.Errorf("300 m straight: error = %v, want too many sheets", err)
+	}
+}
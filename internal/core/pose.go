@@ -0,0 +1,299 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// PxPerCm is the editor canvas scale: piece X/Y are stored in px (1cm = 2px)
+const PxPerCm = 2.0
+
+// Pose is a point on the track centreline (cm) with a heading in degrees,
+// measured counter-clockwise from +X
+type Pose struct {
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	Heading float64 `json:"heading"`
+}
+
+// PiecePose is the solved entry and exit pose of one piece
+type PiecePose struct {
+	Index int         `json:"index"`
+	ID    interface{} `json:"id"`
+	Type  string      `json:"type"`
+	Entry Pose        `json:"entry"`
+	Exit  Pose        `json:"exit"`
+}
+
+// Joint describes how well one piece's exit meets the next piece's entry
+type Joint struct {
+	From         int     `json:"from"`
+	To           int     `json:"to"`
+	Gap          float64 `json:"gap"`          // cm
+	HeadingDelta float64 `json:"headingDelta"` // degrees, in (-180, 180]
+	Connected    bool    `json:"connected"`
+}
+
+// Tolerance bounds the gap and heading mismatch accepted at a joint
+type Tolerance struct {
+	GapCm      float64 `json:"gapCm"`
+	HeadingDeg float64 `json:"headingDeg"`
+}
+
+// DefaultTolerance is loose enough for hand-placed pieces in the editor
+var DefaultTolerance = Tolerance{GapCm: 1.0, HeadingDeg: 2.0}
+
+// ChainReport is the result of solving a piece sequence
+type ChainReport struct {
+	Poses     []PiecePose `json:"poses"`
+	Joints    []Joint     `json:"joints"`
+	Connected bool        `json:"connected"`
+}
+
+// PlacementPose returns the entry pose a piece was placed at in the editor.
+// For curves the editor stores the radial angle of the start point rather
+// than the heading, so it is converted here.
+func (c *Catalog) PlacementPose(piece Piece) Pose {
+	heading := piece.Rotation
+	if geometry, _ := c.geometryOf(piece.Type); geometry == GeometryArc {
+		heading += 90 * piece.Params.turnSign()
+	}
+	return Pose{
+		X:       piece.X / PxPerCm,
+		Y:       piece.Y / PxPerCm,
+		Heading: normalizeAngle(heading),
+	}
+}
+
+// AdvancePose computes where a piece ends when it starts at entry
+func (c *Catalog) AdvancePose(entry Pose, piece Piece) (Pose, error) {
+	geometry, err := c.geometryOf(piece.Type)
+	if err != nil {
+		return Pose{}, err
+	}
+	h := entry.Heading * math.Pi / 180.0
+
+	switch geometry {
+	case GeometryStraight, GeometryCrossing, GeometryRoundabout:
+		// Crossroads and roundabouts pass straight through on the main line
+		return Pose{
+			X:       entry.X + piece.Params.Length*math.Cos(h),
+			Y:       entry.Y + piece.Params.Length*math.Sin(h),
+			Heading: entry.Heading,
+		}, nil
+	case GeometryArc:
+		sign := piece.Params.turnSign()
+		turn := math.Abs(piece.Params.Angle) * sign
+		r := piece.Params.Radius
+
+		// Centre lies to the left (CCW) or right (CW) of the heading
+		cx := entry.X - sign*r*math.Sin(h)
+		cy := entry.Y + sign*r*math.Cos(h)
+
+		exitHeading := entry.Heading + turn
+		eh := exitHeading * math.Pi / 180.0
+		return Pose{
+			X:       cx + sign*r*math.Sin(eh),
+			Y:       cy - sign*r*math.Cos(eh),
+			Heading: normalizeAngle(exitHeading),
+		}, nil
+	default:
+		return Pose{}, fmt.Errorf("unsupported geometry %s for piece type %s", geometry, piece.Type)
+	}
+}
+
+// maxPieceLengthCm bounds a piece's length and radius. No real part comes
+// close; it keeps one piece from standing in for a kilometre of track.
+const maxPieceLengthCm = 10000.0
+
+// ErrInvalidPiece is returned for pieces whose size is zero, negative,
+// not a number or larger than any part
+var ErrInvalidPiece = errors.New("invalid piece")
+
+// checkPieceSize requires a piece dimension to be finite, positive and
+// within maxPieceLengthCm
+func checkPieceSize(piece Piece, name string, v float64) error {
+	if !(v > 0) || v > maxPieceLengthCm {
+		return fmt.Errorf("%w: %s %s %gcm must be above 0 and at most %gcm",
+			ErrInvalidPiece, piece.Type, name, v, maxPieceLengthCm)
+	}
+	return nil
+}
+
+// PieceLength is the centreline length of a piece in cm. It fails with
+// ErrInvalidPiece for pieces that can't be laid.
+func (c *Catalog) PieceLength(piece Piece) (float64, error) {
+	geometry, err := c.geometryOf(piece.Type)
+	if err != nil {
+		return 0, err
+	}
+
+	switch geometry {
+	case GeometryStraight, GeometryCrossing:
+		if err := checkPieceSize(piece, "length", piece.Params.Length); err != nil {
+			return 0, err
+		}
+		return piece.Params.Length, nil
+	case GeometryArc:
+		if err := checkPieceSize(piece, "radius", piece.Params.Radius); err != nil {
+			return 0, err
+		}
+		// Arc length = radius * angle_in_radians
+		length := piece.Params.Radius * math.Abs(piece.Params.Angle) * math.Pi / 180.0
+		if err := checkPieceSize(piece, "arc length", length); err != nil {
+			return 0, err
+		}
+		return length, nil
+	case GeometryRoundabout:
+		if err := checkPieceSize(piece, "length", piece.Params.Length); err != nil {
+			return 0, err
+		}
+		if err := checkPieceSize(piece, "radius", piece.Params.Radius); err != nil {
+			return 0, err
+		}
+		// The car drives the main line plus one lap of the island
+		return piece.Params.Length + 2*math.Pi*piece.Params.Radius, nil
+	default:
+		return 0, fmt.Errorf("unsupported geometry %s for piece type %s", geometry, piece.Type)
+	}
+}
+
+// laidLength is how far a piece carries the main line forward. For
+// roundabouts this excludes the lap of the island.
+func (c *Catalog) laidLength(piece Piece) (float64, error) {
+	length, err := c.PieceLength(piece)
+	if err != nil {
+		return 0, err
+	}
+	if geometry, _ := c.geometryOf(piece.Type); geometry == GeometryRoundabout {
+		return piece.Params.Length, nil
+	}
+	return length, nil
+}
+
+// PoseAt returns the pose s cm along a piece that starts at entry
+func (c *Catalog) PoseAt(entry Pose, piece Piece, s float64) (Pose, error) {
+	geometry, err := c.geometryOf(piece.Type)
+	if err != nil {
+		return Pose{}, err
+	}
+
+	partial := piece
+	switch geometry {
+	case GeometryStraight, GeometryCrossing, GeometryRoundabout:
+		partial.Params.Length = s
+	case GeometryArc:
+		if piece.Params.Radius <= 0 {
+			return entry, nil
+		}
+		deg := s / piece.Params.Radius * 180.0 / math.Pi
+		partial.Params.Angle = math.Copysign(deg, piece.Params.Angle)
+	}
+	return c.AdvancePose(entry, partial)
+}
+
+// ChainPoses lays the pieces end to end starting at start, ignoring where
+// they were placed in the editor
+func (c *Catalog) ChainPoses(pieces []Piece, start Pose) ([]PiecePose, error) {
+	poses := make([]PiecePose, 0, len(pieces))
+	entry := start
+
+	for i, piece := range pieces {
+		exit, err := c.AdvancePose(entry, piece)
+		if err != nil {
+			return nil, fmt.Errorf("piece %d: %w", i, err)
+		}
+		poses = append(poses, PiecePose{
+			Index: i,
+			ID:    piece.ID,
+			Type:  piece.Type,
+			Entry: entry,
+			Exit:  exit,
+		})
+		entry = exit
+
+		// Driving into a crossroads side port carries on out of the
+		// opposite one
+		if through, ok := c.passThroughCrossing(pieces, poses, exit, DefaultTolerance); ok {
+			entry = through
+		}
+	}
+
+	return poses, nil
+}
+
+// SolvePoses computes each piece's entry/exit pose from its placement and
+// parameters, and checks that consecutive pieces actually meet
+func SolvePoses(project *TrackProject, tol Tolerance) (*ChainReport, error) {
+	report := &ChainReport{
+		Poses:     make([]PiecePose, 0, len(project.Pieces)),
+		Joints:    []Joint{},
+		Connected: true,
+	}
+	c := project.Catalog()
+
+	for i, piece := range project.Pieces {
+		entry := c.PlacementPose(piece)
+		exit, err := c.AdvancePose(entry, piece)
+		if err != nil {
+			return nil, fmt.Errorf("piece %d: %w", i, err)
+		}
+		report.Poses = append(report.Poses, PiecePose{
+			Index: i,
+			ID:    piece.ID,
+			Type:  piece.Type,
+			Entry: entry,
+			Exit:  exit,
+		})
+	}
+
+	for i := 1; i < len(report.Poses); i++ {
+		exit := report.Poses[i-1].Exit
+		if through, ok := c.passThroughCrossing(project.Pieces, report.Poses, exit, tol); ok {
+			exit = through
+		}
+		joint := CompareJoint(exit, report.Poses[i].Entry, tol)
+		joint.From = i - 1
+		joint.To = i
+		if !joint.Connected {
+			report.Connected = false
+		}
+		report.Joints = append(report.Joints, joint)
+	}
+
+	return report, nil
+}
+
+// CompareJoint measures the gap and heading mismatch between an exit and
+// the entry it should meet
+func CompareJoint(exit, entry Pose, tol Tolerance) Joint {
+	gap := math.Hypot(entry.X-exit.X, entry.Y-exit.Y)
+	delta := normalizeAngle(entry.Heading - exit.Heading)
+
+	return Joint{
+		Gap:          gap,
+		HeadingDelta: delta,
+		Connected:    gap <= tol.GapCm && math.Abs(delta) <= tol.HeadingDeg,
+	}
+}
+
+// turnSign is +1 for a left (CCW) curve and -1 for a right (CW) one.
+// A negative angle or direction "right" both mean a right turn.
+func (p PieceParams) turnSign() float64 {
+	if p.Angle < 0 || p.Direction == "right" {
+		return -1
+	}
+	return 1
+}
+
+// normalizeAngle maps degrees into (-180, 180]
+func normalizeAngle(deg float64) float64 {
+	deg = math.Mod(deg, 360)
+	if deg > 180 {
+		deg -= 360
+	} else if deg <= -180 {
+		deg += 360
+	}
+	return deg
+}
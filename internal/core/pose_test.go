@@ -0,0 +1,133 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestAdvancePose(t *testing.T) {
+	tests := []struct {
+		name  string
+		piece Piece
+		want  Pose
+	}{
+		{
+			name:  "straight",
+			piece: Piece{Type: "straight", Params: PieceParams{Length: 50}},
+			want:  Pose{X: 50, Y: 0, Heading: 0},
+		},
+		{
+			name:  "left curve",
+			piece: Piece{Type: "curve", Params: PieceParams{Radius: 50, Angle: 90}},
+			want:  Pose{X: 50, Y: 50, Heading: 90},
+		},
+		{
+			name:  "right curve by direction",
+			piece: Piece{Type: "curve", Params: PieceParams{Radius: 50, Angle: 90, Direction: "right"}},
+			want:  Pose{X: 50, Y: -50, Heading: -90},
+		},
+		{
+			name:  "right curve by negative angle",
+			piece: Piece{Type: "curve", Params: PieceParams{Radius: 50, Angle: -90}},
+			want:  Pose{X: 50, Y: -50, Heading: -90},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := defaultCatalog.AdvancePose(Pose{}, tt.piece)
+			if err != nil {
+				t.Fatalf("AdvancePose() error = %v", err)
+			}
+			if !almostEqual(got.X, tt.want.X) || !almostEqual(got.Y, tt.want.Y) || !almostEqual(got.Heading, tt.want.Heading) {
+				t.Errorf("AdvancePose() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdvancePose_UnknownType(t *testing.T) {
+	if _, err := defaultCatalog.AdvancePose(Pose{}, Piece{Type: "teleporter"}); err == nil {
+		t.Error("expected error for unknown piece type")
+	}
+}
+
+func TestSolvePoses(t *testing.T) {
+	// L100 then R50-90 placed the way the editor stores them (px, radial rotation)
+	project := &TrackProject{
+		Pieces: []Piece{
+			{ID: 1, Type: "straight", Params: PieceParams{Length: 100}, X: 0, Y: 0, Rotation: 0},
+			{ID: 2, Type: "curve", Params: PieceParams{Radius: 50, Angle: 90}, X: 200, Y: 0, Rotation: -90},
+		},
+	}
+
+	report, err := SolvePoses(project, DefaultTolerance)
+	if err != nil {
+		t.Fatalf("SolvePoses() error = %v", err)
+	}
+	if !report.Connected {
+		t.Errorf("expected connected chain, joints = %+v", report.Joints)
+	}
+
+	// Move the curve 10cm away
+	project.Pieces[1].X = 220
+	report, err = SolvePoses(project, DefaultTolerance)
+	if err != nil {
+		t.Fatalf("SolvePoses() error = %v", err)
+	}
+	if report.Connected {
+		t.Error("expected gap to be reported")
+	}
+	if !almostEqual(report.Joints[0].Gap, 10) {
+		t.Errorf("gap = %v, want 10", report.Joints[0].Gap)
+	}
+}
+
+func TestCompareJoint_HeadingWraps(t *testing.T) {
+	joint := CompareJoint(Pose{Heading: 179}, Pose{Heading: -179}, DefaultTolerance)
+	if !almostEqual(joint.HeadingDelta, 2) {
+		t.Errorf("HeadingDelta = %v, want 2", joint.HeadingDelta)
+	}
+}
+
+func TestValidateClosure(t *testing.T) {
+	// Four R50-90 left curves make a full circle
+	circle := make([]Piece, 4)
+	for i := range circle {
+		circle[i] = Piece{ID: i, Type: "curve", Params: PieceParams{Radius: 50, Angle: 90}, Rotation: -90}
+	}
+
+	report, err := ValidateClosure(&TrackProject{Pieces: circle}, DefaultClosureOptions)
+	if err != nil {
+		t.Fatalf("ValidateClosure() error = %v", err)
+	}
+	if !report.Closed {
+		t.Errorf("expected circle to be closed, gap = %v heading = %v", report.Gap, report.HeadingDelta)
+	}
+
+	// Drop the last curve: the search should find R50-90 to close it
+	report, err = ValidateClosure(&TrackProject{Pieces: circle[:3]}, DefaultClosureOptions)
+	if err != nil {
+		t.Fatalf("ValidateClosure() error = %v", err)
+	}
+	if report.Closed {
+		t.Fatal("expected open loop")
+	}
+	if len(report.Suggestions) == 0 {
+		t.Fatal("expected closing suggestions")
+	}
+	first := report.Suggestions[0]
+	if len(first.Codes) != 1 || first.Codes[0] != "R50-90" {
+		t.Errorf("first suggestion = %v, want [R50-90]", first.Codes)
+	}
+}
+
+func TestValidateClosure_Empty(t *testing.T) {
+	if _, err := ValidateClosure(&TrackProject{}, DefaultClosureOptions); err == nil {
+		t.Error("expected error for empty project")
+	}
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// PriceList prices the parts of a BOM plus materials bought by the metre
+type PriceList struct {
+	Currency  string             `json:"currency"`
+	Parts     map[string]float64 `json:"parts"` // unit price by BOM code
+	Materials []MaterialPrice    `json:"materials"`
+}
+
+// MaterialPrice is something bought by the metre of track, e.g. mat or
+// edge tape
+type MaterialPrice struct {
+	Name          string  `json:"name"`
+	PricePerMetre float64 `json:"pricePerMetre"`
+	// Metres needed per metre of track, default 1 (edge tape on both
+	// sides is 2)
+	PerTrackMetre float64 `json:"perTrackMetre,omitempty"`
+}
+
+// CostLine is one priced row of a BOM
+type CostLine struct {
+	Code      string  `json:"code,omitempty"` // BOM code, empty for materials
+	Name      string  `json:"name"`
+	Quantity  float64 `json:"quantity"`
+	Unit      string  `json:"unit"` // "pcs" or "m"
+	UnitPrice float64 `json:"unitPrice"`
+	Total     float64 `json:"total"`
+	Priced    bool    `json:"priced"` // false if the price list doesn't have it
+}
+
+// BOMCost is the priced BOM
+type BOMCost struct {
+	Currency string     `json:"currency"`
+	Lines    []CostLine `json:"lines"`
+	Total    float64    `json:"total"`
+	Unpriced []string   `json:"unpriced,omitempty"` // part codes without a price
+}
+
+// LoadPriceList reads a price list file. A missing file is not an error:
+// it returns nil and BOMs go unpriced.
+func LoadPriceList(path string) (*PriceList, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list PriceList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid price list %s: %w", path, err)
+	}
+	for code, price := range list.Parts {
+		if price < 0 {
+			return nil, fmt.Errorf("invalid price list %s: negative price for %s", path, code)
+		}
+	}
+	for _, m := range list.Materials {
+		if m.Name == "" || m.PricePerMetre < 0 || m.PerTrackMetre < 0 {
+			return nil, fmt.Errorf("invalid price list %s: material %q needs a name and non-negative prices", path, m.Name)
+		}
+	}
+	return &list, nil
+}
+
+// PriceBOM prices each part and the materials for the track length.
+// Parts missing from the list are listed at zero and reported in Unpriced.
+func PriceBOM(bom *BOMSummary, list *PriceList) *BOMCost {
+	catalog := bom.partsCatalog()
+	cost := &BOMCost{Currency: list.Currency, Lines: []CostLine{}}
+
+	codes := make([]string, 0, len(bom.BOM))
+	for code := range bom.BOM {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	for _, code := range codes {
+		line := CostLine{Code: code, Name: code, Quantity: float64(bom.BOM[code]), Unit: "pcs"}
+		if entry, ok := catalog.Entry(code); ok {
+			line.Name = entry.Name
+		}
+		line.UnitPrice, line.Priced = list.Parts[code]
+		if !line.Priced {
+			cost.Unpriced = append(cost.Unpriced, code)
+		}
+		line.Total = roundTo(line.Quantity*line.UnitPrice, 2)
+		cost.Lines = append(cost.Lines, line)
+		cost.Total += line.Total
+	}
+
+	metres, _ := strconv.ParseFloat(bom.TotalLength, 64)
+	for _, m := range list.Materials {
+		per := m.PerTrackMetre
+		if per == 0 {
+			per = 1
+		}
+		line := CostLine{
+			Name:      m.Name,
+			Quantity:  roundTo(metres*per, 2),
+			Unit:      "m",
+			UnitPrice: m.PricePerMetre,
+			Priced:    true,
+		}
+		line.Total = roundTo(line.Quantity*line.UnitPrice, 2)
+		cost.Lines = append(cost.Lines, line)
+		cost.Total += line.Total
+	}
+
+	cost.Total = math.Round(cost.Total*100) / 100
+	return cost
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPriceList(t *testing.T) {
+	dir := t.TempDir()
+
+	list, err := LoadPriceList(filepath.Join(dir, "prices.json"))
+	if err != nil || list != nil {
+		t.Fatalf("missing file = %v, %v; want nil, nil", list, err)
+	}
+
+	path := filepath.Join(dir, "prices.json")
+	os.WriteFile(path, []byte(`{"currency": "CNY", "parts": {"L50": -1}}`), 0644)
+	if _, err := LoadPriceList(path); err == nil {
+		t.Error("expected error for a negative price")
+	}
+}
+
+func TestPriceBOM(t *testing.T) {
+	lab := DefaultLab()
+	lab.Prices = &PriceList{
+		Currency: "CNY",
+		Parts:    map[string]float64{"R50-90": 12.5, "X45": 40},
+		Materials: []MaterialPrice{
+			{Name: "Edge tape", PricePerMetre: 0.8, PerTrackMetre: 2},
+		},
+	}
+
+	if GenerateBOM(figureEight()).Cost != nil {
+		t.Error("GenerateBOM priced the BOM without a price list")
+	}
+
+	bom := lab.GenerateBOM(figureEight())
+	cost := bom.Cost
+	if cost == nil {
+		t.Fatal("expected a priced BOM")
+	}
+
+	// 6 × R50-90 + 1 × X45, L27.5 unpriced, plus 2 × 6.26m of edge tape
+	if len(cost.Unpriced) != 1 || cost.Unpriced[0] != "L27.5" {
+		t.Errorf("unpriced = %v", cost.Unpriced)
+	}
+	tape := cost.Lines[len(cost.Lines)-1]
+	if tape.Name != "Edge tape" || tape.Unit != "m" || tape.Quantity != 12.52 {
+		t.Errorf("tape line = %+v", tape)
+	}
+	if want := 6*12.5 + 40 + tape.Total; cost.Total != want {
+		t.Errorf("total = %v, want %v", cost.Total, want)
+	}
+
+	var csvOut bytes.Buffer
+	if err := WriteBOMCSV(&csvOut, "Eight", bom); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Track,Eight\n", "R50-90,R50-90° (半径50cm),6,pcs,12.5,75\n", "L27.5,L27.5,4,pcs,,\n", "Total,,,,,"} {
+		if !strings.Contains(csvOut.String(), want) {
+			t.Errorf("CSV missing %q:\n%s", want, csvOut.String())
+		}
+	}
+
+	var xlsx bytes.Buffer
+	if err := WriteBOMXLSX(&xlsx, "Eight & co", bom); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(xlsx.Bytes()), int64(xlsx.Len()))
+	if err != nil {
+		t.Fatalf("XLSX is not a zip: %v", err)
+	}
+	var sheet string
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, _ := f.Open()
+			data, _ := io.ReadAll(rc)
+			sheet = string(data)
+		}
+	}
+	if !strings.Contains(sheet, "<t>Eight &amp; co</t>") || !strings.Contains(sheet, `<c r="C6"><v>6</v></c>`) {
+		t.Errorf("unexpected sheet:\n%s", sheet)
+	}
+}
+
+func TestBOMExport_UntrustedNames(t *testing.T) {
+	bom := GenerateBOM(figureEight())
+
+	for _, name := range []string{`=HYPERLINK("http://evil.example","x")`, "+1", "-2+3", "@SUM(A1)"} {
+		var out bytes.Buffer
+		if err := WriteBOMCSV(&out, name, bom); err != nil {
+			t.Fatal(err)
+		}
+		r := csv.NewReader(&out)
+		r.FieldsPerRecord = -1
+		records, err := r.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := records[0][1]; got != "'"+name {
+			t.Errorf("CSV name cell = %q, want it quoted as text", got)
+		}
+	}
+
+	// Text that only contains an operator is unchanged
+	if got := csvText("Lab = fun"); got != "Lab = fun" {
+		t.Errorf("csvText changed safe text: %q", got)
+	}
+
+	var xlsx bytes.Buffer
+	if err := WriteBOMXLSX(&xlsx, "Lab\x01 track\x0b 🏁 \xff<", bom); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(xlsx.Bytes()), int64(xlsx.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		if err := xml.Unmarshal(data, new(struct{})); err != nil {
+			t.Errorf("sheet isn't well-formed XML: %v", err)
+		}
+		if want := "<t>Lab track 🏁 \uFFFD&lt;</t>"; !strings.Contains(string(data), want) {
+			t.Errorf("sheet missing %q:\n%s", want, data)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rasterSubsamples is the number of scanlines sampled per pixel row for
+// anti-aliasing; horizontal coverage is computed exactly
+const rasterSubsamples = 4
+
+// ErrInvalidOption is returned for render options out of range, e.g. a
+// NaN or too fine a resolution, so handlers can tell them from bad tracks
+var ErrInvalidOption = errors.New("invalid option")
+
+// DefaultMaxRasterPixels caps the images rendered from a track unless the
+// options set their own MaxPixels. 16M pixels is 64 MB as RGBA, a 4 m x 4 m
+// floor at the default texture resolution.
+const DefaultMaxRasterPixels = 16_000_000
+
+// checkScale rejects a resolution-like option that isn't a finite number
+// above zero
+func checkScale(name string, v float64) error {
+	if !(v > 0) || math.IsInf(v, 0) {
+		return fmt.Errorf("%w: %s must be a positive number, got %g", ErrInvalidOption, name, v)
+	}
+	return nil
+}
+
+// rasterSize is how many cells of 1/perCm cm cover widthCm x heightCm,
+// at most maxPixels in all (DefaultMaxRasterPixels if 0). Each side is
+// checked on its own before multiplying so huge sizes can't overflow past
+// the cap.
+func rasterSize(widthCm, heightCm, perCm float64, maxPixels int) (int, int, error) {
+	if maxPixels <= 0 {
+		maxPixels = DefaultMaxRasterPixels
+	}
+	limit := float64(maxPixels)
+	fw := math.Ceil(widthCm * perCm)
+	fh := math.Ceil(heightCm * perCm)
+	if !(fw <= limit) || !(fh <= limit) || fw*fh > limit {
+		return 0, 0, fmt.Errorf("%w: image would be %.0fx%.0f, over the %d pixel limit; use a coarser resolution",
+			ErrInvalidOption, fw, fh, maxPixels)
+	}
+	return int(fw), int(fh), nil
+}
+
+// Raster paints shapes in floor centimetres onto an RGBA image
+type Raster struct {
+	img   *image.RGBA
+	scale float64 // pixels per cm
+	wx    float64 // floor x at the left edge of the image
+	wy    float64 // floor y at the top edge of the image
+}
+
+// NewRaster creates a w x h image filled with bg, mapping floor (wx, wy) to
+// the top-left pixel at scale pixels per cm
+func NewRaster(w, h int, scale, wx, wy float64, bg color.RGBA) *Raster {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = bg.R, bg.G, bg.B, 255
+	}
+	return &Raster{img: img, scale: scale, wx: wx, wy: wy}
+}
+
+// Image returns the painted image
+func (r *Raster) Image() *image.RGBA {
+	return r.img
+}
+
+// Fill paints the area inside the given rings using the even-odd rule, so
+// a ring inside another one cuts a hole
+func (r *Raster) Fill(rings [][]Point, c color.RGBA) {
+	r.FillAll([][][]Point{rings}, c)
+}
+
+// FillAll paints the union of several areas (each a set of even-odd rings)
+// in one pass, so there are no anti-aliasing seams where they touch
+func (r *Raster) FillAll(paths [][][]Point, c color.RGBA) {
+	// Pixel coordinates and bounding box
+	px := make([][][]Point, 0, len(paths))
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, rings := range paths {
+		var path [][]Point
+		for _, ring := range rings {
+			if len(ring) < 3 {
+				continue
+			}
+			pts := make([]Point, len(ring))
+			for i, p := range ring {
+				x, y := (p.X-r.wx)*r.scale, (r.wy-p.Y)*r.scale
+				pts[i] = Point{X: x, Y: y}
+				minX, minY = math.Min(minX, x), math.Min(minY, y)
+				maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+			}
+			path = append(path, pts)
+		}
+		if len(path) > 0 {
+			px = append(px, path)
+		}
+	}
+	if len(px) == 0 {
+		return
+	}
+
+	bounds := r.img.Bounds()
+	x0 := int(math.Max(math.Floor(minX), 0))
+	y0 := int(math.Max(math.Floor(minY), 0))
+	x1 := int(math.Min(math.Ceil(maxX), float64(bounds.Dx())))
+	y1 := int(math.Min(math.Ceil(maxY), float64(bounds.Dy())))
+	if x0 >= x1 || y0 >= y1 {
+		return
+	}
+
+	coverage := make([]float64, x1-x0)
+	var xs []float64
+	weight := 1.0 / rasterSubsamples
+	for row := y0; row < y1; row++ {
+		for i := range coverage {
+			coverage[i] = 0
+		}
+
+		for s := 0; s < rasterSubsamples; s++ {
+			y := float64(row) + (float64(s)+0.5)*weight
+			for _, path := range px {
+				xs = xs[:0]
+				for _, ring := range path {
+					for i := range ring {
+						a, b := ring[i], ring[(i+1)%len(ring)]
+						if (a.Y <= y) != (b.Y <= y) {
+							xs = append(xs, a.X+(y-a.Y)*(b.X-a.X)/(b.Y-a.Y))
+						}
+					}
+				}
+				sort.Float64s(xs)
+				for i := 0; i+1 < len(xs); i += 2 {
+					addSpan(coverage, xs[i]-float64(x0), xs[i+1]-float64(x0), weight)
+				}
+			}
+		}
+
+		for i, a := range coverage {
+			if a > 0 {
+				r.blend(x0+i, row, c, math.Min(a, 1))
+			}
+		}
+	}
+}
+
+// Stroke paints a line of the given width (cm) along a polyline
+func (r *Raster) Stroke(points []Point, closed bool, widthCm float64, c color.RGBA) {
+	r.FillAll([][][]Point{strokeRings(points, closed, widthCm)}, c)
+}
+
+// strokeRings is the outline of a line of the given width along a polyline
+func strokeRings(points []Point, closed bool, widthCm float64) [][]Point {
+	if len(points) < 2 {
+		return nil
+	}
+	left, right := offsetPolyline(points, closed, widthCm/2)
+	if closed {
+		return [][]Point{left, right}
+	}
+	return [][]Point{Shape{LeftEdge: left, RightEdge: right}.Outline()}
+}
+
+// addSpan adds weight times the covered fraction of each cell in [a, b)
+func addSpan(coverage []float64, a, b, weight float64) {
+	n := float64(len(coverage))
+	a, b = math.Max(a, 0), math.Min(b, n)
+	if a >= b {
+		return
+	}
+	ia, ib := int(a), int(b)
+	if ia == ib {
+		coverage[ia] += (b - a) * weight
+		return
+	}
+	coverage[ia] += (float64(ia+1) - a) * weight
+	for i := ia + 1; i < ib; i++ {
+		coverage[i] += weight
+	}
+	if ib < len(coverage) {
+		coverage[ib] += (b - float64(ib)) * weight
+	}
+}
+
+func (r *Raster) blend(x, y int, c color.RGBA, alpha float64) {
+	alpha *= float64(c.A) / 255
+	i := r.img.PixOffset(x, y)
+	pix := r.img.Pix[i : i+3 : i+3]
+	pix[0] = uint8(float64(c.R)*alpha + float64(pix[0])*(1-alpha) + 0.5)
+	pix[1] = uint8(float64(c.G)*alpha + float64(pix[1])*(1-alpha) + 0.5)
+	pix[2] = uint8(float64(c.B)*alpha + float64(pix[2])*(1-alpha) + 0.5)
+}
+
+// RasterStyle is how the track is painted
+type RasterStyle struct {
+	Floor      color.RGBA
+	Track      color.RGBA
+	Edge       color.RGBA
+	EdgeLineCm float64
+}
+
+// DefaultRasterStyle uses the editor colours and the skin's track colour
+func DefaultRasterStyle(project *TrackProject) RasterStyle {
+	style := RasterStyle{
+		Floor:      mustHexColor(DefaultFloorColor),
+		Track:      mustHexColor(DefaultTrackColor),
+		Edge:       mustHexColor(DefaultEdgeColor),
+		EdgeLineCm: edgeLineCm,
+	}
+	if project.Skin != nil {
+		if c, err := ParseHexColor(project.Skin.Color); err == nil {
+			style.Track = c
+		}
+	}
+	return style
+}
+
+// paintTrack draws the track surface, markings and edge lines
+func paintTrack(r *Raster, shapes []Shape, half float64, style RasterStyle) {
+	obstacle := mustHexColor("#9CA3AF")
+
+	var surface, markings, obstacles, edges [][][]Point
+	for _, s := range shapes {
+		switch {
+		case s.Type == "stopline":
+			markings = append(markings, [][]Point{s.Outline()})
+		case s.Type == "obstacle":
+			obstacles = append(obstacles, [][]Point{s.Outline()})
+		case s.Closed:
+			surface = append(surface, [][]Point{s.LeftEdge, s.RightEdge})
+		default:
+			surface = append(surface, [][]Point{s.Outline()})
+		}
+		if s.Type == "zebra" {
+			for _, stripe := range zebraStripes(s, half) {
+				markings = append(markings, [][]Point{stripe})
+			}
+		}
+	}
+
+	// Edge lines are at least a pixel wide so they survive downscaling
+	width := math.Max(style.EdgeLineCm, 1/r.scale)
+	for _, s := range shapes {
+		edges = append(edges, strokeRings(s.LeftEdge, s.Closed, width), strokeRings(s.RightEdge, s.Closed, width))
+	}
+
+	r.FillAll(surface, style.Track)
+	r.FillAll(obstacles, obstacle)
+	r.FillAll(markings, style.Edge)
+	r.FillAll(edges, style.Edge)
+}
+
+// Thumbnail size in pixels
+const (
+	ThumbnailWidth  = 320
+	ThumbnailHeight = 240
+)
+
+// RenderThumbnail draws a small PNG preview of the track, fitted and
+// centred on the floor colour
+func RenderThumbnail(out io.Writer, project *TrackProject, width, height int) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid thumbnail size %dx%d", width, height)
+	}
+
+	shapes, err := TrackShapes(project)
+	if err != nil {
+		return err
+	}
+	style := DefaultRasterStyle(project)
+
+	// 5% margin on each side
+	minX, minY, maxX, maxY := ShapesBounds(shapes)
+	scale := 1.0
+	if maxX > minX && maxY > minY {
+		scale = math.Min(float64(width)/(maxX-minX), float64(height)/(maxY-minY)) * 0.9
+	}
+	wx := (minX+maxX)/2 - float64(width)/2/scale
+	wy := (minY+maxY)/2 + float64(height)/2/scale
+
+	r := NewRaster(width, height, scale, wx, wy, style.Floor)
+	paintTrack(r, shapes, TrackWidth(project)/2, style)
+
+	return png.Encode(out, r.Image())
+}
+
+// ParseHexColor parses "#RRGGBB" or "#RGB"
+func ParseHexColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid colour: %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid colour: %q", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
+
+func mustHexColor(s string) color.RGBA {
+	c, err := ParseHexColor(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
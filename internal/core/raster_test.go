@@ -0,0 +1,89 @@
+package core
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestRasterFill(t *testing.T) {
+	black := color.RGBA{A: 255}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+	// 1 px per cm, floor (0, 4) at the top-left; square covers x 1..3 and
+	// half of the column at x = 3
+	r := NewRaster(5, 4, 1, 0, 4, black)
+	r.Fill([][]Point{{{X: 1, Y: 1}, {X: 3.5, Y: 1}, {X: 3.5, Y: 3}, {X: 1, Y: 3}}}, white)
+
+	img := r.Image()
+	for _, tc := range []struct {
+		x, y int
+		want uint8
+	}{
+		{0, 2, 0},   // outside
+		{1, 1, 255}, // inside
+		{2, 2, 255},
+		{3, 2, 128}, // half covered
+		{1, 0, 0},   // above
+	} {
+		if got := img.RGBAAt(tc.x, tc.y).R; got != tc.want {
+			t.Errorf("pixel (%d, %d) = %d, want %d", tc.x, tc.y, got, tc.want)
+		}
+	}
+
+	// A ring inside a ring leaves a hole
+	r = NewRaster(10, 10, 1, 0, 10, black)
+	r.Fill([][]Point{
+		{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}},
+		{{X: 3, Y: 3}, {X: 7, Y: 3}, {X: 7, Y: 7}, {X: 3, Y: 7}},
+	}, white)
+	if got := r.Image().RGBAAt(5, 5).R; got != 0 {
+		t.Errorf("hole pixel = %d, want 0", got)
+	}
+	if got := r.Image().RGBAAt(1, 1).R; got != 255 {
+		t.Errorf("ring pixel = %d, want 255", got)
+	}
+}
+
+func TestRenderThumbnail(t *testing.T) {
+	var buf bytes.Buffer
+	if err := RenderThumbnail(&buf, figureEight(), ThumbnailWidth, ThumbnailHeight); err != nil {
+		t.Fatalf("RenderThumbnail() error = %v", err)
+	}
+
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != ThumbnailWidth || b.Dy() != ThumbnailHeight {
+		t.Fatalf("size = %v", b)
+	}
+
+	floor := mustHexColor(DefaultFloorColor)
+	if got := color.RGBAModel.Convert(img.At(0, 0)).(color.RGBA); got != floor {
+		t.Errorf("corner = %v, want floor %v", got, floor)
+	}
+
+	counts := map[color.RGBA]int{}
+	for y := 0; y < ThumbnailHeight; y++ {
+		for x := 0; x < ThumbnailWidth; x++ {
+			counts[color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)]++
+		}
+	}
+	if counts[mustHexColor(DefaultTrackColor)] == 0 || counts[mustHexColor(DefaultEdgeColor)] == 0 {
+		t.Errorf("expected track and edge pixels, got %d colours", len(counts))
+	}
+}
+
+func TestParseHexColor(t *testing.T) {
+	if c, err := ParseHexColor("#1E3A8A"); err != nil || c != (color.RGBA{R: 0x1E, G: 0x3A, B: 0x8A, A: 255}) {
+		t.Errorf("ParseHexColor(#1E3A8A) = %v, %v", c, err)
+	}
+	if c, err := ParseHexColor("#fff"); err != nil || c != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("ParseHexColor(#fff) = %v, %v", c, err)
+	}
+	if _, err := ParseHexColor("blue"); err == nil {
+		t.Error("expected error for named colour")
+	}
+}
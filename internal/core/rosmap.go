@@ -0,0 +1,136 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+)
+
+// Occupancy values in the PGM, as map_server reads them in trinary mode
+const (
+	ROSMapFree     = 254
+	ROSMapOccupied = 0
+)
+
+// ROSMapOptions controls the occupancy grid
+type ROSMapOptions struct {
+	ResolutionM float64 // metres per cell, default 0.01
+	MarginCm    float64 // occupied border around the track, default 20
+	MaxPixels   int     // grid size cap, default DefaultMaxRasterPixels
+}
+
+// ROSMap is an occupancy grid of the drivable area. Row 0 is the top of
+// the image; Origin is the floor position of the bottom-left cell in metres.
+type ROSMap struct {
+	Width       int
+	Height      int
+	Cells       []byte // Width*Height, ROSMapFree or ROSMapOccupied
+	ResolutionM float64
+	OriginX     float64
+	OriginY     float64
+}
+
+// RenderROSMap rasterises the track surface (the skin's track width) as
+// free space and everything else as occupied
+func RenderROSMap(project *TrackProject, opts ROSMapOptions) (*ROSMap, error) {
+	if opts.ResolutionM == 0 {
+		opts.ResolutionM = 0.01
+	}
+	if err := checkScale("resolution", opts.ResolutionM); err != nil {
+		return nil, err
+	}
+	if opts.MarginCm <= 0 {
+		opts.MarginCm = 20
+	}
+
+	shapes, err := TrackShapes(project)
+	if err != nil {
+		return nil, err
+	}
+	if len(shapes) == 0 {
+		return nil, fmt.Errorf("track has no pieces or boundary to draw")
+	}
+
+	minX, minY, maxX, maxY := ShapesBounds(shapes)
+	minX -= opts.MarginCm
+	minY -= opts.MarginCm
+	maxX += opts.MarginCm
+	maxY += opts.MarginCm
+
+	cellCm := opts.ResolutionM * 100
+	w, h, err := rasterSize(maxX-minX, maxY-minY, 1/cellCm, opts.MaxPixels)
+	if err != nil {
+		return nil, err
+	}
+
+	// The top edge is placed so the bottom-left cell starts exactly at minY
+	top := minY + float64(h)*cellCm
+	black := color.RGBA{A: 255}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	r := NewRaster(w, h, 1/cellCm, minX, top, black)
+
+	var free, blocked [][][]Point
+	for _, s := range shapes {
+		switch {
+		case s.Type == "obstacle":
+			blocked = append(blocked, [][]Point{s.Outline()})
+		case s.Closed:
+			free = append(free, [][]Point{s.LeftEdge, s.RightEdge})
+		default:
+			free = append(free, [][]Point{s.Outline()})
+		}
+	}
+	r.FillAll(free, white)
+	r.FillAll(blocked, black)
+
+	m := &ROSMap{
+		Width:       w,
+		Height:      h,
+		Cells:       make([]byte, w*h),
+		ResolutionM: opts.ResolutionM,
+		OriginX:     minX / 100,
+		OriginY:     minY / 100,
+	}
+	img := r.Image()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// A cell is free only if it's mostly on the track
+			if img.Pix[img.PixOffset(x, y)] >= 128 {
+				m.Cells[y*w+x] = ROSMapFree
+			} else {
+				m.Cells[y*w+x] = ROSMapOccupied
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// WritePGM writes the grid as a binary (P5) PGM
+func (m *ROSMap) WritePGM(out io.Writer) error {
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "P5\n# track-designer occupancy grid, %gm/cell\n%d %d\n255\n", m.ResolutionM, m.Width, m.Height)
+	w.Write(m.Cells)
+	return w.Flush()
+}
+
+// WriteYAML writes the map_server metadata, pointing at image (the PGM
+// file name, relative to the YAML)
+func (m *ROSMap) WriteYAML(out io.Writer, image string) error {
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "image: %s\n", image)
+	fmt.Fprintf(w, "mode: trinary\n")
+	fmt.Fprintf(w, "resolution: %g\n", m.ResolutionM)
+	fmt.Fprintf(w, "origin: [%g, %g, 0.0]\n", roundTo(m.OriginX, 4), roundTo(m.OriginY, 4))
+	fmt.Fprintf(w, "negate: 0\n")
+	fmt.Fprintf(w, "occupied_thresh: 0.65\n")
+	fmt.Fprintf(w, "free_thresh: 0.25\n")
+	return w.Flush()
+}
+
+func roundTo(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestRenderROSMap(t *testing.T) {
+	project := &TrackProject{
+		Skin: &TrackSkin{TrackWidthCm: 40},
+		Pieces: []Piece{
+			{ID: "a", Type: "straight", Params: PieceParams{Length: 100}},
+		},
+	}
+
+	m, err := RenderROSMap(project, ROSMapOptions{})
+	if err != nil {
+		t.Fatalf("RenderROSMap() error = %v", err)
+	}
+
+	// 100 x 40cm of track plus a 20cm margin on every side, at 1cm per cell
+	if m.Width != 140 || m.Height != 80 {
+		t.Fatalf("size = %dx%d, want 140x80", m.Width, m.Height)
+	}
+	if !almostEqual(m.OriginX, -0.2) || !almostEqual(m.OriginY, -0.4) {
+		t.Errorf("origin = (%v, %v), want (-0.2, -0.4)", m.OriginX, m.OriginY)
+	}
+
+	cell := func(xCm, yCm float64) byte {
+		x := int((xCm/100 - m.OriginX) / m.ResolutionM)
+		y := m.Height - 1 - int((yCm/100-m.OriginY)/m.ResolutionM)
+		return m.Cells[y*m.Width+x]
+	}
+	for _, tc := range []struct {
+		x, y float64
+		want byte
+	}{
+		{50, 0, ROSMapFree},
+		{50, 19, ROSMapFree},
+		{50, -19, ROSMapFree},
+		{50, 21, ROSMapOccupied},
+		{-10, 0, ROSMapOccupied},
+		{110, 0, ROSMapOccupied},
+	} {
+		if got := cell(tc.x, tc.y); got != tc.want {
+			t.Errorf("cell at (%v, %v) = %d, want %d", tc.x, tc.y, got, tc.want)
+		}
+	}
+
+	var pgm bytes.Buffer
+	if err := m.WritePGM(&pgm); err != nil {
+		t.Fatalf("WritePGM() error = %v", err)
+	}
+	if !strings.HasPrefix(pgm.String(), "P5\n") || !strings.Contains(pgm.String(), "\n140 80\n255\n") {
+		t.Errorf("bad PGM header: %q", pgm.String()[:40])
+	}
+	if want := m.Width * m.Height; !bytes.HasSuffix(pgm.Bytes(), m.Cells) || pgm.Len() < want {
+		t.Errorf("PGM doesn't end with the %d cells", want)
+	}
+
+	var yaml bytes.Buffer
+	if err := m.WriteYAML(&yaml, "track.pgm"); err != nil {
+		t.Fatalf("WriteYAML() error = %v", err)
+	}
+	for _, want := range []string{"image: track.pgm\n", "resolution: 0.01\n", "origin: [-0.2, -0.4, 0.0]\n"} {
+		if !strings.Contains(yaml.String(), want) {
+			t.Errorf("YAML missing %q:\n%s", want, yaml.String())
+		}
+	}
+}
+
+func TestRenderROSMap_InvalidResolution(t *testing.T) {
+	project := &TrackProject{Pieces: []Piece{{Type: "straight", Params: PieceParams{Length: 50}}}}
+	for _, res := range []float64{math.NaN(), math.Inf(1), -0.01, 1e-9} {
+		if _, err := RenderROSMap(project, ROSMapOptions{ResolutionM: res}); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("resolution %g: error = %v, want ErrInvalidOption", res, err)
+		}
+	}
+	if _, err := RenderROSMap(project, ROSMapOptions{MaxPixels: 100}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("MaxPixels 100: error = %v, want ErrInvalidOption", err)
+	}
+}
@@ -0,0 +1,308 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Violation severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Rule IDs, also used as keys in RuleProfile.Severity
+const (
+	RuleMinCurveRadius         = "minCurveRadius"
+	RuleTotalLength            = "totalLength"
+	RuleRequiredElements       = "requiredElements"
+	RuleStraightBeforeCrossing = "straightBeforeCrossing"
+	RuleTrackWidth             = "trackWidth"
+	RuleFieldSize              = "fieldSize"
+)
+
+// DefaultRuleProfile is used when a request doesn't name one
+const DefaultRuleProfile = "default"
+
+// RuleProfile is one competition's constraints. Zero limits are not checked.
+type RuleProfile struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+
+	MinCurveRadiusCm            float64        `json:"minCurveRadiusCm,omitempty"`
+	MinTotalLengthCm            float64        `json:"minTotalLengthCm,omitempty"`
+	MaxTotalLengthCm            float64        `json:"maxTotalLengthCm,omitempty"`
+	RequiredElements            map[string]int `json:"requiredElements,omitempty"` // piece type -> minimum count
+	MinStraightBeforeCrossingCm float64        `json:"minStraightBeforeCrossingCm,omitempty"`
+	MinTrackWidthCm             float64        `json:"minTrackWidthCm,omitempty"`
+	MaxTrackWidthCm             float64        `json:"maxTrackWidthCm,omitempty"`
+	FieldWidthCm                float64        `json:"fieldWidthCm,omitempty"`
+	FieldHeightCm               float64        `json:"fieldHeightCm,omitempty"`
+
+	// Severity overrides the default "error" per rule ID
+	Severity map[string]string `json:"severity,omitempty"`
+}
+
+// RuleViolation is one broken rule
+type RuleViolation struct {
+	Rule     string      `json:"rule"`
+	Severity string      `json:"severity"`
+	Message  string      `json:"message"`
+	Piece    *int        `json:"piece,omitempty"`
+	ID       interface{} `json:"id,omitempty"`
+	Value    float64     `json:"value"`
+	Limit    float64     `json:"limit"`
+}
+
+// RuleReport is the result of checking a track against a profile
+type RuleReport struct {
+	Profile    string          `json:"profile"`
+	Passed     bool            `json:"passed"` // no error-level violations
+	Violations []RuleViolation `json:"violations"`
+}
+
+// DefaultRuleProfiles returns the built-in profile. The field matches the
+// editor's design area (1170cm x 827cm).
+func DefaultRuleProfiles() map[string]*RuleProfile {
+	return map[string]*RuleProfile{
+		DefaultRuleProfile: {
+			Name:                        DefaultRuleProfile,
+			Description:                 "通用智能车赛道规则",
+			MinCurveRadiusCm:            50,
+			MaxTotalLengthCm:            6000,
+			MinStraightBeforeCrossingCm: 50,
+			MinTrackWidthCm:             40,
+			MaxTrackWidthCm:             50,
+			FieldWidthCm:                1170,
+			FieldHeightCm:               827,
+			Severity: map[string]string{
+				RuleStraightBeforeCrossing: SeverityWarning,
+			},
+		},
+	}
+}
+
+// LoadRuleProfiles reads every *.json file in dir as a profile, on top of
+// the built-in one. The file name is used when a profile has no name, so
+// each season's rules can simply be dropped in as e.g. 2025-camera.json.
+func LoadRuleProfiles(dir string) (map[string]*RuleProfile, error) {
+	profiles := DefaultRuleProfiles()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		var profile RuleProfile
+		if err := json.Unmarshal(data, &profile); err != nil {
+			return nil, fmt.Errorf("invalid rule profile %s: %w", file, err)
+		}
+		if profile.Name == "" {
+			profile.Name = strings.TrimSuffix(filepath.Base(file), ".json")
+		}
+		for rule, severity := range profile.Severity {
+			if severity != SeverityError && severity != SeverityWarning {
+				return nil, fmt.Errorf("invalid rule profile %s: rule %s has unknown severity %q", file, rule, severity)
+			}
+		}
+		profiles[profile.Name] = &profile
+	}
+
+	return profiles, nil
+}
+
+// EvaluateRules checks a track against a rule profile
+func EvaluateRules(project *TrackProject, profile *RuleProfile) (*RuleReport, error) {
+	report := &RuleReport{
+		Profile:    profile.Name,
+		Violations: []RuleViolation{},
+	}
+	add := func(v RuleViolation) {
+		v.Severity = profile.severity(v.Rule)
+		report.Violations = append(report.Violations, v)
+	}
+
+	catalog := project.Catalog()
+
+	// Per-piece rules
+	counts := map[string]int{}
+	straightRun := 0.0
+	for i, piece := range project.Pieces {
+		counts[piece.Type]++
+
+		t, ok := catalog.Type(piece.Type)
+		if !ok {
+			return nil, fmt.Errorf("piece %d: unknown piece type: %s", i, piece.Type)
+		}
+
+		if profile.MinCurveRadiusCm > 0 && t.Geometry == GeometryArc && piece.Params.Radius < profile.MinCurveRadiusCm {
+			add(RuleViolation{
+				Rule:    RuleMinCurveRadius,
+				Message: fmt.Sprintf("curve radius %gcm is below %gcm", piece.Params.Radius, profile.MinCurveRadiusCm),
+				Piece:   &i,
+				ID:      piece.ID,
+				Value:   piece.Params.Radius,
+				Limit:   profile.MinCurveRadiusCm,
+			})
+		}
+
+		if t.Geometry == GeometryCrossing {
+			if profile.MinStraightBeforeCrossingCm > 0 && i > 0 && straightRun < profile.MinStraightBeforeCrossingCm {
+				add(RuleViolation{
+					Rule:    RuleStraightBeforeCrossing,
+					Message: fmt.Sprintf("only %gcm of straight before crossroads, need %gcm", straightRun, profile.MinStraightBeforeCrossingCm),
+					Piece:   &i,
+					ID:      piece.ID,
+					Value:   straightRun,
+					Limit:   profile.MinStraightBeforeCrossingCm,
+				})
+			}
+		}
+
+		if t.Geometry == GeometryStraight {
+			straightRun += piece.Params.Length
+		} else {
+			straightRun = 0
+		}
+	}
+
+	// Whole-track rules
+	length, err := CalculateLength(project)
+	if err != nil {
+		return nil, err
+	}
+	lengthCm := length * 100
+	if profile.MinTotalLengthCm > 0 && lengthCm < profile.MinTotalLengthCm {
+		add(RuleViolation{
+			Rule:    RuleTotalLength,
+			Message: fmt.Sprintf("track is %.2fm, shorter than %.2fm", length, profile.MinTotalLengthCm/100),
+			Value:   lengthCm,
+			Limit:   profile.MinTotalLengthCm,
+		})
+	}
+	if profile.MaxTotalLengthCm > 0 && lengthCm > profile.MaxTotalLengthCm {
+		add(RuleViolation{
+			Rule:    RuleTotalLength,
+			Message: fmt.Sprintf("track is %.2fm, longer than %.2fm", length, profile.MaxTotalLengthCm/100),
+			Value:   lengthCm,
+			Limit:   profile.MaxTotalLengthCm,
+		})
+	}
+
+	types := make([]string, 0, len(profile.RequiredElements))
+	for pieceType := range profile.RequiredElements {
+		types = append(types, pieceType)
+	}
+	sort.Strings(types)
+	for _, pieceType := range types {
+		need := profile.RequiredElements[pieceType]
+		if counts[pieceType] < need {
+			add(RuleViolation{
+				Rule:    RuleRequiredElements,
+				Message: fmt.Sprintf("needs at least %d %s, has %d", need, pieceType, counts[pieceType]),
+				Value:   float64(counts[pieceType]),
+				Limit:   float64(need),
+			})
+		}
+	}
+
+	width := TrackWidth(project)
+	if profile.MinTrackWidthCm > 0 && width < profile.MinTrackWidthCm {
+		add(RuleViolation{
+			Rule:    RuleTrackWidth,
+			Message: fmt.Sprintf("track width %gcm is below %gcm", width, profile.MinTrackWidthCm),
+			Value:   width,
+			Limit:   profile.MinTrackWidthCm,
+		})
+	}
+	if profile.MaxTrackWidthCm > 0 && width > profile.MaxTrackWidthCm {
+		add(RuleViolation{
+			Rule:    RuleTrackWidth,
+			Message: fmt.Sprintf("track width %gcm is above %gcm", width, profile.MaxTrackWidthCm),
+			Value:   width,
+			Limit:   profile.MaxTrackWidthCm,
+		})
+	}
+
+	if profile.FieldWidthCm > 0 && profile.FieldHeightCm > 0 {
+		w, h, err := TrackExtent(project)
+		if err != nil {
+			return nil, err
+		}
+		fits := (w <= profile.FieldWidthCm && h <= profile.FieldHeightCm) ||
+			(w <= profile.FieldHeightCm && h <= profile.FieldWidthCm)
+		if !fits {
+			add(RuleViolation{
+				Rule:    RuleFieldSize,
+				Message: fmt.Sprintf("track needs %.0fx%.0fcm, field is %.0fx%.0fcm", w, h, profile.FieldWidthCm, profile.FieldHeightCm),
+				Value:   math.Max(w, h),
+				Limit:   math.Max(profile.FieldWidthCm, profile.FieldHeightCm),
+			})
+		}
+	}
+
+	report.Passed = true
+	for _, v := range report.Violations {
+		if v.Severity == SeverityError {
+			report.Passed = false
+			break
+		}
+	}
+
+	return report, nil
+}
+
+// TrackExtent returns the width and height (cm) of the floor area the
+// track covers, including the track width
+func TrackExtent(project *TrackProject) (float64, float64, error) {
+	minX, minY, maxX, maxY, err := trackBounds(project)
+	if err != nil {
+		return 0, 0, err
+	}
+	return maxX - minX, maxY - minY, nil
+}
+
+// trackBounds is the bounding box (cm) of the swept track
+func trackBounds(project *TrackProject) (minX, minY, maxX, maxY float64, err error) {
+	segments, _, _, err := sweepSegments(project)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	if len(segments) == 0 {
+		return 0, 0, 0, 0, nil
+	}
+
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, s := range segments {
+		minX = math.Min(minX, math.Min(s.ax, s.bx))
+		minY = math.Min(minY, math.Min(s.ay, s.by))
+		maxX = math.Max(maxX, math.Max(s.ax, s.bx))
+		maxY = math.Max(maxY, math.Max(s.ay, s.by))
+	}
+
+	half := TrackWidth(project) / 2
+	return minX - half, minY - half, maxX + half, maxY + half, nil
+}
+
+func (p *RuleProfile) severity(rule string) string {
+	if s, ok := p.Severity[rule]; ok {
+		return s
+	}
+	return SeverityError
+}
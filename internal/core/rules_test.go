@@ -0,0 +1,78 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEvaluateRules(t *testing.T) {
+	project := &TrackProject{
+		Skin: &TrackSkin{TrackWidthCm: 45},
+		Pieces: []Piece{
+			{ID: 1, Type: "straight", Params: PieceParams{Length: 25}},
+			{ID: 2, Type: "crossroads", Params: PieceParams{Length: 45}},
+			{ID: 3, Type: "curve", Params: PieceParams{Radius: 40, Angle: 90}},
+		},
+	}
+
+	profile := &RuleProfile{
+		Name:                        "test",
+		MinCurveRadiusCm:            50,
+		RequiredElements:            map[string]int{"roundabout": 1},
+		MinStraightBeforeCrossingCm: 50,
+		FieldWidthCm:                100,
+		FieldHeightCm:               100,
+		Severity:                    map[string]string{RuleStraightBeforeCrossing: SeverityWarning},
+	}
+
+	report, err := EvaluateRules(project, profile)
+	if err != nil {
+		t.Fatalf("EvaluateRules() error = %v", err)
+	}
+	if report.Passed {
+		t.Error("expected report to fail")
+	}
+
+	got := map[string]string{}
+	for _, v := range report.Violations {
+		got[v.Rule] = v.Severity
+	}
+	want := map[string]string{
+		RuleMinCurveRadius:         SeverityError,
+		RuleRequiredElements:       SeverityError,
+		RuleStraightBeforeCrossing: SeverityWarning,
+		RuleFieldSize:              SeverityError,
+	}
+	for rule, severity := range want {
+		if got[rule] != severity {
+			t.Errorf("rule %s: severity = %q, want %q", rule, got[rule], severity)
+		}
+	}
+	if _, ok := got[RuleTrackWidth]; ok {
+		t.Error("track width should not be checked when unset")
+	}
+}
+
+func TestLoadRuleProfiles(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"description": "2025 摄像头组", "minCurveRadiusCm": 60}`
+	if err := os.WriteFile(filepath.Join(dir, "2025-camera.json"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	profiles, err := LoadRuleProfiles(dir)
+	if err != nil {
+		t.Fatalf("LoadRuleProfiles() error = %v", err)
+	}
+	if _, ok := profiles[DefaultRuleProfile]; !ok {
+		t.Error("built-in profile should be kept")
+	}
+	p, ok := profiles["2025-camera"]
+	if !ok {
+		t.Fatal("profile should be named after its file")
+	}
+	if p.MinCurveRadiusCm != 60 {
+		t.Errorf("MinCurveRadiusCm = %v, want 60", p.MinCurveRadiusCm)
+	}
+}
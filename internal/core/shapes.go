@@ -0,0 +1,268 @@
+package core
+
+import (
+	"fmt"
+	"math"
+)
+
+// Shape kinds
+const (
+	ShapeBand   = "band"   // a stretch of track
+	ShapeIsland = "island" // the ring lane of a roundabout
+)
+
+// shapeStepCm is the centreline sampling step for drawn outlines
+const shapeStepCm = 2.0
+
+// Shape is the footprint of one piece (or the boundary) on the floor, in
+// cm. The fill is the area between LeftEdge and RightEdge; when Closed the
+// edges are loops and the fill is the ring between them.
+type Shape struct {
+	Piece      int         `json:"piece"`
+	ID         interface{} `json:"id,omitempty"`
+	Type       string      `json:"type"`
+	Kind       string      `json:"kind"`
+	Closed     bool        `json:"closed"`
+	Centreline []Point     `json:"centreline"`
+	LeftEdge   []Point     `json:"leftEdge"`
+	RightEdge  []Point     `json:"rightEdge"`
+}
+
+// Outline returns the fill polygon of an open shape: the left edge
+// followed by the right edge reversed
+func (s Shape) Outline() []Point {
+	outline := make([]Point, 0, len(s.LeftEdge)+len(s.RightEdge))
+	outline = append(outline, s.LeftEdge...)
+	for i := len(s.RightEdge) - 1; i >= 0; i-- {
+		outline = append(outline, s.RightEdge[i])
+	}
+	return outline
+}
+
+// TrackShapes lays out the floor footprint of the whole track. Pieces are
+// drawn where they were placed; without pieces the boundary polyline is
+// treated as the centreline.
+func TrackShapes(project *TrackProject) ([]Shape, error) {
+	half := TrackWidth(project) / 2
+
+	if len(project.Pieces) == 0 {
+		if project.Boundary == nil || len(project.Boundary.Points) < 2 {
+			return nil, nil
+		}
+		return []Shape{boundaryShape(project.Boundary, half)}, nil
+	}
+
+	catalog := project.Catalog()
+	var shapes []Shape
+	for i, piece := range project.Pieces {
+		entry := catalog.PlacementPose(piece)
+		length, err := catalog.laidLength(piece)
+		if err != nil {
+			return nil, fmt.Errorf("piece %d: %w", i, err)
+		}
+
+		band, err := catalog.sweepShape(entry, piece, length, half)
+		if err != nil {
+			return nil, fmt.Errorf("piece %d: %w", i, err)
+		}
+		band.Piece, band.ID, band.Type, band.Kind = i, piece.ID, piece.Type, ShapeBand
+		shapes = append(shapes, band)
+
+		geometry, _ := catalog.geometryOf(piece.Type)
+		switch geometry {
+		case GeometryCrossing:
+			// The crossing lane runs from the right port to the left port
+			ports, err := catalog.PiecePorts(entry, piece)
+			if err != nil {
+				return nil, fmt.Errorf("piece %d: %w", i, err)
+			}
+			right := ports[3].Pose
+			across := Pose{X: right.X, Y: right.Y, Heading: normalizeAngle(right.Heading + 180)}
+			cross, err := catalog.sweepShape(across, Piece{Type: "straight", Params: PieceParams{Length: piece.Params.Length}}, piece.Params.Length, half)
+			if err != nil {
+				return nil, fmt.Errorf("piece %d: %w", i, err)
+			}
+			cross.Piece, cross.ID, cross.Type, cross.Kind = i, piece.ID, piece.Type, ShapeBand
+			shapes = append(shapes, cross)
+
+		case GeometryRoundabout:
+			shapes = append(shapes, catalog.islandShape(i, entry, piece, half))
+		}
+	}
+
+	return shapes, nil
+}
+
+// sweepShape samples a piece's centreline and offsets it to both edges
+func (c *Catalog) sweepShape(entry Pose, piece Piece, length, half float64) (Shape, error) {
+	steps := 1
+	if geometry, _ := c.geometryOf(piece.Type); geometry == GeometryArc {
+		steps = int(math.Ceil(length / shapeStepCm))
+		if steps < 1 {
+			steps = 1
+		}
+	}
+
+	var shape Shape
+	for k := 0; k <= steps; k++ {
+		pose, err := c.PoseAt(entry, piece, length*float64(k)/float64(steps))
+		if err != nil {
+			return Shape{}, err
+		}
+		addOffsetPoints(&shape, pose, half)
+	}
+	return shape, nil
+}
+
+// islandShape is the ring lane of a roundabout, tangent to the main line
+// at its midpoint on the Direction side
+func (c *Catalog) islandShape(index int, entry Pose, piece Piece, half float64) Shape {
+	mid, _ := c.PoseAt(entry, piece, piece.Params.Length/2)
+	sign := piece.Params.turnSign()
+	r := piece.Params.Radius
+	h := mid.Heading * math.Pi / 180.0
+	cx := mid.X - sign*r*math.Sin(h)
+	cy := mid.Y + sign*r*math.Cos(h)
+
+	shape := Shape{
+		Piece:  index,
+		ID:     piece.ID,
+		Type:   piece.Type,
+		Kind:   ShapeIsland,
+		Closed: true,
+	}
+	steps := int(math.Ceil(2 * math.Pi * r / shapeStepCm))
+	if steps < 12 {
+		steps = 12
+	}
+	for k := 0; k < steps; k++ {
+		a := 2 * math.Pi * float64(k) / float64(steps)
+		cos, sin := math.Cos(a), math.Sin(a)
+		shape.Centreline = append(shape.Centreline, Point{X: cx + r*cos, Y: cy + r*sin})
+		shape.LeftEdge = append(shape.LeftEdge, Point{X: cx + (r+half)*cos, Y: cy + (r+half)*sin})
+		shape.RightEdge = append(shape.RightEdge, Point{X: cx + math.Max(r-half, 0)*cos, Y: cy + math.Max(r-half, 0)*sin})
+	}
+	return shape
+}
+
+// boundaryShape offsets the boundary polyline by half the track width,
+// mitring the corners
+func boundaryShape(boundary *Boundary, half float64) Shape {
+	scale := 1.0
+	if boundary.Unit == "px" {
+		scale = 1 / PxPerCm
+	}
+
+	points := make([]Point, len(boundary.Points))
+	for i, p := range boundary.Points {
+		points[i] = Point{Idx: i, X: p.X * scale, Y: p.Y * scale}
+	}
+
+	closed := boundary.Closed && len(points) > 2
+	left, right := offsetPolyline(points, closed, half)
+	return Shape{
+		Piece:      0,
+		Type:       "boundary",
+		Kind:       ShapeBand,
+		Closed:     closed,
+		Centreline: points,
+		LeftEdge:   left,
+		RightEdge:  right,
+	}
+}
+
+// offsetPolyline offsets a polyline by half to both sides, mitring the
+// corners
+func offsetPolyline(points []Point, closed bool, half float64) (left, right []Point) {
+	n := len(points)
+	for i := 0; i < n; i++ {
+		prev, next := i-1, i+1
+		if closed {
+			prev, next = (i+n-1)%n, (i+1)%n
+		}
+
+		// Average the normals of the edges meeting at this point
+		var nx, ny float64
+		if prev >= 0 {
+			dx, dy := unit(points[i].X-points[prev].X, points[i].Y-points[prev].Y)
+			nx, ny = nx-dy, ny+dx
+		}
+		if next < n {
+			dx, dy := unit(points[next].X-points[i].X, points[next].Y-points[i].Y)
+			nx, ny = nx-dy, ny+dx
+		}
+		nx, ny = unit(nx, ny)
+
+		// Stretch the offset at corners so the edges stay parallel
+		miter := half
+		if prev >= 0 && next < n {
+			dx, dy := unit(points[next].X-points[i].X, points[next].Y-points[i].Y)
+			if dot := nx*-dy + ny*dx; dot > 0.25 {
+				miter = half / dot
+			}
+		}
+
+		p := points[i]
+		left = append(left, Point{X: p.X + nx*miter, Y: p.Y + ny*miter})
+		right = append(right, Point{X: p.X - nx*miter, Y: p.Y - ny*miter})
+	}
+	return left, right
+}
+
+// zebraStripes returns the stripe polygons of a zebra crossing, running
+// along the direction of travel
+func zebraStripes(s Shape, half float64) [][]Point {
+	const stripeCm = 5.0
+	n := len(s.Centreline)
+	if n < 2 {
+		return nil
+	}
+	a, b := s.Centreline[0], s.Centreline[n-1]
+	dx, dy := unit(b.X-a.X, b.Y-a.Y)
+	nx, ny := -dy, dx
+
+	var stripes [][]Point
+	for off := -half + stripeCm/2; off+stripeCm <= half; off += 2 * stripeCm {
+		stripes = append(stripes, []Point{
+			{X: a.X + nx*off, Y: a.Y + ny*off},
+			{X: b.X + nx*off, Y: b.Y + ny*off},
+			{X: b.X + nx*(off+stripeCm), Y: b.Y + ny*(off+stripeCm)},
+			{X: a.X + nx*(off+stripeCm), Y: a.Y + ny*(off+stripeCm)},
+		})
+	}
+	return stripes
+}
+
+func addOffsetPoints(shape *Shape, pose Pose, half float64) {
+	h := pose.Heading * math.Pi / 180.0
+	nx, ny := -math.Sin(h), math.Cos(h)
+	shape.Centreline = append(shape.Centreline, Point{X: pose.X, Y: pose.Y})
+	shape.LeftEdge = append(shape.LeftEdge, Point{X: pose.X + nx*half, Y: pose.Y + ny*half})
+	shape.RightEdge = append(shape.RightEdge, Point{X: pose.X - nx*half, Y: pose.Y - ny*half})
+}
+
+func unit(x, y float64) (float64, float64) {
+	l := math.Hypot(x, y)
+	if l == 0 {
+		return 0, 0
+	}
+	return x / l, y / l
+}
+
+// ShapesBounds is the bounding box (cm) of a set of shapes
+func ShapesBounds(shapes []Shape) (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, s := range shapes {
+		for _, edge := range [][]Point{s.LeftEdge, s.RightEdge} {
+			for _, p := range edge {
+				minX, minY = math.Min(minX, p.X), math.Min(minY, p.Y)
+				maxX, maxY = math.Max(maxX, p.X), math.Max(maxY, p.Y)
+			}
+		}
+	}
+	if math.IsInf(minX, 1) {
+		return 0, 0, 0, 0
+	}
+	return minX, minY, maxX, maxY
+}
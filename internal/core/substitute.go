@@ -0,0 +1,242 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// maxSubstitutePieces caps how many parts may replace one piece
+const maxSubstitutePieces = 6
+
+// Substitution replaces one piece with a run of owned parts
+type Substitution struct {
+	Piece int         `json:"piece"`
+	ID    interface{} `json:"id,omitempty"`
+	Code  string      `json:"code"`
+	With  []string    `json:"with"`
+}
+
+// UnresolvedPiece is a piece the inventory can't supply or replace
+type UnresolvedPiece struct {
+	Piece  int         `json:"piece"`
+	ID     interface{} `json:"id,omitempty"`
+	Code   string      `json:"code"`
+	Reason string      `json:"reason"`
+}
+
+// SubstitutionReport is the result of SubstituteParts
+type SubstitutionReport struct {
+	Project       *TrackProject     `json:"project"` // rewritten copy
+	Complete      bool              `json:"complete"`
+	Substitutions []Substitution    `json:"substitutions"`
+	Unresolved    []UnresolvedPiece `json:"unresolved"`
+	Remaining     Inventory         `json:"remaining"` // parts left over
+}
+
+// substitutePart is a part that can stand in for others: a catalogue
+// entry, or a custom part that only appears in the track
+type substitutePart struct {
+	code    string
+	piece   Piece
+	measure float64 // length (straights) or angle (curves)
+}
+
+// SubstituteParts rewrites a track to use only parts in inv. Pieces the
+// inventory covers are kept; the others are replaced by runs of owned
+// parts of the same type (L75 → L50 + L25, R50-90 → 2 × R50-45) that end
+// within tol of where the original did. Special elements are never split.
+func SubstituteParts(project *TrackProject, inv Inventory, tol Tolerance) (*SubstitutionReport, error) {
+	catalog := project.Catalog()
+
+	remaining := Inventory{}
+	for code, n := range inv {
+		remaining[code] = n
+	}
+
+	parts := map[string]substitutePart{}
+	addPart := func(p Piece) {
+		code := catalog.Code(p)
+		if _, ok := parts[code]; ok || code == "UNKNOWN" {
+			return
+		}
+		p.ID, p.X, p.Y, p.Rotation = nil, 0, 0, 0
+		p.Params.Direction = ""
+		p.Params.Angle = math.Abs(p.Params.Angle)
+		measure := p.Params.Length
+		if geometry, _ := catalog.geometryOf(p.Type); geometry == GeometryArc {
+			measure = p.Params.Angle
+		}
+		parts[code] = substitutePart{code: code, piece: p, measure: measure}
+	}
+	for _, entry := range catalog.Pieces {
+		addPart(entry.Piece())
+	}
+	for _, piece := range project.Pieces {
+		addPart(piece)
+	}
+
+	// Owned pieces first, so substitutes only use what's left over
+	codes := make([]string, len(project.Pieces))
+	pending := []int{}
+	for i, piece := range project.Pieces {
+		codes[i] = catalog.Code(piece)
+		if remaining[codes[i]] > 0 {
+			remaining[codes[i]]--
+		} else {
+			pending = append(pending, i)
+		}
+	}
+
+	report := &SubstitutionReport{
+		Substitutions: []Substitution{},
+		Unresolved:    []UnresolvedPiece{},
+	}
+	replacements := map[int][]Piece{}
+	for _, i := range pending {
+		piece := project.Pieces[i]
+		unresolved := UnresolvedPiece{Piece: i, ID: piece.ID, Code: codes[i]}
+
+		if isSpecialElement(catalog, piece.Type) {
+			unresolved.Reason = "special elements can't be substituted"
+			report.Unresolved = append(report.Unresolved, unresolved)
+			continue
+		}
+		run, err := catalog.findSubstitute(piece, parts, remaining, tol)
+		if err != nil {
+			return nil, fmt.Errorf("piece %d: %w", i, err)
+		}
+		if run == nil {
+			unresolved.Reason = "no combination of available parts matches"
+			report.Unresolved = append(report.Unresolved, unresolved)
+			continue
+		}
+
+		sub := Substitution{Piece: i, ID: piece.ID, Code: codes[i]}
+		for _, p := range run {
+			code := catalog.Code(p)
+			remaining[code]--
+			sub.With = append(sub.With, code)
+		}
+		report.Substitutions = append(report.Substitutions, sub)
+		replacements[i] = run
+	}
+
+	rewritten := *project
+	rewritten.Pieces = make([]Piece, 0, len(project.Pieces))
+	for i, piece := range project.Pieces {
+		run, ok := replacements[i]
+		if !ok {
+			rewritten.Pieces = append(rewritten.Pieces, piece)
+			continue
+		}
+		entry := catalog.PlacementPose(piece)
+		for k, p := range run {
+			p.ID = fmt.Sprintf("%v-%d", piece.ID, k+1)
+			rewritten.Pieces = append(rewritten.Pieces, catalog.placePiece(p, entry))
+			entry, _ = catalog.AdvancePose(entry, p)
+		}
+	}
+
+	for code, n := range remaining {
+		if n <= 0 {
+			delete(remaining, code)
+		}
+	}
+	report.Project = &rewritten
+	report.Remaining = remaining
+	report.Complete = len(report.Unresolved) == 0
+	return report, nil
+}
+
+// findSubstitute searches owned parts of the piece's type (and radius, for
+// curves) for the shortest run that ends where the piece does. It returns
+// nil if there's none.
+func (c *Catalog) findSubstitute(piece Piece, parts map[string]substitutePart, remaining Inventory, tol Tolerance) ([]Piece, error) {
+	geometry, err := c.geometryOf(piece.Type)
+	if err != nil {
+		return nil, err
+	}
+	target := piece.Params.Length
+	slack := tol.GapCm
+	if geometry == GeometryArc {
+		target = math.Abs(piece.Params.Angle)
+		slack = tol.HeadingDeg
+	}
+
+	var candidates []substitutePart
+	for _, part := range parts {
+		if part.piece.Type != piece.Type || remaining[part.code] <= 0 || part.measure <= 0 {
+			continue
+		}
+		if geometry == GeometryArc && part.piece.Params.Radius != piece.Params.Radius {
+			continue
+		}
+		if part.piece.Params.Height != piece.Params.Height {
+			continue
+		}
+		candidates = append(candidates, part)
+	}
+	// Longest first, so the first fits found use the fewest parts
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].measure != candidates[j].measure {
+			return candidates[i].measure > candidates[j].measure
+		}
+		return candidates[i].code < candidates[j].code
+	})
+
+	want, err := c.AdvancePose(Pose{}, piece)
+	if err != nil {
+		return nil, err
+	}
+	orient := func(p Piece) Piece {
+		if geometry == GeometryArc && piece.Params.turnSign() < 0 {
+			p.Params.Direction = "right"
+		}
+		return p
+	}
+
+	var best []substitutePart
+	bestErr := math.Inf(1)
+	used := map[string]int{}
+	path := make([]substitutePart, 0, maxSubstitutePieces)
+
+	var search func(start int, sum float64)
+	search = func(start int, sum float64) {
+		if len(path) > 0 && math.Abs(sum-target) <= slack {
+			if len(best) == 0 || len(path) < len(best) || (len(path) == len(best) && math.Abs(sum-target) < bestErr) {
+				best = append(best[:0], path...)
+				bestErr = math.Abs(sum - target)
+			}
+		}
+		if len(path) == maxSubstitutePieces || (len(best) > 0 && len(path) >= len(best)) {
+			return
+		}
+		for i := start; i < len(candidates); i++ {
+			c := candidates[i]
+			if used[c.code] >= remaining[c.code] || sum+c.measure > target+slack {
+				continue
+			}
+			used[c.code]++
+			path = append(path, c)
+			search(i, sum+c.measure)
+			path = path[:len(path)-1]
+			used[c.code]--
+		}
+	}
+	search(0, 0)
+	if best == nil {
+		return nil, nil
+	}
+
+	run := make([]Piece, len(best))
+	end := Pose{}
+	for i, part := range best {
+		run[i] = orient(part.piece)
+		end, _ = c.AdvancePose(end, run[i])
+	}
+	if !CompareJoint(end, want, tol).Connected {
+		return nil, nil
+	}
+	return run, nil
+}
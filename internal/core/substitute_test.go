@@ -0,0 +1,74 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSubstituteParts(t *testing.T) {
+	pieces := []Piece{
+		{ID: "s", Type: "straight", Params: PieceParams{Length: 75}},
+		{ID: "c", Type: "curve", Params: PieceParams{Radius: 50, Angle: 90, Direction: "right"}},
+		{ID: "x", Type: "crossroads", Params: PieceParams{Length: 45}},
+		{ID: "k", Type: "straight", Params: PieceParams{Length: 50}},
+	}
+	poses, _ := defaultCatalog.ChainPoses(pieces, Pose{X: 10, Y: 20, Heading: 30})
+	for i := range pieces {
+		pieces[i] = defaultCatalog.placePiece(pieces[i], poses[i].Entry)
+	}
+	project := &TrackProject{Pieces: pieces}
+
+	inv := Inventory{"L50": 2, "L25": 1, "R50-45": 2, "R50-30": 3}
+	report, err := SubstituteParts(project, inv, DefaultTolerance)
+	if err != nil {
+		t.Fatalf("SubstituteParts() error = %v", err)
+	}
+
+	// The L50 is owned and kept; the L75 gets the other L50 plus the L25
+	want := []Substitution{
+		{Piece: 0, ID: "s", Code: "L75", With: []string{"L50", "L25"}},
+		{Piece: 1, ID: "c", Code: "R50-90", With: []string{"R50-45", "R50-45"}},
+	}
+	if !reflect.DeepEqual(report.Substitutions, want) {
+		t.Errorf("substitutions = %+v, want %+v", report.Substitutions, want)
+	}
+	if report.Complete || len(report.Unresolved) != 1 || report.Unresolved[0].Code != "X45" {
+		t.Errorf("unresolved = %+v, want just the crossroads", report.Unresolved)
+	}
+	if !reflect.DeepEqual(report.Remaining, Inventory{"R50-30": 3}) {
+		t.Errorf("remaining = %v", report.Remaining)
+	}
+
+	// The rewritten track still joins up, curving right where it did
+	rewritten := report.Project
+	if len(rewritten.Pieces) != 6 {
+		t.Fatalf("got %d pieces, want 6", len(rewritten.Pieces))
+	}
+	chain, err := SolvePoses(rewritten, DefaultTolerance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !chain.Connected {
+		t.Errorf("rewritten track doesn't connect: %+v", chain.Joints)
+	}
+	end := chain.Poses[len(chain.Poses)-1].Exit
+	if j := CompareJoint(end, poses[len(poses)-1].Exit, DefaultTolerance); !j.Connected {
+		t.Errorf("rewritten track ends at %+v, want %+v", end, poses[len(poses)-1].Exit)
+	}
+	if rewritten.Pieces[0].ID != "s-1" || rewritten.Pieces[2].Params.Direction != "right" {
+		t.Errorf("unexpected pieces %+v", rewritten.Pieces[:3])
+	}
+	if project.Pieces[0].Params.Length != 75 || len(project.Pieces) != 4 {
+		t.Error("the original track must not change")
+	}
+
+	// Nothing fits a 60cm gap from 50s and 25s
+	odd := &TrackProject{Pieces: []Piece{{ID: 1, Type: "straight", Params: PieceParams{Length: 60}}}}
+	report, err = SubstituteParts(odd, inv, DefaultTolerance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Unresolved) != 1 || report.Unresolved[0].Reason == "" {
+		t.Errorf("unresolved = %+v", report.Unresolved)
+	}
+}
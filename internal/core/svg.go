@@ -0,0 +1,204 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Default colours, matching the editor
+const (
+	DefaultFloorColor = "#1E3A8A"
+	DefaultTrackColor = "#FFFFFF"
+	DefaultEdgeColor  = "#000000"
+)
+
+// SVGOptions controls what is drawn besides the track itself
+type SVGOptions struct {
+	Grid     bool    // grid lines every GridCm
+	GridCm   float64 // default 50
+	Labels   bool    // BOM code on each piece
+	Legend   bool    // BOM table beside the track
+	MarginCm float64 // default 20
+}
+
+// edgeLineCm is the width of the black edge lines
+const edgeLineCm = 2.5
+
+// RenderSVG draws the track in centimetres at true scale (width/height are
+// given in cm, so printing at 100% gives a 1:1 template). The SVG y axis is
+// flipped so that +Y points up as in the editor.
+func RenderSVG(out io.Writer, project *TrackProject, opts SVGOptions) error {
+	if opts.GridCm <= 0 {
+		opts.GridCm = 50
+	}
+	if opts.MarginCm <= 0 {
+		opts.MarginCm = 20
+	}
+
+	shapes, err := TrackShapes(project)
+	if err != nil {
+		return err
+	}
+
+	minX, minY, maxX, maxY := ShapesBounds(shapes)
+	minX -= opts.MarginCm
+	minY -= opts.MarginCm
+	maxX += opts.MarginCm
+	maxY += opts.MarginCm
+
+	// Legend goes to the right of the track
+	var bom *BOMSummary
+	var codes []string
+	fontSize := math.Max(8, math.Min(maxX-minX, maxY-minY)/40)
+	legendWidth := 0.0
+	if opts.Legend {
+		bom = GenerateBOM(project)
+		for code := range bom.BOM {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		legendWidth = fontSize * 12
+		if rows := float64(len(codes)+3) * fontSize * 1.5; rows > maxY-minY {
+			minY = maxY - rows
+		}
+	}
+
+	width := maxX - minX + legendWidth
+	height := maxY - minY
+
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%scm" height="%scm" viewBox="%s %s %s %s">`+"\n",
+		num(width), num(height), num(minX), num(-maxY), num(width), num(height))
+	fmt.Fprintf(w, "<title>%s</title>\n", html.EscapeString(project.Name))
+	fmt.Fprintf(w, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n",
+		num(minX), num(-maxY), num(width), num(height), DefaultFloorColor)
+
+	if opts.Grid {
+		writeSVGGrid(w, minX, minY, maxX, maxY, opts.GridCm)
+	}
+
+	trackColor := DefaultTrackColor
+	if project.Skin != nil && project.Skin.Color != "" {
+		trackColor = project.Skin.Color
+	}
+	half := TrackWidth(project) / 2
+
+	fmt.Fprintf(w, `<g id="track">`+"\n")
+	for _, s := range shapes {
+		fmt.Fprintf(w, `<path d="%s" fill="%s" fill-rule="evenodd"/>`+"\n", shapePath(s), shapeFill(s, trackColor))
+	}
+	for _, s := range shapes {
+		if s.Type == "zebra" {
+			writeZebra(w, s, half)
+		}
+	}
+	for _, s := range shapes {
+		for _, edge := range [][]Point{s.LeftEdge, s.RightEdge} {
+			fmt.Fprintf(w, `<path d="%s" fill="none" stroke="%s" stroke-width="%s"/>`+"\n",
+				polylinePath(edge, s.Closed), DefaultEdgeColor, num(edgeLineCm))
+		}
+	}
+	fmt.Fprintf(w, "</g>\n")
+
+	if opts.Labels && len(project.Pieces) > 0 {
+		fmt.Fprintf(w, `<g id="labels" font-family="sans-serif" font-size="%s" fill="#FFB732" text-anchor="middle">`+"\n", num(fontSize))
+		for _, s := range shapes {
+			if s.Kind != ShapeBand || len(s.Centreline) == 0 {
+				continue
+			}
+			mid := s.Centreline[len(s.Centreline)/2]
+			code := project.Catalog().Code(project.Pieces[s.Piece])
+			fmt.Fprintf(w, `<text x="%s" y="%s">%s</text>`+"\n", num(mid.X), num(-mid.Y), html.EscapeString(code))
+		}
+		fmt.Fprintf(w, "</g>\n")
+	}
+
+	if opts.Legend {
+		x := maxX + fontSize
+		y := -maxY + fontSize*2
+		fmt.Fprintf(w, `<g id="legend" font-family="sans-serif" font-size="%s" fill="#FFFFFF">`+"\n", num(fontSize))
+		fmt.Fprintf(w, `<text x="%s" y="%s" font-weight="bold">%s</text>`+"\n", num(x), num(y), html.EscapeString(project.Name))
+		y += fontSize * 1.5
+		fmt.Fprintf(w, `<text x="%s" y="%s">%d pieces, %sm</text>`+"\n", num(x), num(y), bom.TotalPieces, bom.TotalLength)
+		for _, code := range codes {
+			y += fontSize * 1.5
+			fmt.Fprintf(w, `<text x="%s" y="%s">%s × %d</text>`+"\n", num(x), num(y), html.EscapeString(code), bom.BOM[code])
+		}
+		fmt.Fprintf(w, "</g>\n")
+	}
+
+	fmt.Fprintf(w, "</svg>\n")
+	return w.Flush()
+}
+
+func writeSVGGrid(w io.Writer, minX, minY, maxX, maxY, step float64) {
+	fmt.Fprintf(w, `<g id="grid" stroke="#3B82F6" stroke-width="0.5" opacity="0.4">`+"\n")
+	for x := math.Ceil(minX/step) * step; x <= maxX; x += step {
+		fmt.Fprintf(w, `<line x1="%s" y1="%s" x2="%s" y2="%s"/>`+"\n", num(x), num(-maxY), num(x), num(-minY))
+	}
+	for y := math.Ceil(minY/step) * step; y <= maxY; y += step {
+		fmt.Fprintf(w, `<line x1="%s" y1="%s" x2="%s" y2="%s"/>`+"\n", num(minX), num(-y), num(maxX), num(-y))
+	}
+	fmt.Fprintf(w, "</g>\n")
+}
+
+// writeZebra paints the stripes of a zebra crossing
+func writeZebra(w io.Writer, s Shape, half float64) {
+	for _, stripe := range zebraStripes(s, half) {
+		fmt.Fprintf(w, `<path d="%s" fill="%s"/>`+"\n", polylinePath(stripe, true), DefaultEdgeColor)
+	}
+}
+
+// shapeFill picks the fill for special elements
+func shapeFill(s Shape, trackColor string) string {
+	switch s.Type {
+	case "stopline":
+		return DefaultEdgeColor
+	case "obstacle":
+		return "#9CA3AF"
+	}
+	return trackColor
+}
+
+// shapePath is the SVG path of a shape's fill
+func shapePath(s Shape) string {
+	if s.Closed {
+		return polylinePath(s.LeftEdge, true) + " " + polylinePath(s.RightEdge, true)
+	}
+	return polylinePath(s.Outline(), true)
+}
+
+// polylinePath converts points to SVG path data, flipping y
+func polylinePath(points []Point, closed bool) string {
+	var b strings.Builder
+	for i, p := range points {
+		if i == 0 {
+			b.WriteString("M")
+		} else {
+			b.WriteString(" L")
+		}
+		b.WriteString(num(p.X))
+		b.WriteString(" ")
+		b.WriteString(num(-p.Y))
+	}
+	if closed && len(points) > 0 {
+		b.WriteString(" Z")
+	}
+	return b.String()
+}
+
+// num formats a coordinate with at most two decimals
+func num(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRenderSVG(t *testing.T) {
+	project := figureEight()
+	project.Name = "八字 <test>"
+
+	var buf bytes.Buffer
+	if err := RenderSVG(&buf, project, SVGOptions{Grid: true, Labels: true, Legend: true}); err != nil {
+		t.Fatalf("RenderSVG() error = %v", err)
+	}
+	svg := buf.String()
+
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" width="`,
+		`cm" height="`,
+		`<g id="grid"`,
+		`<g id="labels"`,
+		`X45`,
+		`R50-90 × 6`,
+		`八字 &lt;test&gt;`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG missing %q", want)
+		}
+	}
+}
+
+func TestTrackShapes_Boundary(t *testing.T) {
+	project := &TrackProject{
+		Boundary: &Boundary{
+			Unit:   "px",
+			Points: []Point{{X: 0, Y: 0}, {X: 200, Y: 0}, {X: 200, Y: 200}},
+		},
+	}
+
+	shapes, err := TrackShapes(project)
+	if err != nil {
+		t.Fatalf("TrackShapes() error = %v", err)
+	}
+	if len(shapes) != 1 {
+		t.Fatalf("got %d shapes, want 1", len(shapes))
+	}
+
+	// px converted to cm, and the mitred corner stays half a width off both edges
+	minX, minY, maxX, maxY := ShapesBounds(shapes)
+	if !almostEqual(minX, 0) || !almostEqual(minY, -22.5) || !almostEqual(maxX, 122.5) || !almostEqual(maxY, 100) {
+		t.Errorf("bounds = (%v, %v, %v, %v)", minX, minY, maxX, maxY)
+	}
+}
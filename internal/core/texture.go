@@ -0,0 +1,316 @@
+package core
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+// Floor patterns for RenderTexture
+const (
+	FloorPlain  = "plain"
+	FloorCarpet = "carpet"
+	FloorTiles  = "tiles"
+	FloorWood   = "wood"
+)
+
+// TextureOptions controls how the track is painted for vision work.
+// Zero values fall back to the editor look at 4 px/cm.
+type TextureOptions struct {
+	PxPerCm      float64 // default 4
+	MarginCm     float64 // floor around the track, default 30
+	TrackColor   string  // "#RRGGBB", default the skin colour or white
+	FloorColor   string  // default the editor blue
+	EdgeColor    string  // default black
+	EdgeLineCm   float64 // default 2.5
+	FloorPattern string  // plain (default), carpet, tiles or wood
+	Noise        float64 // per-pixel sensor noise, 0..1
+	Lighting     float64 // uneven lighting strength, 0..1
+	Seed         int64   // noise and lighting are reproducible per seed
+	MaxPixels    int     // image size cap, default DefaultMaxRasterPixels
+}
+
+// TrackTexture is a top-down image of the floor. Pixel (0, 0) is the floor
+// point (MinX, MaxY), as the image y axis points down.
+type TrackTexture struct {
+	Image   *image.RGBA
+	PxPerCm float64
+	MinX    float64
+	MinY    float64
+	MaxX    float64
+	MaxY    float64
+}
+
+// RenderTexture paints the track as it looks on the floor
+func RenderTexture(project *TrackProject, opts TextureOptions) (*TrackTexture, error) {
+	if opts.PxPerCm == 0 {
+		opts.PxPerCm = 4
+	}
+	if err := checkScale("pxPerCm", opts.PxPerCm); err != nil {
+		return nil, err
+	}
+	if opts.MarginCm <= 0 {
+		opts.MarginCm = 30
+	}
+	switch opts.FloorPattern {
+	case "":
+		opts.FloorPattern = FloorPlain
+	case FloorPlain, FloorCarpet, FloorTiles, FloorWood:
+	default:
+		return nil, fmt.Errorf("%w: unknown floor pattern: %s", ErrInvalidOption, opts.FloorPattern)
+	}
+	if opts.Noise < 0 || opts.Noise > 1 || opts.Lighting < 0 || opts.Lighting > 1 {
+		return nil, fmt.Errorf("%w: noise and lighting must be between 0 and 1", ErrInvalidOption)
+	}
+
+	style := DefaultRasterStyle(project)
+	for _, c := range []struct {
+		hex string
+		dst *color.RGBA
+	}{
+		{opts.TrackColor, &style.Track},
+		{opts.FloorColor, &style.Floor},
+		{opts.EdgeColor, &style.Edge},
+	} {
+		if c.hex == "" {
+			continue
+		}
+		parsed, err := ParseHexColor(c.hex)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidOption, err)
+		}
+		*c.dst = parsed
+	}
+	if opts.EdgeLineCm > 0 {
+		style.EdgeLineCm = opts.EdgeLineCm
+	}
+
+	shapes, err := TrackShapes(project)
+	if err != nil {
+		return nil, err
+	}
+	if len(shapes) == 0 {
+		return nil, fmt.Errorf("track has no pieces or boundary to draw")
+	}
+
+	minX, minY, maxX, maxY := ShapesBounds(shapes)
+	minX -= opts.MarginCm
+	minY -= opts.MarginCm
+	maxX += opts.MarginCm
+	maxY += opts.MarginCm
+
+	w, h, err := rasterSize(maxX-minX, maxY-minY, opts.PxPerCm, opts.MaxPixels)
+	if err != nil {
+		return nil, err
+	}
+	// Grow the floor to whole pixels so the texture isn't stretched
+	maxX = minX + float64(w)/opts.PxPerCm
+	maxY = minY + float64(h)/opts.PxPerCm
+
+	tex := &TrackTexture{PxPerCm: opts.PxPerCm, MinX: minX, MinY: minY, MaxX: maxX, MaxY: maxY}
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	r := NewRaster(w, h, opts.PxPerCm, minX, maxY, style.Floor)
+	tex.Image = r.Image()
+	if err := tex.paintFloor(opts.FloorPattern, rng); err != nil {
+		return nil, err
+	}
+	paintTrack(r, shapes, TrackWidth(project)/2, style)
+
+	if opts.Lighting > 0 {
+		tex.applyLighting(opts.Lighting, rng)
+	}
+	if opts.Noise > 0 {
+		tex.applyNoise(opts.Noise, rng)
+	}
+
+	return tex, nil
+}
+
+// FloorPoint returns the floor position (cm) of the centre of pixel (x, y)
+func (t *TrackTexture) FloorPoint(x, y int) Point {
+	return Point{
+		X: t.MinX + (float64(x)+0.5)/t.PxPerCm,
+		Y: t.MaxY - (float64(y)+0.5)/t.PxPerCm,
+	}
+}
+
+// Sample returns the bilinearly interpolated colour at a floor position,
+// and false outside the texture
+func (t *TrackTexture) Sample(x, y float64) (r, g, b float64, ok bool) {
+	fx := (x-t.MinX)*t.PxPerCm - 0.5
+	fy := (t.MaxY-y)*t.PxPerCm - 0.5
+	bounds := t.Image.Bounds()
+	if fx < -0.5 || fy < -0.5 || fx > float64(bounds.Dx())-0.5 || fy > float64(bounds.Dy())-0.5 {
+		return 0, 0, 0, false
+	}
+
+	x0, y0 := int(math.Floor(fx)), int(math.Floor(fy))
+	ax, ay := fx-float64(x0), fy-float64(y0)
+	clampX := func(v int) int { return max(0, min(v, bounds.Dx()-1)) }
+	clampY := func(v int) int { return max(0, min(v, bounds.Dy()-1)) }
+
+	var acc [3]float64
+	for _, c := range []struct {
+		x, y int
+		w    float64
+	}{
+		{x0, y0, (1 - ax) * (1 - ay)},
+		{x0 + 1, y0, ax * (1 - ay)},
+		{x0, y0 + 1, (1 - ax) * ay},
+		{x0 + 1, y0 + 1, ax * ay},
+	} {
+		i := t.Image.PixOffset(clampX(c.x), clampY(c.y))
+		acc[0] += float64(t.Image.Pix[i]) * c.w
+		acc[1] += float64(t.Image.Pix[i+1]) * c.w
+		acc[2] += float64(t.Image.Pix[i+2]) * c.w
+	}
+	return acc[0], acc[1], acc[2], true
+}
+
+// paintFloor modulates the plain floor colour with a pattern. It runs
+// before the track is painted, so only the floor is affected.
+func (t *TrackTexture) paintFloor(pattern string, rng *rand.Rand) error {
+	img := t.Image
+	bounds := img.Bounds()
+
+	var brightness func(p Point) float64
+	switch pattern {
+	case FloorPlain:
+		return nil
+
+	case FloorCarpet:
+		// Fine fibres: independent speckle per pixel
+		brightness = func(Point) float64 {
+			return 1 + (rng.Float64()-0.5)*0.16
+		}
+
+	case FloorTiles:
+		// 60cm tiles with slightly different shades and dark grout
+		const tileCm, groutCm = 60.0, 0.5
+		shades := map[[2]int]float64{}
+		brightness = func(p Point) float64 {
+			tx, ty := math.Floor(p.X/tileCm), math.Floor(p.Y/tileCm)
+			if p.X-tx*tileCm < groutCm || p.Y-ty*tileCm < groutCm {
+				return 0.75
+			}
+			key := [2]int{int(tx), int(ty)}
+			shade, ok := shades[key]
+			if !ok {
+				shade = 1 + (rng.Float64()-0.5)*0.08
+				shades[key] = shade
+			}
+			return shade
+		}
+
+	case FloorWood:
+		// 15cm planks along x, each with its own grain phase
+		const plankCm = 15.0
+		phases := map[int]float64{}
+		brightness = func(p Point) float64 {
+			plank := int(math.Floor(p.Y / plankCm))
+			if p.Y-float64(plank)*plankCm < 0.3 {
+				return 0.8
+			}
+			phase, ok := phases[plank]
+			if !ok {
+				phase = rng.Float64() * 100
+				phases[plank] = phase
+			}
+			grain := math.Sin((p.X+phase)*0.7+3*math.Sin((p.X+phase)*0.05)) * 0.06
+			return 1 + grain + (float64(plank%3)-1)*0.03
+		}
+
+	default:
+		return fmt.Errorf("unknown floor pattern: %s", pattern)
+	}
+
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			scalePixel(img.Pix[img.PixOffset(x, y):], brightness(t.FloorPoint(x, y)))
+		}
+	}
+	return nil
+}
+
+// applyLighting darkens and brightens the image smoothly, as uneven hall
+// lighting does: a random gradient plus blobs about a metre across
+func (t *TrackTexture) applyLighting(strength float64, rng *rand.Rand) {
+	img := t.Image
+	bounds := img.Bounds()
+
+	angle := rng.Float64() * 2 * math.Pi
+	gx, gy := math.Cos(angle), math.Sin(angle)
+	w, h := t.MaxX-t.MinX, t.MaxY-t.MinY
+	span := math.Max(w, h)
+	blobs := newValueNoise(w, h, 100, rng)
+
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			p := t.FloorPoint(x, y)
+			px, py := p.X-t.MinX, p.Y-t.MinY
+			gradient := ((px-w/2)*gx + (py-h/2)*gy) / span
+			light := 1 + strength*0.35*(gradient+blobs.at(px, py)-0.5)
+			scalePixel(img.Pix[img.PixOffset(x, y):], light)
+		}
+	}
+}
+
+// applyNoise adds per-pixel, per-channel sensor noise
+func (t *TrackTexture) applyNoise(amount float64, rng *rand.Rand) {
+	pix := t.Image.Pix
+	sigma := amount * 40
+	for i := 0; i < len(pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			pix[i+c] = clampByte(float64(pix[i+c]) + rng.NormFloat64()*sigma)
+		}
+	}
+}
+
+func scalePixel(pix []uint8, f float64) {
+	for c := 0; c < 3; c++ {
+		pix[c] = clampByte(float64(pix[c]) * f)
+	}
+}
+
+func clampByte(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
+
+// valueNoise is smooth 2D noise in 0..1 from random values on a grid
+type valueNoise struct {
+	cols, rows int
+	cellCm     float64
+	values     []float64
+}
+
+func newValueNoise(widthCm, heightCm, cellCm float64, rng *rand.Rand) *valueNoise {
+	n := &valueNoise{
+		cols:   int(math.Ceil(widthCm/cellCm)) + 2,
+		rows:   int(math.Ceil(heightCm/cellCm)) + 2,
+		cellCm: cellCm,
+	}
+	n.values = make([]float64, n.cols*n.rows)
+	for i := range n.values {
+		n.values[i] = rng.Float64()
+	}
+	return n
+}
+
+func (n *valueNoise) at(x, y float64) float64 {
+	fx, fy := math.Max(x/n.cellCm, 0), math.Max(y/n.cellCm, 0)
+	x0 := min(int(fx), n.cols-2)
+	y0 := min(int(fy), n.rows-2)
+	smooth := func(t float64) float64 {
+		t = math.Max(0, math.Min(1, t))
+		return t * t * (3 - 2*t)
+	}
+	ax, ay := smooth(fx-float64(x0)), smooth(fy-float64(y0))
+
+	v := func(cx, cy int) float64 { return n.values[cy*n.cols+cx] }
+	top := v(x0, y0)*(1-ax) + v(x0+1, y0)*ax
+	bottom := v(x0, y0+1)*(1-ax) + v(x0+1, y0+1)*ax
+	return top*(1-ay) + bottom*ay
+}
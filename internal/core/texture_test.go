@@ -0,0 +1,95 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestRenderTexture(t *testing.T) {
+	project := &TrackProject{
+		Pieces: []Piece{{ID: "a", Type: "straight", Params: PieceParams{Length: 100}}},
+	}
+
+	tex, err := RenderTexture(project, TextureOptions{
+		PxPerCm:    2,
+		TrackColor: "#00FF00",
+		EdgeLineCm: 4,
+	})
+	if err != nil {
+		t.Fatalf("RenderTexture() error = %v", err)
+	}
+
+	// 100 x 45cm plus a 30cm margin, at 2px/cm
+	if b := tex.Image.Bounds(); b.Dx() != 320 || b.Dy() != 210 {
+		t.Fatalf("size = %v, want 320x210", b)
+	}
+
+	for _, tc := range []struct {
+		name string
+		x, y float64
+		want color.RGBA
+	}{
+		{"track", 50, 0, color.RGBA{G: 255, A: 255}},
+		{"edge line", 50, 21, mustHexColor(DefaultEdgeColor)},
+		{"floor", 50, 40, mustHexColor(DefaultFloorColor)},
+	} {
+		r, g, b, ok := tex.Sample(tc.x, tc.y)
+		got := color.RGBA{R: clampByte(r), G: clampByte(g), B: clampByte(b), A: 255}
+		if !ok || got != tc.want {
+			t.Errorf("%s at (%v, %v) = %v, want %v", tc.name, tc.x, tc.y, got, tc.want)
+		}
+	}
+	if _, _, _, ok := tex.Sample(-100, 0); ok {
+		t.Error("expected no sample outside the texture")
+	}
+
+	for name, opts := range map[string]TextureOptions{
+		"unknown floor pattern": {FloorPattern: "marble"},
+		"noise above 1":         {Noise: 2},
+		"bad colour":            {TrackColor: "#12"},
+	} {
+		if _, err := RenderTexture(project, opts); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: error = %v, want ErrInvalidOption", name, err)
+		}
+	}
+	odd := &TrackProject{Pieces: []Piece{{Type: "hovercraft-ramp", Params: PieceParams{Length: 50}}}}
+	if _, err := RenderTexture(odd, TextureOptions{}); err == nil || errors.Is(err, ErrInvalidOption) {
+		t.Errorf("unknown piece type: error = %v, want a track error", err)
+	}
+	for _, px := range []float64{math.NaN(), math.Inf(1), -1, 1e9} {
+		if _, err := RenderTexture(project, TextureOptions{PxPerCm: px}); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("pxPerCm %g: error = %v, want ErrInvalidOption", px, err)
+		}
+	}
+
+	// Within the default cap at 4 px/cm, but not at 40 or with a lower cap
+	if _, err := RenderTexture(project, TextureOptions{PxPerCm: 40}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("pxPerCm 40: error = %v, want ErrInvalidOption", err)
+	}
+	if _, err := RenderTexture(project, TextureOptions{MaxPixels: 1000}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("MaxPixels 1000: error = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestRenderTexture_Seed(t *testing.T) {
+	project := figureEight()
+	opts := TextureOptions{PxPerCm: 1, FloorPattern: FloorTiles, Noise: 0.3, Lighting: 0.5, Seed: 7}
+
+	a, err := RenderTexture(project, opts)
+	if err != nil {
+		t.Fatalf("RenderTexture() error = %v", err)
+	}
+	b, _ := RenderTexture(project, opts)
+	if !bytes.Equal(a.Image.Pix, b.Image.Pix) {
+		t.Error("same seed gave different textures")
+	}
+
+	opts.Seed = 8
+	c, _ := RenderTexture(project, opts)
+	if bytes.Equal(a.Image.Pix, c.Image.Pix) {
+		t.Error("different seeds gave the same texture")
+	}
+}
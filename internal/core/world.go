@@ -0,0 +1,299 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"image"
+	"image/png"
+	"io"
+	"math"
+	"regexp"
+)
+
+// WorldOptions controls simulator world generation
+type WorldOptions struct {
+	PxPerCm          float64 // ground texture resolution, default 2
+	MarginCm         float64 // floor around the track, default 50
+	ObstacleHeightCm float64 // default 10
+	MaxPixels        int     // ground texture size cap, default DefaultMaxRasterPixels
+}
+
+// WorldElement is a special element placed in the world. X/Y/Heading is
+// the middle of the piece on the floor (cm, degrees).
+type WorldElement struct {
+	Piece    int         `json:"piece"`
+	ID       interface{} `json:"id,omitempty"`
+	Type     string      `json:"type"`
+	X        float64     `json:"x"`
+	Y        float64     `json:"y"`
+	Heading  float64     `json:"heading"`
+	LengthCm float64     `json:"lengthCm"`
+	WidthCm  float64     `json:"widthCm"`
+	HeightCm float64     `json:"heightCm"`
+	Solid    bool        `json:"solid"` // has a collision body; otherwise it's only painted
+}
+
+// TrackWorld is a simulator-neutral description of the track: a textured
+// floor plane plus the special elements
+type TrackWorld struct {
+	Name     string
+	Texture  *image.RGBA
+	CentreX  float64 // floor plane centre (cm)
+	CentreY  float64
+	SizeX    float64 // floor plane size (cm)
+	SizeY    float64
+	Elements []WorldElement
+}
+
+// BuildWorld paints the ground texture and places the special elements.
+// Markings, crossroads and roundabouts only exist in the texture; ramps
+// and obstacles also get collision bodies.
+func BuildWorld(project *TrackProject, opts WorldOptions) (*TrackWorld, error) {
+	if opts.PxPerCm == 0 {
+		opts.PxPerCm = 2
+	}
+	if err := checkScale("pxPerCm", opts.PxPerCm); err != nil {
+		return nil, err
+	}
+	if opts.MarginCm <= 0 {
+		opts.MarginCm = 50
+	}
+	if opts.ObstacleHeightCm <= 0 {
+		opts.ObstacleHeightCm = 10
+	}
+
+	tex, err := RenderTexture(project, TextureOptions{PxPerCm: opts.PxPerCm, MarginCm: opts.MarginCm, MaxPixels: opts.MaxPixels})
+	if err != nil {
+		return nil, err
+	}
+	half := TrackWidth(project) / 2
+
+	world := &TrackWorld{
+		Name:    worldName(project),
+		Texture: tex.Image,
+		CentreX: (tex.MinX + tex.MaxX) / 2,
+		CentreY: (tex.MinY + tex.MaxY) / 2,
+		SizeX:   tex.MaxX - tex.MinX,
+		SizeY:   tex.MaxY - tex.MinY,
+	}
+
+	catalog := project.Catalog()
+	for i, piece := range project.Pieces {
+		if !isSpecialElement(catalog, piece.Type) {
+			continue
+		}
+		t, _ := catalog.Type(piece.Type)
+		length, err := catalog.laidLength(piece)
+		if err != nil {
+			return nil, fmt.Errorf("piece %d: %w", i, err)
+		}
+		mid, err := catalog.PoseAt(catalog.PlacementPose(piece), piece, length/2)
+		if err != nil {
+			return nil, fmt.Errorf("piece %d: %w", i, err)
+		}
+
+		e := WorldElement{
+			Piece:    i,
+			ID:       piece.ID,
+			Type:     piece.Type,
+			X:        mid.X,
+			Y:        mid.Y,
+			Heading:  mid.Heading,
+			LengthCm: length,
+			WidthCm:  2 * half,
+		}
+		switch {
+		case piece.Type == "obstacle":
+			e.HeightCm = opts.ObstacleHeightCm
+			e.Solid = true
+		case t.Geometry == GeometryStraight && piece.Params.Height > 0:
+			e.HeightCm = piece.Params.Height
+			e.Solid = true
+		}
+		world.Elements = append(world.Elements, e)
+	}
+
+	return world, nil
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// worldName is an identifier-safe name for the world
+func worldName(project *TrackProject) string {
+	name := unsafeNameChars.ReplaceAllString(project.ID, "_")
+	if name == "" {
+		name = "track"
+	}
+	return "track_" + name
+}
+
+// WriteTexture writes the ground texture as PNG
+func (w *TrackWorld) WriteTexture(out io.Writer) error {
+	return png.Encode(out, w.Texture)
+}
+
+// rampSlope returns the length and pitch (radians) of each half of a ramp:
+// up to its height at the middle, then back down
+func (e WorldElement) rampSlope() (float64, float64) {
+	run := e.LengthCm / 2
+	return math.Hypot(run, e.HeightCm), math.Atan2(e.HeightCm, run)
+}
+
+// WriteSDF writes a Gazebo (SDF 1.9) world. texture is the PNG file name,
+// relative to the world file.
+func (w *TrackWorld) WriteSDF(out io.Writer, texture string) error {
+	b := bufio.NewWriter(out)
+	m := func(cm float64) string { return num4(cm / 100) }
+
+	fmt.Fprintf(b, "<?xml version=\"1.0\"?>\n<sdf version=\"1.9\">\n  <world name=\"%s\">\n", w.Name)
+	fmt.Fprintf(b, `    <light type="directional" name="sun">
+      <cast_shadows>true</cast_shadows>
+      <pose>0 0 10 0 0 0</pose>
+      <diffuse>0.9 0.9 0.9 1</diffuse>
+      <specular>0.2 0.2 0.2 1</specular>
+      <direction>-0.5 0.1 -0.9</direction>
+    </light>
+`)
+
+	fmt.Fprintf(b, `    <model name="track">
+      <static>true</static>
+      <pose>%s %s 0 0 0 0</pose>
+      <link name="floor">
+        <collision name="collision">
+          <geometry><plane><normal>0 0 1</normal><size>%s %s</size></plane></geometry>
+        </collision>
+        <visual name="visual">
+          <geometry><plane><normal>0 0 1</normal><size>%s %s</size></plane></geometry>
+          <material>
+            <ambient>1 1 1 1</ambient>
+            <diffuse>1 1 1 1</diffuse>
+            <pbr><metal><albedo_map>%s</albedo_map><roughness>1</roughness><metalness>0</metalness></metal></pbr>
+          </material>
+        </visual>
+      </link>
+    </model>
+`, m(w.CentreX), m(w.CentreY), m(w.SizeX), m(w.SizeY), m(w.SizeX), m(w.SizeY), html.EscapeString(texture))
+
+	for _, e := range w.Elements {
+		name := fmt.Sprintf("%s_%d", e.Type, e.Piece)
+		yaw := num4(e.Heading * math.Pi / 180)
+		if !e.Solid {
+			// Painted elements are just named frames, for ground truth
+			fmt.Fprintf(b, "    <frame name=\"%s\"><pose>%s %s 0 0 0 %s</pose></frame>\n", name, m(e.X), m(e.Y), yaw)
+			continue
+		}
+
+		fmt.Fprintf(b, "    <model name=\"%s\">\n      <static>true</static>\n      <pose>%s %s 0 0 0 %s</pose>\n", name, m(e.X), m(e.Y), yaw)
+		if e.Type == "obstacle" {
+			writeSDFBox(b, "body", 0, e.HeightCm/2, 0, e.LengthCm, e.WidthCm, e.HeightCm, "0.61 0.64 0.69 1")
+		} else {
+			slope, pitch := e.rampSlope()
+			writeSDFBox(b, "up", -e.LengthCm/4, e.HeightCm/2, -pitch, slope, e.WidthCm, 1, "0.8 0.8 0.8 1")
+			writeSDFBox(b, "down", e.LengthCm/4, e.HeightCm/2, pitch, slope, e.WidthCm, 1, "0.8 0.8 0.8 1")
+		}
+		fmt.Fprintf(b, "    </model>\n")
+	}
+
+	fmt.Fprintf(b, "  </world>\n</sdf>\n")
+	return b.Flush()
+}
+
+func writeSDFBox(b io.Writer, name string, x, z, pitch, length, width, height float64, color string) {
+	m := func(cm float64) string { return num4(cm / 100) }
+	size := fmt.Sprintf("%s %s %s", m(length), m(width), m(height))
+	fmt.Fprintf(b, `      <link name="%s">
+        <pose>%s 0 %s 0 %s 0</pose>
+        <collision name="collision"><geometry><box><size>%s</size></box></geometry></collision>
+        <visual name="visual">
+          <geometry><box><size>%s</size></box></geometry>
+          <material><ambient>%s</ambient><diffuse>%s</diffuse></material>
+        </visual>
+      </link>
+`, name, m(x), m(z), num4(pitch), size, size, color, color)
+}
+
+// WriteWebots writes a Webots (R2023b, z-up) world. texture is the PNG file
+// name, relative to the world file.
+func (w *TrackWorld) WriteWebots(out io.Writer, texture string) error {
+	b := bufio.NewWriter(out)
+	m := func(cm float64) string { return num4(cm / 100) }
+
+	fmt.Fprintf(b, "#VRML_SIM R2023b utf8\n\n")
+	fmt.Fprintf(b, "WorldInfo {\n  title \"%s\"\n  basicTimeStep 16\n}\n", w.Name)
+	fmt.Fprintf(b, "Viewpoint {\n  orientation 0 1 0 1.5708\n  position %s %s %s\n}\n",
+		m(w.CentreX), m(w.CentreY), m(math.Max(w.SizeX, w.SizeY)))
+	fmt.Fprintf(b, "Background {\n  skyColor [ 0.4 0.7 1 ]\n}\n")
+	fmt.Fprintf(b, "DirectionalLight {\n  direction -0.5 0.1 -0.9\n  castShadows TRUE\n}\n")
+
+	fmt.Fprintf(b, `Solid {
+  translation %s %s 0
+  name "track"
+  children [
+    Shape {
+      appearance PBRAppearance {
+        baseColorMap ImageTexture {
+          url [ "%s" ]
+        }
+        roughness 1
+        metalness 0
+      }
+      geometry Plane {
+        size %s %s
+      }
+    }
+  ]
+  boundingObject Plane {
+    size %s %s
+  }
+}
+`, m(w.CentreX), m(w.CentreY), texture, m(w.SizeX), m(w.SizeY), m(w.SizeX), m(w.SizeY))
+
+	for _, e := range w.Elements {
+		name := fmt.Sprintf("%s_%d", e.Type, e.Piece)
+		yaw := num4(e.Heading * math.Pi / 180)
+		fmt.Fprintf(b, "Solid {\n  translation %s %s 0\n  rotation 0 0 1 %s\n  name \"%s\"\n", m(e.X), m(e.Y), yaw, name)
+		if e.Solid {
+			fmt.Fprintf(b, "  children [\n")
+			if e.Type == "obstacle" {
+				writeWebotsBox(b, "body", 0, e.HeightCm/2, 0, e.LengthCm, e.WidthCm, e.HeightCm, "0.61 0.64 0.69")
+			} else {
+				slope, pitch := e.rampSlope()
+				writeWebotsBox(b, "up", -e.LengthCm/4, e.HeightCm/2, -pitch, slope, e.WidthCm, 1, "0.8 0.8 0.8")
+				writeWebotsBox(b, "down", e.LengthCm/4, e.HeightCm/2, pitch, slope, e.WidthCm, 1, "0.8 0.8 0.8")
+			}
+			fmt.Fprintf(b, "  ]\n")
+		}
+		fmt.Fprintf(b, "}\n")
+	}
+
+	return b.Flush()
+}
+
+func writeWebotsBox(b io.Writer, name string, x, z, pitch, length, width, height float64, color string) {
+	m := func(cm float64) string { return num4(cm / 100) }
+	fmt.Fprintf(b, `    Solid {
+      translation %s 0 %s
+      rotation 0 1 0 %s
+      name "%s"
+      children [
+        Shape {
+          appearance PBRAppearance {
+            baseColor %s
+            roughness 1
+            metalness 0
+          }
+          geometry DEF BOX Box {
+            size %s %s %s
+          }
+        }
+      ]
+      boundingObject USE BOX
+    }
+`, m(x), m(z), num4(pitch), name, color, m(length), m(width), m(height))
+}
+
+// num4 formats a value with at most four decimals (0.1mm in metres)
+func num4(v float64) string {
+	return formatParam(math.Round(v*10000) / 10000)
+}
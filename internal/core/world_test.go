@@ -0,0 +1,94 @@
+package core
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestBuildWorld(t *testing.T) {
+	pieces := []Piece{
+		{ID: "a", Type: "straight", Params: PieceParams{Length: 50}},
+		{ID: "z", Type: "zebra", Params: PieceParams{Length: 25}},
+		{ID: "b", Type: "straight", Params: PieceParams{Length: 50}},
+		{ID: "p", Type: "ramp", Params: PieceParams{Length: 100, Height: 10}},
+		{ID: "o", Type: "obstacle", Params: PieceParams{Length: 50}},
+	}
+	poses, _ := defaultCatalog.ChainPoses(pieces, Pose{})
+	for i := range pieces {
+		pieces[i] = defaultCatalog.placePiece(pieces[i], poses[i].Entry)
+	}
+	project := &TrackProject{ID: "demo-1", Pieces: pieces}
+
+	world, err := BuildWorld(project, WorldOptions{PxPerCm: 1})
+	if err != nil {
+		t.Fatalf("BuildWorld() error = %v", err)
+	}
+	if world.Name != "track_demo_1" {
+		t.Errorf("name = %s", world.Name)
+	}
+	// 275cm of track plus 50cm each side, at 1px/cm
+	if b := world.Texture.Bounds(); b.Dx() != 375 || !almostEqual(world.SizeX, 375) {
+		t.Errorf("texture %v for a %vcm floor", b, world.SizeX)
+	}
+	if len(world.Elements) != 3 {
+		t.Fatalf("got %d elements, want 3", len(world.Elements))
+	}
+	ramp := world.Elements[1]
+	if ramp.Type != "ramp" || !ramp.Solid || !almostEqual(ramp.X, 175) || !almostEqual(ramp.HeightCm, 10) {
+		t.Errorf("ramp = %+v", ramp)
+	}
+	if world.Elements[0].Solid {
+		t.Error("zebra should only be painted")
+	}
+
+	var sdf bytes.Buffer
+	if err := world.WriteSDF(&sdf, "demo-1.png"); err != nil {
+		t.Fatalf("WriteSDF() error = %v", err)
+	}
+	dec := xml.NewDecoder(bytes.NewReader(sdf.Bytes()))
+	for {
+		if _, err := dec.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("SDF is not well-formed: %v", err)
+		}
+	}
+	for _, want := range []string{
+		`<world name="track_demo_1">`,
+		`<albedo_map>demo-1.png</albedo_map>`,
+		`<frame name="zebra_1"><pose>0.625 0 0 0 0 0</pose></frame>`,
+		`<model name="ramp_3">`,
+		`<model name="obstacle_4">`,
+	} {
+		if !strings.Contains(sdf.String(), want) {
+			t.Errorf("SDF missing %q", want)
+		}
+	}
+
+	var wbt bytes.Buffer
+	if err := world.WriteWebots(&wbt, "demo-1.png"); err != nil {
+		t.Fatalf("WriteWebots() error = %v", err)
+	}
+	for _, want := range []string{"#VRML_SIM R2023b utf8", `url [ "demo-1.png" ]`, `name "ramp_3"`} {
+		if !strings.Contains(wbt.String(), want) {
+			t.Errorf("Webots world missing %q", want)
+		}
+	}
+	if strings.Count(wbt.String(), "{") != strings.Count(wbt.String(), "}") {
+		t.Error("unbalanced braces in Webots world")
+	}
+}
+
+func TestBuildWorld_InvalidResolution(t *testing.T) {
+	project := &TrackProject{Pieces: []Piece{{Type: "straight", Params: PieceParams{Length: 50}}}}
+	for _, px := range []float64{math.NaN(), math.Inf(1), -2} {
+		if _, err := BuildWorld(project, WorldOptions{PxPerCm: px}); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("pxPerCm %g: error = %v, want ErrInvalidOption", px, err)
+		}
+	}
+}
@@ -23,7 +23,7 @@ func setupTestServer(t *testing.T) (*httptest.Server, *mw.RateLimiterGroup, *sto
 	}
 
 	// Create handler
-	handler := api.NewHandler(st, 5)
+	handler := api.NewHandler(st, 5, 0)
 
 	// Create rate limiters with test-friendly limits
 	rateLimiters := mw.NewRateLimiterGroup()
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BlobStore keeps the files behind the database: track documents,
+// thumbnails and revisions. Keys are slash-separated paths like
+// "tracks/<id>.json". Missing blobs are reported as fs.ErrNotExist.
+type BlobStore interface {
+	Put(key string, data []byte, contentType string) error
+	Get(key string) ([]byte, BlobInfo, error)
+	Stat(key string) (BlobInfo, error)
+	// Delete removes a blob; deleting a missing one is not an error
+	Delete(key string) error
+}
+
+// BlobInfo describes a stored blob
+type BlobInfo struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// Blob store backends, for OpenBlobStore
+const (
+	BlobBackendLocal = "local" // default
+	BlobBackendS3    = "s3"
+)
+
+// OpenBlobStore returns the configured backend: files under dataDir, or an
+// S3-compatible bucket
+func OpenBlobStore(backend, dataDir string, s3 S3Config) (BlobStore, error) {
+	switch backend {
+	case "", BlobBackendLocal:
+		return NewLocalBlobStore(dataDir), nil
+	case BlobBackendS3:
+		return NewS3BlobStore(s3)
+	default:
+		return nil, fmt.Errorf("unknown blob store %q, want %s or %s", backend, BlobBackendLocal, BlobBackendS3)
+	}
+}
+
+func trackKey(id string) string {
+	return "tracks/" + id + ".json"
+}
+
+func thumbnailKey(id string) string {
+	return "thumbnails/" + id + ".png"
+}
+
+func revisionKey(hash string) string {
+	return "revisions/" + hash[:2] + "/" + hash + ".json"
+}
+
+// LocalBlobStore keeps blobs as files under a directory, with the same
+// layout the data directory always had
+type LocalBlobStore struct {
+	root string
+}
+
+func NewLocalBlobStore(root string) *LocalBlobStore {
+	return &LocalBlobStore{root: root}
+}
+
+func (b *LocalBlobStore) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if clean == "." || filepath.IsAbs(clean) || strings.HasPrefix(clean, ".."+string(filepath.Separator)) || clean == ".." {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(b.root, clean), nil
+}
+
+// Put writes atomically so readers never see a half-written file
+func (b *LocalBlobStore) Put(key string, data []byte, contentType string) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Each write gets its own temporary file, so concurrent writes to one
+	// key can't rename each other's file away
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (b *LocalBlobStore) Get(key string) ([]byte, BlobInfo, error) {
+	path, err := b.path(key)
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, BlobInfo{}, err
+	}
+	return data, BlobInfo{Size: int64(len(data)), ModTime: info.ModTime()}, nil
+}
+
+func (b *LocalBlobStore) Stat(key string) (BlobInfo, error) {
+	path, err := b.path(key)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	return BlobInfo{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (b *LocalBlobStore) Delete(key string) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// Database drivers, for Config.Driver
+const (
+	DriverSQLite   = "sqlite" // default: dataDir/tracks.db
+	DriverPostgres = "postgres"
+)
+
+// dialect is what differs between the databases the store runs on. The
+// queries are written for SQLite with ? placeholders and adapted here.
+type dialect struct {
+	name string
+	// numbered placeholders ($1, $2, ...) instead of ?
+	numbered bool
+	// case-insensitive LIKE, which SQLite's LIKE already is for ASCII
+	ilike string
+	// column types in CREATE TABLE that need another name
+	types *strings.Replacer
+	// full-text search through the tracks_fts FTS5 table; without it
+	// search is ILIKE on each field
+	fts bool
+	// lockTrack takes a per-track lock for the rest of the transaction.
+	// SQLite doesn't need one: its single connection runs one transaction
+	// at a time.
+	lockTrack string
+}
+
+var (
+	sqliteDialect = &dialect{
+		name:  DriverSQLite,
+		ilike: "LIKE",
+		types: strings.NewReplacer(),
+		fts:   true,
+	}
+	postgresDialect = &dialect{
+		name:      DriverPostgres,
+		numbered:  true,
+		ilike:     "ILIKE",
+		lockTrack: "SELECT pg_advisory_xact_lock(hashtext(?))",
+		types: strings.NewReplacer(
+			" DATETIME", " TIMESTAMPTZ",
+			" REAL", " DOUBLE PRECISION",
+		),
+	}
+)
+
+// rebind rewrites ? placeholders outside string literals for the driver
+func (d *dialect) rebind(query string) string {
+	if !d.numbered || !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	inString := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'':
+			inString = !inString
+		case c == '?' && !inString:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// schema adapts CREATE TABLE statements to the database's type names
+func (d *dialect) schema(ddl string) string {
+	return d.types.Replace(ddl)
+}
+
+// DB is a database handle that takes SQLite-style queries on either
+// database
+type DB struct {
+	*sql.DB
+	dialect *dialect
+}
+
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(db.dialect.rebind(query), args...)
+}
+
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(db.dialect.rebind(query), args...)
+}
+
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(db.dialect.rebind(query), args...)
+}
+
+func (db *DB) Begin() (*Tx, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, dialect: db.dialect}, nil
+}
+
+// Tx is a transaction on a DB
+type Tx struct {
+	*sql.Tx
+	dialect *dialect
+}
+
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(tx.dialect.rebind(query), args...)
+}
+
+func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.dialect.rebind(query), args...)
+}
+
+func (tx *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRow(tx.dialect.rebind(query), args...)
+}
+
+// openDB connects to the configured database
+func openDB(cfg Config) (*DB, error) {
+	switch cfg.Driver {
+	case "", DriverSQLite:
+		dbPath := filepath.Join(cfg.DataDir, "tracks.db")
+		// 添加SQLite连接参数以支持并发和WAL模式
+		db, err := sql.Open("sqlite", dbPath+"?_busy_timeout=5000&_journal_mode=WAL")
+		if err != nil {
+			return nil, err
+		}
+
+		// 设置连接池参数以减少并发冲突
+		db.SetMaxOpenConns(1) // SQLite建议使用单连接
+		db.SetMaxIdleConns(1)
+		db.SetConnMaxLifetime(0)
+		return &DB{DB: db, dialect: sqliteDialect}, nil
+
+	case DriverPostgres:
+		if cfg.DatabaseURL == "" {
+			return nil, fmt.Errorf("postgres needs a database URL")
+		}
+		db, err := sql.Open("pgx", cfg.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.Ping(); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("connect to postgres: %w", err)
+		}
+
+		maxConns := cfg.MaxOpenConns
+		if maxConns <= 0 {
+			maxConns = 20
+		}
+		db.SetMaxOpenConns(maxConns)
+		db.SetMaxIdleConns(maxConns / 2)
+		return &DB{DB: db, dialect: postgresDialect}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown database driver %q, want %s or %s", cfg.Driver, DriverSQLite, DriverPostgres)
+	}
+}
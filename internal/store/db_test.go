@@ -0,0 +1,214 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asc-lab/track-designer/internal/core"
+)
+
+func TestDialectRebind(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"SELECT * FROM tracks", "SELECT * FROM tracks"},
+		{"SELECT * FROM tracks WHERE id = ?", "SELECT * FROM tracks WHERE id = $1"},
+		{"UPDATE tracks SET likes = ? WHERE id = ? AND name != '?'", "UPDATE tracks SET likes = $1 WHERE id = $2 AND name != '?'"},
+		{"SELECT 'it''s ?', ?", "SELECT 'it''s ?', $1"},
+		{postgresDialect.lockTrack, "SELECT pg_advisory_xact_lock(hashtext($1))"},
+	}
+	for _, tt := range tests {
+		if got := postgresDialect.rebind(tt.query); got != tt.want {
+			t.Errorf("rebind(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+		if got := sqliteDialect.rebind(tt.query); got != tt.query {
+			t.Errorf("sqlite rebind(%q) = %q, want it unchanged", tt.query, got)
+		}
+	}
+}
+
+func TestDialectSchema(t *testing.T) {
+	got := postgresDialect.schema("created_at DATETIME NOT NULL, max_speed REAL NOT NULL")
+	want := "created_at TIMESTAMPTZ NOT NULL, max_speed DOUBLE PRECISION NOT NULL"
+	if got != want {
+		t.Errorf("schema = %q, want %q", got, want)
+	}
+}
+
+// TestStore_SearchWithoutFTS runs the search PostgreSQL uses, per-word
+// ILIKE with name matches first, on SQLite's LIKE
+func TestStore_SearchWithoutFTS(t *testing.T) {
+	s := newTestStore(t)
+	s.db.dialect = &dialect{name: "sqlite-like", ilike: "LIKE", types: strings.NewReplacer()}
+
+	for i, p := range []*core.TrackProject{
+		{ID: "t1", Name: "Figure Eight", Description: "Crosses an oval bridge"},
+		{ID: "t2", Name: "Lab Oval", Description: "Warm-up loop", Tags: []string{"beginner"}},
+		{ID: "t3", Name: "Hairpins", UploaderName: "Ovaltine"},
+	} {
+		p.CreatedAt = time.Now().Add(time.Duration(-i) * time.Minute)
+		p.Pieces = []core.Piece{{ID: 1, Type: "straight", Params: core.PieceParams{Length: 50}}}
+		if _, err := s.SaveTrack(p, RevisionInfo{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		sort  string
+		want  []string
+	}{
+		{"OVAL", "", []string{"t2", "t1", "t3"}}, // name match first, then newest
+		{"OVAL", SortNewest, []string{"t1", "t2", "t3"}},
+		{"oval bridge", "", []string{"t1"}},
+		{"beginner", "", []string{"t2"}},
+		{"nothing", "", []string{}},
+	}
+	for _, tt := range tests {
+		tracks, total, err := s.ListTracksWithFilters(1, 10, TrackFilters{Query: tt.query, Sort: tt.sort})
+		if err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		got := []string{}
+		for _, track := range tracks {
+			got = append(got, track.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) || total != len(tt.want) {
+			t.Errorf("ListTracksWithFilters(%q, %q) = %v (total %d), want %v", tt.query, tt.sort, got, total, tt.want)
+		}
+	}
+}
+
+func TestOpenUnknownDriver(t *testing.T) {
+	if _, err := Open(Config{DataDir: t.TempDir(), Driver: "mysql"}); err == nil {
+		t.Error("Open with an unknown driver succeeded")
+	}
+	if _, err := Open(Config{DataDir: t.TempDir(), Driver: DriverPostgres}); err == nil {
+		t.Error("Open postgres without a URL succeeded")
+	}
+}
+
+// TestStore_Postgres runs the repository against a real server. Set
+// TRACKD_TEST_POSTGRES_URL to run it; each run gets a schema of its own.
+func TestStore_Postgres(t *testing.T) {
+	baseURL := os.Getenv("TRACKD_TEST_POSTGRES_URL")
+	if baseURL == "" {
+		t.Skip("TRACKD_TEST_POSTGRES_URL not set")
+	}
+
+	admin, err := sql.Open("pgx", baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	schema := fmt.Sprintf("trackd_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+
+	s, err := NewFromConfig(Config{DataDir: t.TempDir(), Driver: DriverPostgres, DatabaseURL: u.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Migrating again is a no-op
+	if applied, err := s.Migrate(); err != nil || len(applied) != 0 {
+		t.Fatalf("second Migrate = %v, %v", applied, err)
+	}
+
+	var repo Repository = s
+	for i, name := range []string{"Oval", "Figure Eight"} {
+		project := &core.TrackProject{
+			ID:        fmt.Sprintf("t%d", i+1),
+			Name:      name,
+			Tags:      []string{"demo"},
+			CreatedAt: time.Now().Add(time.Duration(i) * time.Minute),
+			Pieces: []core.Piece{
+				{ID: 1, Type: "straight", Params: core.PieceParams{Length: 50}},
+			},
+		}
+		if _, err := repo.SaveTrack(project, RevisionInfo{Message: "first"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Name search is case-insensitive on both databases
+	tracks, total, err := repo.ListTracksWithFilters(1, 10, TrackFilters{Query: "oval", Tags: []string{"demo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(tracks) != 1 || tracks[0].ID != "t1" {
+		t.Errorf("ListTracksWithFilters = %d %+v", total, tracks)
+	}
+	if tags, err := repo.GetAllTags(); err != nil || len(tags) != 1 || tags[0] != "demo" {
+		t.Errorf("GetAllTags = %v, %v", tags, err)
+	}
+
+	likes, liked, err := repo.ToggleLike("t1", "10.0.0.1")
+	if err != nil || likes != 1 || !liked {
+		t.Errorf("ToggleLike = %d %v %v", likes, liked, err)
+	}
+	if liked, err := repo.GetLikeStatus("t1", "10.0.0.1"); err != nil || !liked {
+		t.Errorf("GetLikeStatus = %v %v", liked, err)
+	}
+	if err := repo.IncrementDownloads("t1"); err != nil {
+		t.Error(err)
+	}
+
+	user := &User{ID: "u1", Login: "octocat", Name: "Octo"}
+	if err := repo.UpsertUser(user); err != nil {
+		t.Fatal(err)
+	}
+	user.Name = "Octo Cat"
+	if err := repo.UpsertUser(user); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.GetUserByLogin("octocat"); err != nil || got.Name != "Octo Cat" {
+		t.Errorf("GetUserByLogin = %+v, %v", got, err)
+	}
+
+	// Revisions are numbered under the track's advisory lock
+	changed := straightTrack("t1", 80)
+	if rev, err := repo.SaveTrack(changed, RevisionInfo{Message: "longer"}); err != nil || rev.Revision != 2 {
+		t.Errorf("SaveTrack = %+v, %v, want revision 2", rev, err)
+	}
+	if revisions, err := repo.ListTrackRevisions("t1"); err != nil || len(revisions) != 2 {
+		t.Errorf("ListTrackRevisions = %+v, %v", revisions, err)
+	}
+
+	if err := repo.SaveVehicleProfile(&VehicleProfile{ID: "v1", UserID: "u1", Name: "Stock"}); err != nil {
+		t.Fatal(err)
+	}
+	if profiles, err := repo.ListVehicleProfiles("u1"); err != nil || len(profiles) != 1 {
+		t.Errorf("ListVehicleProfiles = %+v, %v", profiles, err)
+	}
+	if err := repo.SetInventory("u1", core.Inventory{"S-50": 4}); err != nil {
+		t.Fatal(err)
+	}
+	if inv, err := repo.GetInventory("u1"); err != nil || inv["S-50"] != 4 {
+		t.Errorf("GetInventory = %v, %v", inv, err)
+	}
+
+	if err := repo.DeleteTrack("t2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, err := repo.ListTracks(1, 10, ""); err != nil || total != 1 {
+		t.Errorf("ListTracks after delete: total %d, %v", total, err)
+	}
+}
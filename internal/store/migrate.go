@@ -0,0 +1,345 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Migration is one numbered schema change. Each runs in its own
+// transaction together with its schema_migrations row, so it's either
+// fully applied or not at all.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *Tx) error
+}
+
+// MigrationState is a migration and when it was applied, nil if pending
+type MigrationState struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// migrations is the schema history, in order. Append only: never edit or
+// renumber one that has shipped. The ones up to 6 predate
+// schema_migrations, so they must be no-ops on databases that already
+// have their tables and columns.
+var migrations = []Migration{
+	{1, "create tracks, users and likes", execMigration(`
+	CREATE TABLE IF NOT EXISTS tracks (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		description TEXT DEFAULT '',
+		tags TEXT DEFAULT '[]',
+		uploader_id TEXT,
+		uploader_name TEXT DEFAULT '',
+		uploader_avatar TEXT DEFAULT '',
+		created_at DATETIME NOT NULL,
+		total_pieces INTEGER DEFAULT 0,
+		total_length TEXT DEFAULT '0.00',
+		total_length_cm INTEGER DEFAULT 0,
+		thumbnail TEXT DEFAULT '',
+		likes INTEGER DEFAULT 0,
+		downloads INTEGER DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_tracks_created ON tracks(created_at DESC);
+
+	CREATE TABLE IF NOT EXISTS users (
+		id TEXT PRIMARY KEY,
+		login TEXT NOT NULL UNIQUE,
+		name TEXT DEFAULT '',
+		email TEXT DEFAULT '',
+		avatar_url TEXT DEFAULT '',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_users_login ON users(login);
+
+	-- 用户点赞记录表(防止重复点赞)
+	CREATE TABLE IF NOT EXISTS track_likes (
+		track_id TEXT NOT NULL,
+		user_ip TEXT NOT NULL,
+		liked_at DATETIME NOT NULL,
+		PRIMARY KEY (track_id, user_ip)
+	);
+	CREATE INDEX IF NOT EXISTS idx_track_likes_track ON track_likes(track_id);
+	`)},
+	{2, "add track metadata and counters", func(tx *Tx) error {
+		// Databases from before these columns were in CREATE TABLE
+		columns := []struct{ name, decl string }{
+			{"description", "TEXT DEFAULT ''"},
+			{"thumbnail", "TEXT DEFAULT ''"},
+			{"tags", "TEXT DEFAULT '[]'"},
+			{"total_length_cm", "INTEGER DEFAULT 0"},
+			{"uploader_name", "TEXT DEFAULT ''"},
+			{"uploader_avatar", "TEXT DEFAULT ''"},
+			{"likes", "INTEGER DEFAULT 0"},
+			{"downloads", "INTEGER DEFAULT 0"},
+		}
+		for _, c := range columns {
+			if err := addColumn(tx, "tracks", c.name, c.decl); err != nil {
+				return err
+			}
+		}
+		return execMigration(`
+		CREATE INDEX IF NOT EXISTS idx_tracks_length ON tracks(total_length_cm);
+		CREATE INDEX IF NOT EXISTS idx_tracks_likes ON tracks(likes DESC);
+		CREATE INDEX IF NOT EXISTS idx_tracks_downloads ON tracks(downloads DESC);
+		`)(tx)
+	}},
+	// Thumbnails are rendered to files now; drop client-supplied images
+	{3, "clear stored thumbnails", execMigration(`UPDATE tracks SET thumbnail = '' WHERE thumbnail != ''`)},
+	{4, "create vehicle profiles", execMigration(`
+	CREATE TABLE IF NOT EXISTS vehicle_profiles (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		max_speed REAL NOT NULL,
+		max_lateral_accel REAL NOT NULL,
+		max_accel REAL NOT NULL,
+		max_brake REAL NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_vehicle_profiles_user ON vehicle_profiles(user_id);
+	`)},
+	{5, "add track difficulty", func(tx *Tx) error {
+		if err := addColumn(tx, "tracks", "difficulty", "REAL"); err != nil {
+			return err
+		}
+		_, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_tracks_difficulty ON tracks(difficulty)")
+		return err
+	}},
+	{6, "create track parts and inventories", execMigration(`
+	-- Parts each track needs, by BOM code, for the buildable filter
+	CREATE TABLE IF NOT EXISTS track_parts (
+		track_id TEXT NOT NULL,
+		code TEXT NOT NULL,
+		quantity INTEGER NOT NULL,
+		PRIMARY KEY (track_id, code)
+	);
+
+	-- Parts each user owns
+	CREATE TABLE IF NOT EXISTS inventory_items (
+		user_id TEXT NOT NULL,
+		code TEXT NOT NULL,
+		quantity INTEGER NOT NULL,
+		PRIMARY KEY (user_id, code)
+	);
+	`)},
+	{7, "create track revisions", execMigration(`
+	-- Every saved version of a track; the JSON lives in revisions/ by hash
+	CREATE TABLE IF NOT EXISTS track_revisions (
+		track_id TEXT NOT NULL,
+		revision INTEGER NOT NULL,
+		hash TEXT NOT NULL,
+		author_id TEXT DEFAULT '',
+		author_name TEXT DEFAULT '',
+		message TEXT DEFAULT '',
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (track_id, revision)
+	);
+	CREATE INDEX IF NOT EXISTS idx_track_revisions_hash ON track_revisions(hash);
+	`)},
+	{8, "create track search index", createSearchIndex},
+}
+
+// execMigration is a migration that only runs SQL
+func execMigration(query string) func(tx *Tx) error {
+	return func(tx *Tx) error {
+		_, err := tx.Exec(tx.dialect.schema(query))
+		return err
+	}
+}
+
+// createSearchIndex creates tracks_fts and indexes the tracks already
+// saved. PostgreSQL has no FTS5; it searches with ILIKE instead.
+func createSearchIndex(tx *Tx) error {
+	if !tx.dialect.fts {
+		return nil
+	}
+	if _, err := tx.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS tracks_fts USING fts5(
+			track_id UNINDEXED, name, description, tags, uploader
+		)
+	`); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT id, name, description, tags, uploader_name FROM tracks")
+	if err != nil {
+		return err
+	}
+	type row struct {
+		id, name, description, uploader string
+		tags                            []string
+	}
+	var tracks []row
+	for rows.Next() {
+		var t row
+		var description, tagsJSON, uploader sql.NullString
+		if err := rows.Scan(&t.id, &t.name, &description, &tagsJSON, &uploader); err != nil {
+			rows.Close()
+			return err
+		}
+		t.description, t.uploader = description.String, uploader.String
+		json.Unmarshal([]byte(tagsJSON.String), &t.tags)
+		tracks = append(tracks, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, t := range tracks {
+		if err := indexTrack(tx, t.id, t.name, t.description, t.tags, t.uploader); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumn adds a column unless the table already has it. SQLite has no
+// ADD COLUMN IF NOT EXISTS.
+func addColumn(tx *Tx, table, column, decl string) error {
+	decl = tx.dialect.schema(" " + decl)
+	if tx.dialect == postgresDialect {
+		_, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s%s", table, column, decl))
+		return err
+	}
+
+	rows, err := tx.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if strings.EqualFold(name, column) {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s%s", table, column, decl))
+	return err
+}
+
+// appliedMigrations returns when each applied version was applied
+func (s *Store) appliedMigrations() (map[int]time.Time, error) {
+	if _, err := s.db.Exec(s.db.dialect.schema(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)
+	`)); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// MigrationStatus lists every migration this build knows, oldest first,
+// with when it was applied
+func (s *Store) MigrationStatus() ([]MigrationState, error) {
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKnownMigrations(applied); err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, len(migrations))
+	for i, m := range migrations {
+		states[i] = MigrationState{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			states[i].AppliedAt = &at
+		}
+	}
+	return states, nil
+}
+
+// Migrate applies pending migrations in order and returns the ones it
+// applied. It stops at the first failure, leaving that migration and
+// everything after it pending.
+func (s *Store) Migrate() ([]MigrationState, error) {
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKnownMigrations(applied); err != nil {
+		return nil, err
+	}
+
+	done := []MigrationState{}
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		at, err := s.applyMigration(m)
+		if err != nil {
+			return done, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		done = append(done, MigrationState{Version: m.Version, Name: m.Name, AppliedAt: &at})
+	}
+	return done, nil
+}
+
+func (s *Store) applyMigration(m Migration) (time.Time, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	if err := m.Up(tx); err != nil {
+		return time.Time{}, err
+	}
+	now := time.Now().UTC()
+	if _, err := tx.Exec(
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		m.Version, m.Name, now,
+	); err != nil {
+		return time.Time{}, err
+	}
+	return now, tx.Commit()
+}
+
+// checkKnownMigrations refuses databases migrated by a newer build, whose
+// schema this one may not understand
+func checkKnownMigrations(applied map[int]time.Time) error {
+	latest := migrations[len(migrations)-1].Version
+	for version := range applied {
+		if version > latest {
+			return fmt.Errorf("database is at schema version %d, newer than this build (%d)", version, latest)
+		}
+	}
+	return nil
+}
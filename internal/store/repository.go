@@ -0,0 +1,68 @@
+package store
+
+import "github.com/asc-lab/track-designer/internal/core"
+
+// TrackRepository is the track library: saved tracks, their metadata and
+// search
+type TrackRepository interface {
+	SaveTrack(project *core.TrackProject, info RevisionInfo) (*TrackRevision, error)
+	GetTrack(id string) (*core.TrackProject, error)
+	DeleteTrack(id string) error
+	ListTracks(page, size int, query string) ([]core.TrackMetadata, int, error)
+	ListTracksWithFilters(page, size int, f TrackFilters) ([]core.TrackMetadata, int, error)
+	GetAllTags() ([]string, error)
+	IncrementDownloads(trackID string) error
+	Thumbnail(id string) ([]byte, BlobInfo, error)
+}
+
+// RevisionRepository holds the saved versions of each track
+type RevisionRepository interface {
+	ListTrackRevisions(trackID string) ([]TrackRevision, error)
+	GetTrackRevision(trackID string, revision int) (*TrackRevision, *core.TrackProject, error)
+	RollbackTrack(trackID string, revision int, info RevisionInfo) (*TrackRevision, error)
+}
+
+// UserRepository holds signed-in users
+type UserRepository interface {
+	UpsertUser(user *User) error
+	GetUser(id string) (*User, error)
+	GetUserByLogin(login string) (*User, error)
+}
+
+// LikeRepository holds likes, one per track and visitor
+type LikeRepository interface {
+	ToggleLike(trackID, userIP string) (int, bool, error)
+	GetLikeStatus(trackID, userIP string) (bool, error)
+}
+
+// VehicleRepository holds each user's vehicle profiles
+type VehicleRepository interface {
+	SaveVehicleProfile(p *VehicleProfile) error
+	ListVehicleProfiles(userID string) ([]VehicleProfile, error)
+	GetVehicleProfile(userID, id string) (*VehicleProfile, error)
+	DeleteVehicleProfile(userID, id string) error
+}
+
+// InventoryRepository holds the parts each user owns
+type InventoryRepository interface {
+	GetInventory(userID string) (core.Inventory, error)
+	SetInventory(userID string, inv core.Inventory) error
+	SetInventoryItem(userID, code string, quantity int) error
+}
+
+// Repository is everything a Store keeps: tracks and their revisions,
+// users, likes, vehicle profiles and inventories, plus the lab config the
+// tracks are built from. The Store implements it on SQLite or PostgreSQL
+// (Config.Driver).
+type Repository interface {
+	TrackRepository
+	RevisionRepository
+	UserRepository
+	LikeRepository
+	VehicleRepository
+	InventoryRepository
+
+	Lab() *core.Lab
+}
+
+var _ Repository = (*Store)(nil)
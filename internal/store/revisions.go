@@ -0,0 +1,254 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/asc-lab/track-designer/internal/core"
+)
+
+// TrackRevision is one saved version of a track. The content is stored
+// once per hash under revisions/, so identical versions share a blob.
+type TrackRevision struct {
+	TrackID    string    `json:"trackId"`
+	Revision   int       `json:"revision"` // 1, 2, ... per track
+	Hash       string    `json:"hash"`     // SHA-256 of the track JSON
+	AuthorID   string    `json:"authorId,omitempty"`
+	AuthorName string    `json:"authorName,omitempty"`
+	Message    string    `json:"message,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// RevisionInfo describes who saved a revision and why
+type RevisionInfo struct {
+	AuthorID   string
+	AuthorName string
+	Message    string
+}
+
+// writeRevisionBlob stores track JSON under its hash. Blobs are immutable,
+// so an existing one is left alone.
+func (s *Store) writeRevisionBlob(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	if _, err := s.blobs.Stat(revisionKey(hash)); err == nil {
+		return hash, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	return hash, s.blobs.Put(revisionKey(hash), data, "application/json")
+}
+
+// recordRevision adds the next revision of a track, unless data is what
+// the latest revision already holds
+func (s *Store) recordRevision(trackID string, data []byte, info RevisionInfo) (*TrackRevision, error) {
+	hash, err := s.writeRevisionBlob(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reading the latest revision and inserting the next one happen in one
+	// transaction holding the track's lock, so concurrent saves get
+	// consecutive numbers. The (track_id, revision) primary key refuses a
+	// duplicate if anything slips past.
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if tx.dialect.lockTrack != "" {
+		if _, err := tx.Exec(tx.dialect.lockTrack, trackID); err != nil {
+			return nil, err
+		}
+	}
+
+	latest, err := scanRevision(tx.QueryRow(`
+		SELECT track_id, revision, hash, author_id, author_name, message, created_at
+		FROM track_revisions WHERE track_id = ?
+		ORDER BY revision DESC LIMIT 1
+	`, trackID))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if latest != nil && latest.Hash == hash {
+		return latest, nil
+	}
+
+	rev := &TrackRevision{
+		TrackID:    trackID,
+		Revision:   1,
+		Hash:       hash,
+		AuthorID:   info.AuthorID,
+		AuthorName: info.AuthorName,
+		Message:    info.Message,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if latest != nil {
+		rev.Revision = latest.Revision + 1
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO track_revisions (track_id, revision, hash, author_id, author_name, message, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, rev.TrackID, rev.Revision, rev.Hash, rev.AuthorID, rev.AuthorName, rev.Message, rev.CreatedAt); err != nil {
+		return nil, err
+	}
+	return rev, tx.Commit()
+}
+
+func scanRevision(row interface{ Scan(...interface{}) error }) (*TrackRevision, error) {
+	var rev TrackRevision
+	if err := row.Scan(&rev.TrackID, &rev.Revision, &rev.Hash,
+		&rev.AuthorID, &rev.AuthorName, &rev.Message, &rev.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// ListTrackRevisions returns a track's revisions, newest first
+func (s *Store) ListTrackRevisions(trackID string) ([]TrackRevision, error) {
+	rows, err := s.db.Query(`
+		SELECT track_id, revision, hash, author_id, author_name, message, created_at
+		FROM track_revisions WHERE track_id = ?
+		ORDER BY revision DESC
+	`, trackID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []TrackRevision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *rev)
+	}
+	return revisions, rows.Err()
+}
+
+// GetTrackRevision returns one revision of a track and its content.
+// revision 0 means the latest. It returns sql.ErrNoRows if there's no
+// such revision.
+func (s *Store) GetTrackRevision(trackID string, revision int) (*TrackRevision, *core.TrackProject, error) {
+	query := `
+		SELECT track_id, revision, hash, author_id, author_name, message, created_at
+		FROM track_revisions WHERE track_id = ? AND revision = ?
+	`
+	args := []interface{}{trackID, revision}
+	if revision == 0 {
+		query = `
+		SELECT track_id, revision, hash, author_id, author_name, message, created_at
+		FROM track_revisions WHERE track_id = ?
+		ORDER BY revision DESC LIMIT 1
+		`
+		args = args[:1]
+	}
+	rev, err := scanRevision(s.db.QueryRow(query, args...))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, _, err := s.blobs.Get(revisionKey(rev.Hash))
+	if err != nil {
+		return nil, nil, fmt.Errorf("revision %d of %s: %w", rev.Revision, trackID, err)
+	}
+	var project core.TrackProject
+	if err := json.Unmarshal(data, &project); err != nil {
+		return nil, nil, err
+	}
+	project.UseCatalog(s.lab.Catalog)
+	return rev, &project, nil
+}
+
+// RollbackTrack makes an old revision current again by saving its content
+// as a new revision, so the history in between is kept
+func (s *Store) RollbackTrack(trackID string, revision int, info RevisionInfo) (*TrackRevision, error) {
+	_, project, err := s.GetTrackRevision(trackID, revision)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.GetTrack(trackID)
+	if err != nil {
+		return nil, err
+	}
+
+	project.ID = current.ID
+	project.CreatedAt = current.CreatedAt
+	project.UpdatedAt = time.Now()
+	if info.Message == "" {
+		info.Message = fmt.Sprintf("Roll back to revision %d", revision)
+	}
+	return s.SaveTrack(project, info)
+}
+
+// deleteRevisions forgets a track's history, removing blobs no other
+// revision shares
+func (s *Store) deleteRevisions(trackID string) error {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT hash FROM track_revisions
+		WHERE track_id = ? AND hash NOT IN (SELECT hash FROM track_revisions WHERE track_id != ?)
+	`, trackID, trackID)
+	if err != nil {
+		return err
+	}
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err == nil {
+			hashes = append(hashes, hash)
+		}
+	}
+	rows.Close()
+
+	if _, err := s.db.Exec("DELETE FROM track_revisions WHERE track_id = ?", trackID); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		s.blobs.Delete(revisionKey(hash))
+	}
+	return nil
+}
+
+// backfillRevisions records the current file of tracks saved before
+// revisions existed as their first revision
+func (s *Store) backfillRevisions() error {
+	rows, err := s.db.Query(`
+		SELECT id, uploader_id, uploader_name FROM tracks
+		WHERE id NOT IN (SELECT DISTINCT track_id FROM track_revisions)
+	`)
+	if err != nil {
+		return err
+	}
+	type pending struct{ id, authorID, authorName string }
+	var tracks []pending
+	for rows.Next() {
+		var t pending
+		var authorID, authorName sql.NullString
+		if err := rows.Scan(&t.id, &authorID, &authorName); err == nil {
+			t.authorID, t.authorName = authorID.String, authorName.String
+			tracks = append(tracks, t)
+		}
+	}
+	rows.Close()
+
+	for _, t := range tracks {
+		data, _, err := s.blobs.Get(trackKey(t.id))
+		if err != nil {
+			continue
+		}
+		info := RevisionInfo{AuthorID: t.authorID, AuthorName: t.authorName, Message: "Initial revision"}
+		if _, err := s.recordRevision(t.id, data, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"testing"
+)
+
+func TestRevisions(t *testing.T) {
+	s := newTestStore(t)
+
+	first, err := s.SaveTrack(straightTrack("t1", 50), RevisionInfo{AuthorName: "ann", Message: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Revision != 1 {
+		t.Errorf("first revision = %d", first.Revision)
+	}
+
+	// Saving the same content again doesn't add a revision
+	same, err := s.SaveTrack(straightTrack("t1", 50), RevisionInfo{Message: "again"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.Revision != 1 || same.Message != "first" {
+		t.Errorf("identical save = %+v, want revision 1", same)
+	}
+
+	second, err := s.SaveTrack(straightTrack("t1", 80), RevisionInfo{AuthorName: "bob", Message: "longer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Revision != 2 || second.Hash == first.Hash {
+		t.Errorf("second revision = %+v", second)
+	}
+
+	revisions, err := s.ListTrackRevisions("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 || revisions[0].Revision != 2 || revisions[1].AuthorName != "ann" {
+		t.Errorf("ListTrackRevisions = %+v", revisions)
+	}
+
+	rev, project, err := s.GetTrackRevision("t1", 1)
+	if err != nil || rev.Revision != 1 || project.Pieces[0].Params.Length != 50 {
+		t.Errorf("GetTrackRevision(1) = %+v, %+v, %v", rev, project, err)
+	}
+	if rev, _, err := s.GetTrackRevision("t1", 0); err != nil || rev.Revision != 2 {
+		t.Errorf("GetTrackRevision(latest) = %+v, %v", rev, err)
+	}
+	if _, _, err := s.GetTrackRevision("t1", 9); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetTrackRevision(9) error = %v, want sql.ErrNoRows", err)
+	}
+
+	// Rolling back adds a revision with the old content
+	back, err := s.RollbackTrack("t1", 1, RevisionInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.Revision != 3 || back.Message != "Roll back to revision 1" {
+		t.Errorf("rollback = %+v", back)
+	}
+	if current, err := s.GetTrack("t1"); err != nil || current.Pieces[0].Params.Length != 50 {
+		t.Errorf("track after rollback = %+v, %v", current, err)
+	}
+
+	// Deleting forgets the history and its blobs
+	if err := s.DeleteTrack("t1"); err != nil {
+		t.Fatal(err)
+	}
+	if revisions, err := s.ListTrackRevisions("t1"); err != nil || len(revisions) != 0 {
+		t.Errorf("revisions after delete = %+v, %v", revisions, err)
+	}
+	for _, hash := range []string{first.Hash, second.Hash} {
+		if _, err := s.blobs.Stat(revisionKey(hash)); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("revision blob %s left after delete: %v", hash[:8], err)
+		}
+	}
+}
+
+func TestRevisions_SharedBlobKept(t *testing.T) {
+	s := newTestStore(t)
+
+	rev, err := s.SaveTrack(straightTrack("t1", 50), RevisionInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another track with the same content shares the blob
+	data, _, err := s.blobs.Get(revisionKey(rev.Hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.recordRevision("t2", data, RevisionInfo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteTrack("t1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.blobs.Stat(revisionKey(rev.Hash)); err != nil {
+		t.Errorf("blob t2 still uses was deleted: %v", err)
+	}
+}
+
+func TestRevisions_ConcurrentSaves(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.SaveTrack(straightTrack("t1", 10), RevisionInfo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.recordRevision("t1", []byte(fmt.Sprintf(`{"id":"t1","n":%d}`, i)), RevisionInfo{})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	revisions, err := s.ListTrackRevisions("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != n+1 {
+		t.Fatalf("%d revisions, want %d", len(revisions), n+1)
+	}
+	for i, rev := range revisions {
+		if want := n + 1 - i; rev.Revision != want {
+			t.Errorf("revision %d numbered %d, want %d", i, rev.Revision, want)
+		}
+	}
+}
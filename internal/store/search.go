@@ -0,0 +1,221 @@
+package store
+
+import (
+	"database/sql"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/asc-lab/track-designer/internal/core"
+)
+
+// Full-text search runs on an FTS5 table on SQLite. FTS5's tokenizers
+// keep a run of Chinese characters as one token, so 环岛 wouldn't match
+// 环岛赛道. The indexed text is tokenised here instead: CJK runs become
+// their single characters and overlapping bigrams, and queries are
+// matched bigram by bigram. PostgreSQL falls back to ILIKE.
+
+// execer is a DB or a Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// searchRank orders tracks_fts matches, best first. A match in the name
+// counts most, then tags, uploader and description.
+const searchRank = "bm25(tracks_fts, 0, 10.0, 2.0, 5.0, 3.0)"
+
+// snippetRunes is about how much text a snippet shows around the first match
+const snippetRunes = 80
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// searchSegments splits text into lower-case words and runs of CJK
+// characters, dropping punctuation and spaces
+func searchSegments(text string) []string {
+	var segments []string
+	var cur []rune
+	curCJK := false
+	flush := func() {
+		if len(cur) > 0 {
+			segments = append(segments, string(cur))
+			cur = cur[:0]
+		}
+	}
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			if !curCJK {
+				flush()
+			}
+			curCJK = true
+			cur = append(cur, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if curCJK {
+				flush()
+			}
+			curCJK = false
+			cur = append(cur, unicode.ToLower(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+	return segments
+}
+
+// searchIndexText is text as it goes into tracks_fts: words as they are,
+// CJK runs as characters and bigrams
+func searchIndexText(text string) string {
+	var tokens []string
+	for _, seg := range searchSegments(text) {
+		runes := []rune(seg)
+		if !isCJK(runes[0]) {
+			tokens = append(tokens, seg)
+			continue
+		}
+		for i := range runes {
+			tokens = append(tokens, string(runes[i]))
+			if i+1 < len(runes) {
+				tokens = append(tokens, string(runes[i:i+2]))
+			}
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+// searchTerms are what a query matches on, all of which must match:
+// words (as prefixes) and the bigrams of CJK runs, or the character of a
+// one-character run
+func searchTerms(query string) []string {
+	var terms []string
+	for _, seg := range searchSegments(query) {
+		runes := []rune(seg)
+		if !isCJK(runes[0]) || len(runes) == 1 {
+			terms = append(terms, seg)
+			continue
+		}
+		for i := 0; i+1 < len(runes); i++ {
+			terms = append(terms, string(runes[i:i+2]))
+		}
+	}
+	return terms
+}
+
+// searchMatchQuery turns a user's query into an FTS5 MATCH expression.
+// Empty if the query has nothing to search for.
+func searchMatchQuery(query string) string {
+	terms := searchTerms(query)
+	for i, term := range terms {
+		terms[i] = `"` + term + `"`
+		if !isCJK([]rune(term)[0]) {
+			terms[i] += "*"
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// indexTrack replaces a track's row in the search index
+func indexTrack(db execer, id, name, description string, tags []string, uploader string) error {
+	if _, err := db.Exec("DELETE FROM tracks_fts WHERE track_id = ?", id); err != nil {
+		return err
+	}
+	_, err := db.Exec(
+		"INSERT INTO tracks_fts (track_id, name, description, tags, uploader) VALUES (?, ?, ?, ?, ?)",
+		id, searchIndexText(name), searchIndexText(description),
+		searchIndexText(strings.Join(tags, " ")), searchIndexText(uploader))
+	return err
+}
+
+// searchSnippet is the text that best shows why a track matched, HTML
+// escaped with the matches in <mark>: the description if it matches,
+// else the tags, uploader or name. Empty if nothing matches.
+func searchSnippet(track *core.TrackMetadata, query string) string {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return ""
+	}
+	for _, text := range []string{
+		track.Description,
+		strings.Join(track.Tags, ", "),
+		track.UploaderName,
+		track.Name,
+	} {
+		if s := highlight(text, terms); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// highlight marks every occurrence of terms in text, trimmed to about
+// snippetRunes around the first one. Empty if no term occurs.
+func highlight(text string, terms []string) string {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		t := []rune(term)
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) != term {
+				continue
+			}
+			for j := i; j < i+len(t); j++ {
+				marked[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	start, end := 0, len(runes)
+	if len(runes) > snippetRunes {
+		start = first - snippetRunes/4
+		if start < 0 {
+			start = 0
+		}
+		end = start + snippetRunes
+		if end > len(runes) {
+			end = len(runes)
+			start = end - snippetRunes
+		}
+		// Don't start mid-word
+		for i := start; start > 0 && i < first; i++ {
+			if unicode.IsSpace(runes[i]) {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && marked[j] == marked[i] {
+			j++
+		}
+		part := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			part = "<mark>" + part + "</mark>"
+		}
+		b.WriteString(part)
+		i = j
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/asc-lab/track-designer/internal/core"
+)
+
+func TestSearchIndexText(t *testing.T) {
+	tests := []struct{ text, want string }{
+		{"Oval Track", "oval track"},
+		{"环岛赛道", "环 环岛 岛 岛赛 赛 赛道 道"},
+		{"Lab环岛, v2!", "lab 环 环岛 岛 v2"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := searchIndexText(tt.text); got != tt.want {
+			t.Errorf("searchIndexText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSearchMatchQuery(t *testing.T) {
+	tests := []struct{ query, want string }{
+		{"oval", `"oval"*`},
+		{"Figure 8", `"figure"* "8"*`},
+		{"环岛赛", `"环岛" "岛赛"`},
+		{"岛", `"岛"`},
+		{`"; DROP`, `"drop"*`},
+		{"  !? ", ""},
+	}
+	for _, tt := range tests {
+		if got := searchMatchQuery(tt.query); got != tt.want {
+			t.Errorf("searchMatchQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchSnippet(t *testing.T) {
+	track := &core.TrackMetadata{
+		Name:        "Lab Oval",
+		Description: "一条带<环岛>的赛道",
+		Tags:        []string{"beginner", "oval"},
+	}
+	if got, want := searchSnippet(track, "环岛"), "一条带&lt;<mark>环岛</mark>&gt;的赛道"; got != want {
+		t.Errorf("snippet = %q, want %q", got, want)
+	}
+	if got, want := searchSnippet(track, "OVAL"), "beginner, <mark>oval</mark>"; got != want {
+		t.Errorf("snippet = %q, want %q", got, want)
+	}
+	if got := searchSnippet(track, "nothing"); got != "" {
+		t.Errorf("snippet for no match = %q", got)
+	}
+
+	long := &core.TrackMetadata{Description: strings.Repeat("straight ", 30) + "hairpin at the end"}
+	got := searchSnippet(long, "hairpin")
+	if !strings.HasPrefix(got, "…straight") || !strings.HasSuffix(got, "<mark>hairpin</mark> at the end") {
+		t.Errorf("long snippet not trimmed around the match: %q", got)
+	}
+}
+
+func TestStore_Search(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for _, p := range []*core.TrackProject{
+		{ID: "t1", Name: "Lab Oval", Description: "Warm-up loop", Tags: []string{"beginner"}},
+		{ID: "t2", Name: "Figure Eight", Description: "Crosses an oval bridge"},
+		{ID: "t3", Name: "环岛赛道", Description: "带环岛的长赛道", UploaderName: "小明"},
+	} {
+		p.Pieces = []core.Piece{{ID: 1, Type: "straight", Params: core.PieceParams{Length: 50}}}
+		if _, err := s.SaveTrack(p, RevisionInfo{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func(query string) []string {
+		t.Helper()
+		tracks, total, err := s.ListTracks(1, 10, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != len(tracks) {
+			t.Errorf("%q: total %d for %d tracks", query, total, len(tracks))
+		}
+		got := []string{}
+		for _, track := range tracks {
+			if track.Snippet == "" {
+				t.Errorf("%q: %s has no snippet", query, track.ID)
+			}
+			got = append(got, track.ID)
+		}
+		return got
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"oval", []string{"t1", "t2"}}, // name match ranks first
+		{"ova", []string{"t1", "t2"}},
+		{"环岛", []string{"t3"}},
+		{"岛", []string{"t3"}},
+		{"小明", []string{"t3"}},
+		{"beginner", []string{"t1"}},
+		{"oval bridge", []string{"t2"}},
+		{"hairpin", []string{}},
+		{"!!", []string{}},
+	}
+	for _, tt := range tests {
+		if got := ids(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ListTracks(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	// Re-saving reindexes, deleting unindexes
+	p, _ := s.GetTrack("t1")
+	p.Name = "Lab Loop"
+	if _, err := s.SaveTrack(p, RevisionInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids("oval"); !reflect.DeepEqual(got, []string{"t2"}) {
+		t.Errorf("after rename: %v", got)
+	}
+	if err := s.DeleteTrack("t3"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids("环岛"); len(got) != 0 {
+		t.Errorf("after delete: %v", got)
+	}
+
+	// Another order still filters by the query
+	tracks, _, err := s.ListTracksWithFilters(1, 10, TrackFilters{Query: "loop", Sort: SortNewest})
+	if err != nil || len(tracks) != 1 || tracks[0].ID != "t1" {
+		t.Errorf("sorted search = %+v, %v", tracks, err)
+	}
+}
@@ -1,40 +1,67 @@
 package store
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/asc-lab/track-designer/internal/core"
-	_ "modernc.org/sqlite"
 )
 
 type Store struct {
-	db      *sql.DB
+	db      *DB
 	dataDir string
+	blobs   BlobStore // track JSON, thumbnails and revisions
+	lab     *core.Lab // catalogue, rules and prices from dataDir
 }
 
+// Config says where a Store keeps its data. Only DataDir is required.
+type Config struct {
+	// Lab config files (catalog.json, rules/, prices.json), the SQLite
+	// database and, by default, the blobs
+	DataDir string
+
+	Driver       string // DriverSQLite (default) or DriverPostgres
+	DatabaseURL  string // PostgreSQL connection string
+	MaxOpenConns int    // PostgreSQL pool size, default 20
+
+	Blobs BlobStore // default: files under DataDir
+}
+
+// New opens the store with everything under dataDir
 func New(dataDir string) (*Store, error) {
-	dbPath := filepath.Join(dataDir, "tracks.db")
-	// 添加SQLite连接参数以支持并发和WAL模式
-	db, err := sql.Open("sqlite", dbPath+"?_busy_timeout=5000&_journal_mode=WAL")
+	return NewFromConfig(Config{DataDir: dataDir})
+}
+
+// NewWithBlobs is New with track files and thumbnails kept in blobs, e.g.
+// an S3 bucket on hosts whose disk doesn't survive a redeploy. The
+// database and lab config files stay in dataDir.
+func NewWithBlobs(dataDir string, blobs BlobStore) (*Store, error) {
+	return NewFromConfig(Config{DataDir: dataDir, Blobs: blobs})
+}
+
+// NewFromConfig opens the store, brings its schema up to date and loads
+// the lab config files
+func NewFromConfig(cfg Config) (*Store, error) {
+	store, err := Open(cfg)
 	if err != nil {
 		return nil, err
 	}
-
-	// 设置连接池参数以减少并发冲突
-	db.SetMaxOpenConns(1)  // SQLite建议使用单连接
-	db.SetMaxIdleConns(1)
-	db.SetConnMaxLifetime(0)
-
-	store := &Store{
-		db:      db,
-		dataDir: dataDir,
+	lab, err := core.LoadLab(cfg.DataDir)
+	if err != nil {
+		return nil, err
 	}
+	store.lab = lab
 
+	// After the catalogue, which the backfills' BOMs depend on
 	if err := store.init(); err != nil {
 		return nil, err
 	}
@@ -42,76 +69,136 @@ func New(dataDir string) (*Store, error) {
 	return store, nil
 }
 
+// Open connects to the database without touching its schema, for tools
+// like `trackd migrate` that inspect it first. Use NewFromConfig to serve
+// from it.
+func Open(cfg Config) (*Store, error) {
+	db, err := openDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs := cfg.Blobs
+	if blobs == nil {
+		blobs = NewLocalBlobStore(cfg.DataDir)
+	}
+	return &Store{
+		db:      db,
+		dataDir: cfg.DataDir,
+		blobs:   blobs,
+		lab:     core.DefaultLab(),
+	}, nil
+}
+
+// Lab is the catalogue, rule profiles and price list the store's tracks
+// are built from
+func (s *Store) Lab() *core.Lab {
+	return s.lab
+}
+
+// init brings the schema up to date and fills derived columns for tracks
+// saved before they existed
 func (s *Store) init() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS tracks (
-		id TEXT PRIMARY KEY,
-		name TEXT NOT NULL,
-		description TEXT DEFAULT '',
-		tags TEXT DEFAULT '[]',
-		uploader_id TEXT,
-		uploader_name TEXT DEFAULT '',
-		uploader_avatar TEXT DEFAULT '',
-		created_at DATETIME NOT NULL,
-		total_pieces INTEGER DEFAULT 0,
-		total_length TEXT DEFAULT '0.00',
-		total_length_cm INTEGER DEFAULT 0,
-		thumbnail TEXT DEFAULT '',
-		likes INTEGER DEFAULT 0,
-		downloads INTEGER DEFAULT 0
-	);
-	CREATE INDEX IF NOT EXISTS idx_tracks_created ON tracks(created_at DESC);
-	CREATE INDEX IF NOT EXISTS idx_tracks_length ON tracks(total_length_cm);
-	CREATE INDEX IF NOT EXISTS idx_tracks_likes ON tracks(likes DESC);
-	CREATE INDEX IF NOT EXISTS idx_tracks_downloads ON tracks(downloads DESC);
-
-	CREATE TABLE IF NOT EXISTS users (
-		id TEXT PRIMARY KEY,
-		login TEXT NOT NULL UNIQUE,
-		name TEXT DEFAULT '',
-		email TEXT DEFAULT '',
-		avatar_url TEXT DEFAULT '',
-		created_at DATETIME NOT NULL,
-		updated_at DATETIME NOT NULL
-	);
-	CREATE INDEX IF NOT EXISTS idx_users_login ON users(login);
-
-	-- 用户点赞记录表(防止重复点赞)
-	CREATE TABLE IF NOT EXISTS track_likes (
-		track_id TEXT NOT NULL,
-		user_ip TEXT NOT NULL,
-		liked_at DATETIME NOT NULL,
-		PRIMARY KEY (track_id, user_ip)
-	);
-	CREATE INDEX IF NOT EXISTS idx_track_likes_track ON track_likes(track_id);
-	`
-	if _, err := s.db.Exec(schema); err != nil {
+	if _, err := s.Migrate(); err != nil {
 		return err
 	}
 
-	// Migrate existing tables: add new columns if they don't exist
-	// SQLite doesn't have ALTER TABLE IF NOT EXISTS, so we ignore errors for existing columns
-	s.db.Exec("ALTER TABLE tracks ADD COLUMN description TEXT DEFAULT ''")
-	s.db.Exec("ALTER TABLE tracks ADD COLUMN thumbnail TEXT DEFAULT ''")
-	s.db.Exec("ALTER TABLE tracks ADD COLUMN tags TEXT DEFAULT '[]'")
-	s.db.Exec("ALTER TABLE tracks ADD COLUMN total_length_cm INTEGER DEFAULT 0")
-	s.db.Exec("ALTER TABLE tracks ADD COLUMN uploader_name TEXT DEFAULT ''")
-	s.db.Exec("ALTER TABLE tracks ADD COLUMN uploader_avatar TEXT DEFAULT ''")
-	s.db.Exec("ALTER TABLE tracks ADD COLUMN likes INTEGER DEFAULT 0")
-	s.db.Exec("ALTER TABLE tracks ADD COLUMN downloads INTEGER DEFAULT 0")
+	if err := s.backfillDifficulty(); err != nil {
+		return err
+	}
+	if err := s.backfillTrackParts(); err != nil {
+		return err
+	}
+	return s.backfillRevisions()
+}
 
+// backfillDifficulty rates tracks saved before difficulty was computed.
+// Tracks that can't be rated (e.g. boundary only) stay NULL.
+func (s *Store) backfillDifficulty() error {
+	rows, err := s.db.Query("SELECT id FROM tracks WHERE difficulty IS NULL")
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		project, err := s.GetTrack(id)
+		if err != nil {
+			continue
+		}
+		if difficulty := trackDifficulty(project); difficulty != nil {
+			s.db.Exec("UPDATE tracks SET difficulty = ? WHERE id = ?", *difficulty, id)
+		}
+	}
 	return nil
 }
 
-func (s *Store) SaveTrack(project *core.TrackProject, thumbnail string) error {
+// backfillTrackParts fills track_parts for tracks saved before it existed
+func (s *Store) backfillTrackParts() error {
+	rows, err := s.db.Query(`
+		SELECT id FROM tracks
+		WHERE total_pieces > 0 AND id NOT IN (SELECT DISTINCT track_id FROM track_parts)
+	`)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		project, err := s.GetTrack(id)
+		if err != nil {
+			continue
+		}
+		if err := s.saveTrackParts(id, core.GenerateBOM(project).BOM); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trackDifficulty is the stored difficulty score, nil if the track can't
+// be rated
+func trackDifficulty(project *core.TrackProject) *float64 {
+	d, err := core.ComputeDifficulty(project)
+	if err != nil {
+		return nil
+	}
+	return &d.Score
+}
+
+// SaveTrack saves a track and records it as a new revision. Saving the
+// same content as the latest revision returns that revision.
+func (s *Store) SaveTrack(project *core.TrackProject, info RevisionInfo) (*TrackRevision, error) {
+	project.UseCatalog(s.lab.Catalog)
+
 	// Save JSON file
-	trackPath := filepath.Join(s.dataDir, "tracks", project.ID+".json")
 	data, err := json.MarshalIndent(project, "", "  ")
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if err := os.WriteFile(trackPath, data, 0644); err != nil {
-		return err
+	if err := s.blobs.Put(trackKey(project.ID), data, "application/json"); err != nil {
+		return nil, err
+	}
+
+	// Re-render the thumbnail for the new geometry. Tracks that can't be
+	// drawn (e.g. unknown piece types) are still saved, without one.
+	if err := s.renderThumbnail(project); err != nil {
+		slog.Warn("track saved without thumbnail", "track_id", project.ID, "error", err)
+		s.blobs.Delete(thumbnailKey(project.ID))
 	}
 
 	// Calculate stats
@@ -119,8 +206,8 @@ func (s *Store) SaveTrack(project *core.TrackProject, thumbnail string) error {
 
 	// Convert total length to cm (stored as string "12.34" meters)
 	totalLengthCm := 0
-	if _, err := fmt.Sscanf(bom.TotalLength, "%d", &totalLengthCm); err == nil {
-		totalLengthCm = int(totalLengthCm * 100) // convert meters to cm
+	if meters, err := strconv.ParseFloat(bom.TotalLength, 64); err == nil {
+		totalLengthCm = int(math.Round(meters * 100))
 	}
 
 	// Serialize tags to JSON
@@ -135,104 +222,123 @@ func (s *Store) SaveTrack(project *core.TrackProject, thumbnail string) error {
 		description = project.Description
 	}
 
-	// Save metadata
+	// Save metadata, keeping likes and downloads when a track is re-saved
 	_, err = s.db.Exec(`
-		INSERT OR REPLACE INTO tracks (
+		INSERT INTO tracks (
 			id, name, description, tags,
 			uploader_id, uploader_name, uploader_avatar,
-			created_at, total_pieces, total_length, total_length_cm, thumbnail, likes, downloads
+			created_at, total_pieces, total_length, total_length_cm, difficulty
 		)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			description = excluded.description,
+			tags = excluded.tags,
+			uploader_id = excluded.uploader_id,
+			uploader_name = excluded.uploader_name,
+			uploader_avatar = excluded.uploader_avatar,
+			total_pieces = excluded.total_pieces,
+			total_length = excluded.total_length,
+			total_length_cm = excluded.total_length_cm,
+			difficulty = excluded.difficulty
 	`, project.ID, project.Name, description, string(tagsJSON),
 		project.UploaderID, project.UploaderName, project.UploaderAvatar,
-		project.CreatedAt, bom.TotalPieces, bom.TotalLength, totalLengthCm, thumbnail)
-
-	return err
-}
-
-func (s *Store) GetTrack(id string) (*core.TrackProject, error) {
-	trackPath := filepath.Join(s.dataDir, "tracks", id+".json")
-	data, err := os.ReadFile(trackPath)
+		project.CreatedAt, bom.TotalPieces, bom.TotalLength, totalLengthCm, trackDifficulty(project))
 	if err != nil {
 		return nil, err
 	}
 
-	var project core.TrackProject
-	if err := json.Unmarshal(data, &project); err != nil {
+	if s.db.dialect.fts {
+		if err := indexTrack(s.db, project.ID, project.Name, description, project.Tags, project.UploaderName); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.saveTrackParts(project.ID, bom.BOM); err != nil {
 		return nil, err
 	}
 
-	return &project, nil
+	return s.recordRevision(project.ID, data, info)
 }
 
-func (s *Store) ListTracks(page, size int, query string) ([]core.TrackMetadata, int, error) {
-	offset := (page - 1) * size
-
-	// Count total
-	var total int
-	countSQL := "SELECT COUNT(*) FROM tracks"
-	whereClause := ""
-	args := []interface{}{}
+// saveTrackParts replaces the parts list of a track
+func (s *Store) saveTrackParts(trackID string, parts map[string]int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	if query != "" {
-		whereClause = " WHERE name LIKE ?"
-		args = append(args, "%"+query+"%")
-		if err := s.db.QueryRow(countSQL+whereClause, args...).Scan(&total); err != nil {
-			return nil, 0, err
-		}
-	} else {
-		if err := s.db.QueryRow(countSQL).Scan(&total); err != nil {
-			return nil, 0, err
+	if _, err := tx.Exec("DELETE FROM track_parts WHERE track_id = ?", trackID); err != nil {
+		return err
+	}
+	for code, n := range parts {
+		if _, err := tx.Exec("INSERT INTO track_parts (track_id, code, quantity) VALUES (?, ?, ?)", trackID, code, n); err != nil {
+			return err
 		}
 	}
+	return tx.Commit()
+}
 
-	// Get page
-	listSQL := `
-		SELECT id, name, description, tags,
-		       uploader_id, uploader_name, uploader_avatar,
-		       created_at, total_pieces, total_length, total_length_cm, thumbnail, likes, downloads
-		FROM tracks` + whereClause + `
-		ORDER BY created_at DESC
-		LIMIT ? OFFSET ?
-	`
-	args = append(args, size, offset)
+// Thumbnail returns the PNG thumbnail of a track, rendering it first if
+// it doesn't exist yet (e.g. for tracks uploaded before thumbnails were
+// rendered on the server)
+func (s *Store) Thumbnail(id string) ([]byte, BlobInfo, error) {
+	data, info, err := s.blobs.Get(thumbnailKey(id))
+	if err == nil {
+		return data, info, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, BlobInfo{}, err
+	}
 
-	rows, err := s.db.Query(listSQL, args...)
+	project, err := s.GetTrack(id)
 	if err != nil {
-		return nil, 0, err
+		return nil, BlobInfo{}, err
 	}
-	defer rows.Close()
+	if err := s.renderThumbnail(project); err != nil {
+		return nil, BlobInfo{}, err
+	}
+	return s.blobs.Get(thumbnailKey(id))
+}
 
-	tracks := []core.TrackMetadata{}
-	for rows.Next() {
-		var track core.TrackMetadata
-		var createdAt string
-		var tagsJSON string
-		err := rows.Scan(
-			&track.ID, &track.Name, &track.Description, &tagsJSON,
-			&track.UploaderID, &track.UploaderName, &track.UploaderAvatar,
-			&createdAt, &track.TotalPieces, &track.TotalLength, &track.TotalLengthCm, &track.Thumbnail,
-			&track.Likes, &track.Downloads)
-		if err != nil {
-			continue
-		}
-		track.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+func (s *Store) renderThumbnail(project *core.TrackProject) error {
+	var buf bytes.Buffer
+	if err := core.RenderThumbnail(&buf, project, core.ThumbnailWidth, core.ThumbnailHeight); err != nil {
+		return fmt.Errorf("render thumbnail: %w", err)
+	}
+	return s.blobs.Put(thumbnailKey(project.ID), buf.Bytes(), "image/png")
+}
 
-		// Parse tags JSON
-		if tagsJSON != "" {
-			json.Unmarshal([]byte(tagsJSON), &track.Tags)
-		}
+func (s *Store) GetTrack(id string) (*core.TrackProject, error) {
+	data, _, err := s.blobs.Get(trackKey(id))
+	if err != nil {
+		return nil, err
+	}
 
-		tracks = append(tracks, track)
+	var project core.TrackProject
+	if err := json.Unmarshal(data, &project); err != nil {
+		return nil, err
 	}
+	project.UseCatalog(s.lab.Catalog)
 
-	return tracks, total, nil
+	return &project, nil
+}
+
+// ListTracks is the newest tracks, or the best matches for query
+func (s *Store) ListTracks(page, size int, query string) ([]core.TrackMetadata, int, error) {
+	return s.ListTracksWithFilters(page, size, TrackFilters{Query: query})
 }
 
 func (s *Store) DeleteTrack(id string) error {
-	trackPath := filepath.Join(s.dataDir, "tracks", id+".json")
-	os.Remove(trackPath)
+	s.blobs.Delete(trackKey(id))
+	s.blobs.Delete(thumbnailKey(id))
 
+	s.db.Exec("DELETE FROM track_parts WHERE track_id = ?", id)
+	if s.db.dialect.fts {
+		s.db.Exec("DELETE FROM tracks_fts WHERE track_id = ?", id)
+	}
+	s.deleteRevisions(id)
 	_, err := s.db.Exec("DELETE FROM tracks WHERE id = ?", id)
 	return err
 }
@@ -241,38 +347,122 @@ func (s *Store) Close() error {
 	return s.db.Close()
 }
 
-// ListTracksWithFilters searches tracks with tag and length filters
-func (s *Store) ListTracksWithFilters(page, size int, query string, tags []string, minLength, maxLength int) ([]core.TrackMetadata, int, error) {
+// Sort orders for ListTracksWithFilters
+const (
+	SortNewest         = "newest"    // default without a query
+	SortRelevance      = "relevance" // default with a query
+	SortDifficulty     = "difficulty"
+	SortDifficultyDesc = "-difficulty"
+)
+
+var trackOrderBy = map[string]string{
+	"":                 "created_at DESC",
+	SortNewest:         "created_at DESC",
+	SortRelevance:      "created_at DESC", // ranked by the search when there's a query
+	SortDifficulty:     "difficulty IS NULL, difficulty ASC, created_at DESC",
+	SortDifficultyDesc: "difficulty IS NULL, difficulty DESC, created_at DESC",
+}
+
+// TrackFilters narrows and orders ListTracksWithFilters. Zero values don't
+// filter.
+type TrackFilters struct {
+	Query         string   // words in the name, description, tags or uploader
+	Tags          []string // any of
+	MinLength     int      // cm
+	MaxLength     int
+	MinDifficulty float64 // 0..100; tracks without a score are left out
+	MaxDifficulty float64
+	BuildableFor  string // user ID: only tracks their inventory covers
+	Sort          string // SortNewest (also for unknown values), SortRelevance or a difficulty order
+}
+
+// ListTracksWithFilters searches tracks with tag, length and difficulty
+// filters. With a query, results carry a highlighted snippet and are
+// ranked by relevance unless another order is asked for.
+func (s *Store) ListTracksWithFilters(page, size int, f TrackFilters) ([]core.TrackMetadata, int, error) {
 	offset := (page - 1) * size
 
+	query := strings.TrimSpace(f.Query)
+	sort := f.Sort
+	if sort == "" && query != "" {
+		sort = SortRelevance
+	}
+	orderBy, ok := trackOrderBy[sort]
+	if !ok {
+		orderBy = trackOrderBy[SortNewest]
+	}
+
 	// Build WHERE clause
+	from := "tracks"
 	whereConditions := []string{}
 	args := []interface{}{}
+	orderArgs := []interface{}{}
 
-	if query != "" {
-		whereConditions = append(whereConditions, "name LIKE ?")
-		args = append(args, "%"+query+"%")
+	if query != "" && s.db.dialect.fts {
+		match := searchMatchQuery(query)
+		if match == "" {
+			return []core.TrackMetadata{}, 0, nil
+		}
+		from = `tracks JOIN (
+			SELECT track_id, ` + searchRank + ` AS score FROM tracks_fts WHERE tracks_fts MATCH ?
+		) AS fts ON fts.track_id = tracks.id`
+		args = append(args, match)
+		if sort == SortRelevance {
+			orderBy = "fts.score, created_at DESC"
+		}
+	} else if query != "" {
+		// Every word in one of the fields, name matches first
+		for _, word := range strings.Fields(query) {
+			like := "%" + word + "%"
+			whereConditions = append(whereConditions, fmt.Sprintf(
+				"(name %[1]s ? OR description %[1]s ? OR tags %[1]s ? OR uploader_name %[1]s ?)", s.db.dialect.ilike))
+			args = append(args, like, like, like, like)
+		}
+		if sort == SortRelevance {
+			orderBy = "CASE WHEN name " + s.db.dialect.ilike + " ? THEN 0 ELSE 1 END, created_at DESC"
+			orderArgs = append(orderArgs, "%"+query+"%")
+		}
 	}
 
 	// Tag filtering: check if tags JSON contains any of the requested tags
 	// SQLite has limited JSON support, so we use simple string matching
-	if len(tags) > 0 {
+	if len(f.Tags) > 0 {
 		tagConditions := []string{}
-		for _, tag := range tags {
-			tagConditions = append(tagConditions, "tags LIKE ?")
+		for _, tag := range f.Tags {
+			tagConditions = append(tagConditions, "tags "+s.db.dialect.ilike+" ?")
 			args = append(args, "%\""+tag+"\"%")
 		}
 		whereConditions = append(whereConditions, "("+joinStrings(tagConditions, " OR ")+")")
 	}
 
 	// Length filtering (in cm)
-	if minLength > 0 {
+	if f.MinLength > 0 {
 		whereConditions = append(whereConditions, "total_length_cm >= ?")
-		args = append(args, minLength)
+		args = append(args, f.MinLength)
 	}
-	if maxLength > 0 {
+	if f.MaxLength > 0 {
 		whereConditions = append(whereConditions, "total_length_cm <= ?")
-		args = append(args, maxLength)
+		args = append(args, f.MaxLength)
+	}
+
+	// Difficulty filtering (score 0..100)
+	if f.MinDifficulty > 0 {
+		whereConditions = append(whereConditions, "difficulty >= ?")
+		args = append(args, f.MinDifficulty)
+	}
+	if f.MaxDifficulty > 0 {
+		whereConditions = append(whereConditions, "difficulty <= ?")
+		args = append(args, f.MaxDifficulty)
+	}
+
+	// Buildable: has pieces, and no part needs more than the user owns
+	if f.BuildableFor != "" {
+		whereConditions = append(whereConditions, `total_pieces > 0 AND NOT EXISTS (
+			SELECT 1 FROM track_parts p
+			LEFT JOIN inventory_items i ON i.user_id = ? AND i.code = p.code
+			WHERE p.track_id = tracks.id AND COALESCE(i.quantity, 0) < p.quantity
+		)`)
+		args = append(args, f.BuildableFor)
 	}
 
 	whereClause := ""
@@ -282,7 +472,7 @@ func (s *Store) ListTracksWithFilters(page, size int, query string, tags []strin
 
 	// Count total
 	var total int
-	countSQL := "SELECT COUNT(*) FROM tracks" + whereClause
+	countSQL := "SELECT COUNT(*) FROM " + from + whereClause
 	if err := s.db.QueryRow(countSQL, args...).Scan(&total); err != nil {
 		return nil, 0, err
 	}
@@ -291,11 +481,12 @@ func (s *Store) ListTracksWithFilters(page, size int, query string, tags []strin
 	listSQL := `
 		SELECT id, name, description, tags,
 		       uploader_id, uploader_name, uploader_avatar,
-		       created_at, total_pieces, total_length, total_length_cm, thumbnail, likes, downloads
-		FROM tracks` + whereClause + `
-		ORDER BY created_at DESC
+		       created_at, total_pieces, total_length, total_length_cm, thumbnail, likes, downloads, difficulty
+		FROM ` + from + whereClause + `
+		ORDER BY ` + orderBy + `
 		LIMIT ? OFFSET ?
 	`
+	args = append(args, orderArgs...)
 	args = append(args, size, offset)
 
 	rows, err := s.db.Query(listSQL, args...)
@@ -309,20 +500,27 @@ func (s *Store) ListTracksWithFilters(page, size int, query string, tags []strin
 		var track core.TrackMetadata
 		var createdAt string
 		var tagsJSON string
+		var difficulty sql.NullFloat64
 		err := rows.Scan(
 			&track.ID, &track.Name, &track.Description, &tagsJSON,
 			&track.UploaderID, &track.UploaderName, &track.UploaderAvatar,
 			&createdAt, &track.TotalPieces, &track.TotalLength, &track.TotalLengthCm, &track.Thumbnail,
-			&track.Likes, &track.Downloads)
+			&track.Likes, &track.Downloads, &difficulty)
 		if err != nil {
 			continue
 		}
 		track.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		if difficulty.Valid {
+			track.Difficulty = &difficulty.Float64
+		}
 
 		// Parse tags JSON
 		if tagsJSON != "" {
 			json.Unmarshal([]byte(tagsJSON), &track.Tags)
 		}
+		if query != "" {
+			track.Snippet = searchSnippet(&track, query)
+		}
 
 		tracks = append(tracks, track)
 	}
@@ -516,3 +714,154 @@ func (s *Store) GetLikeStatus(trackID, userIP string) (bool, error) {
 	`, trackID, userIP).Scan(&exists)
 	return exists, err
 }
+
+// Vehicle profiles: each user's saved car models for lap-time estimates
+
+// VehicleProfile is a named vehicle model owned by a user
+type VehicleProfile struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+	Name   string `json:"name"`
+	core.VehicleModel
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// SaveVehicleProfile inserts or updates a profile. Updating someone else's
+// profile fails with sql.ErrNoRows.
+func (s *Store) SaveVehicleProfile(p *VehicleProfile) error {
+	res, err := s.db.Exec(`
+		INSERT INTO vehicle_profiles (id, user_id, name, max_speed, max_lateral_accel, max_accel, max_brake, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			max_speed = excluded.max_speed,
+			max_lateral_accel = excluded.max_lateral_accel,
+			max_accel = excluded.max_accel,
+			max_brake = excluded.max_brake,
+			updated_at = excluded.updated_at
+		WHERE vehicle_profiles.user_id = excluded.user_id
+	`, p.ID, p.UserID, p.Name, p.MaxSpeed, p.MaxLateralAccel, p.MaxAccel, p.MaxBrake,
+		p.CreatedAt.Format(time.RFC3339), p.UpdatedAt.Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListVehicleProfiles returns a user's profiles by name
+func (s *Store) ListVehicleProfiles(userID string) ([]VehicleProfile, error) {
+	rows, err := s.db.Query(`
+		SELECT id, user_id, name, max_speed, max_lateral_accel, max_accel, max_brake, created_at, updated_at
+		FROM vehicle_profiles
+		WHERE user_id = ?
+		ORDER BY name, created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []VehicleProfile{}
+	for rows.Next() {
+		p, err := scanVehicleProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, *p)
+	}
+	return profiles, rows.Err()
+}
+
+// GetVehicleProfile retrieves one of a user's profiles
+func (s *Store) GetVehicleProfile(userID, id string) (*VehicleProfile, error) {
+	return scanVehicleProfile(s.db.QueryRow(`
+		SELECT id, user_id, name, max_speed, max_lateral_accel, max_accel, max_brake, created_at, updated_at
+		FROM vehicle_profiles
+		WHERE id = ? AND user_id = ?
+	`, id, userID))
+}
+
+// DeleteVehicleProfile removes one of a user's profiles
+func (s *Store) DeleteVehicleProfile(userID, id string) error {
+	res, err := s.db.Exec(`DELETE FROM vehicle_profiles WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanVehicleProfile(row interface{ Scan(...interface{}) error }) (*VehicleProfile, error) {
+	var p VehicleProfile
+	var createdAt, updatedAt string
+	err := row.Scan(&p.ID, &p.UserID, &p.Name, &p.MaxSpeed, &p.MaxLateralAccel, &p.MaxAccel, &p.MaxBrake, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	p.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	return &p, nil
+}
+
+// Inventory: the parts each user owns
+
+// GetInventory returns a user's parts, empty if they haven't registered any
+func (s *Store) GetInventory(userID string) (core.Inventory, error) {
+	rows, err := s.db.Query("SELECT code, quantity FROM inventory_items WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inv := core.Inventory{}
+	for rows.Next() {
+		var code string
+		var n int
+		if err := rows.Scan(&code, &n); err != nil {
+			return nil, err
+		}
+		inv[code] = n
+	}
+	return inv, rows.Err()
+}
+
+// SetInventory replaces a user's whole inventory
+func (s *Store) SetInventory(userID string, inv core.Inventory) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM inventory_items WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	for code, n := range inv {
+		if n <= 0 {
+			continue
+		}
+		if _, err := tx.Exec("INSERT INTO inventory_items (user_id, code, quantity) VALUES (?, ?, ?)", userID, code, n); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetInventoryItem sets how many of one part a user owns; zero removes it
+func (s *Store) SetInventoryItem(userID, code string, quantity int) error {
+	if quantity <= 0 {
+		_, err := s.db.Exec("DELETE FROM inventory_items WHERE user_id = ? AND code = ?", userID, code)
+		return err
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO inventory_items (user_id, code, quantity) VALUES (?, ?, ?)
+		ON CONFLICT(user_id, code) DO UPDATE SET quantity = excluded.quantity
+	`, userID, code, quantity)
+	return err
+}
@@ -0,0 +1,319 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/asc-lab/track-designer/internal/core"
+)
+
+// newTestStore opens a store in a temporary data directory
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// straightTrack is a track of one straight of the given length (cm)
+func straightTrack(id string, length float64) *core.TrackProject {
+	return &core.TrackProject{
+		ID:   id,
+		Name: id,
+		Pieces: []core.Piece{
+			{ID: 1, Type: "straight", Params: core.PieceParams{Length: length}},
+		},
+	}
+}
+
+func TestSaveTrack_UnknownPieceType(t *testing.T) {
+	s := newTestStore(t)
+
+	project := &core.TrackProject{
+		ID:   "odd",
+		Name: "Odd",
+		Pieces: []core.Piece{
+			{ID: 1, Type: "hovercraft-ramp", Params: core.PieceParams{Length: 50}},
+		},
+	}
+	if _, err := s.SaveTrack(project, RevisionInfo{}); err != nil {
+		t.Fatalf("SaveTrack = %v, want a track saved without thumbnail", err)
+	}
+
+	if got, err := s.GetTrack("odd"); err != nil || got.Pieces[0].Type != "hovercraft-ramp" {
+		t.Errorf("GetTrack = %+v, %v", got, err)
+	}
+	if _, total, err := s.ListTracks(1, 10, ""); err != nil || total != 1 {
+		t.Errorf("ListTracks: total %d, %v", total, err)
+	}
+	if _, _, err := s.Thumbnail("odd"); err == nil {
+		t.Error("Thumbnail of an undrawable track succeeded")
+	}
+}
+
+func TestStore_LabPerStore(t *testing.T) {
+	dir := t.TempDir()
+	catalog := `{
+		"types": [{"type": "flex", "name": "软直道", "geometry": "straight", "codeFormat": "F{length}"}]
+	}`
+	if err := os.WriteFile(filepath.Join(dir, "catalog.json"), []byte(catalog), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lab, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lab.Close()
+	plain := newTestStore(t)
+
+	flex := &core.TrackProject{ID: "flex", Name: "flex", Pieces: []core.Piece{
+		{ID: 1, Type: "flex", Params: core.PieceParams{Length: 30}},
+	}}
+	if _, err := lab.SaveTrack(flex, RevisionInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := lab.GetTrack("flex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bom := lab.Lab().GenerateBOM(got); bom.BOM["F30"] != 1 || bom.TotalLength != "0.30" {
+		t.Errorf("BOM with the lab catalogue = %+v", bom)
+	}
+
+	// Opening the lab's store left the other store's catalogue alone
+	if _, ok := plain.Lab().Catalog.Type("flex"); ok {
+		t.Error("catalogue leaked into another store")
+	}
+	if _, ok := core.DefaultCatalog().Type("flex"); ok {
+		t.Error("catalogue leaked into the defaults")
+	}
+}
+
+func TestVehicleProfiles(t *testing.T) {
+	s := newTestStore(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	car := &VehicleProfile{
+		ID:           "v1",
+		UserID:       "u1",
+		Name:         "Our car",
+		VehicleModel: core.VehicleModel{MaxSpeed: 3, MaxLateralAccel: 8, MaxAccel: 4, MaxBrake: 6},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := s.SaveVehicleProfile(car); err != nil {
+		t.Fatal(err)
+	}
+	other := &VehicleProfile{ID: "v2", UserID: "u1", Name: "Another car", CreatedAt: now, UpdatedAt: now}
+	if err := s.SaveVehicleProfile(other); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetVehicleProfile("u1", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, car) {
+		t.Errorf("GetVehicleProfile = %+v, want %+v", got, car)
+	}
+
+	// Updating keeps the owner and creation time
+	car.Name = "Our tuned car"
+	car.MaxSpeed = 4
+	car.UpdatedAt = now.Add(time.Minute)
+	if err := s.SaveVehicleProfile(car); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetVehicleProfile("u1", "v1"); err != nil || got.MaxSpeed != 4 || !got.CreatedAt.Equal(now) {
+		t.Errorf("after update: %+v, %v", got, err)
+	}
+
+	profiles, err := s.ListVehicleProfiles("u1")
+	if err != nil || len(profiles) != 2 || profiles[0].ID != "v2" {
+		t.Errorf("ListVehicleProfiles = %+v, %v, want them by name", profiles, err)
+	}
+
+	// Someone else can't see, overwrite or delete them
+	if profiles, err := s.ListVehicleProfiles("u2"); err != nil || len(profiles) != 0 {
+		t.Errorf("other user's list = %+v, %v", profiles, err)
+	}
+	if _, err := s.GetVehicleProfile("u2", "v1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("other user's get: %v, want sql.ErrNoRows", err)
+	}
+	stolen := *car
+	stolen.UserID = "u2"
+	if err := s.SaveVehicleProfile(&stolen); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("other user's save: %v, want sql.ErrNoRows", err)
+	}
+	if err := s.DeleteVehicleProfile("u2", "v1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("other user's delete: %v, want sql.ErrNoRows", err)
+	}
+
+	if err := s.DeleteVehicleProfile("u1", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetVehicleProfile("u1", "v1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("get after delete: %v", err)
+	}
+	if err := s.DeleteVehicleProfile("u1", "v1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("second delete: %v, want sql.ErrNoRows", err)
+	}
+}
+
+// loopTrack is a circle of four quarter curves of the given radius (cm)
+func loopTrack(id string, radius float64) *core.TrackProject {
+	project := &core.TrackProject{ID: id, Name: id}
+	for i := 0; i < 4; i++ {
+		project.Pieces = append(project.Pieces, core.Piece{
+			ID: i + 1, Type: "curve", Params: core.PieceParams{Radius: radius, Angle: 90, Direction: "left"},
+		})
+	}
+	return project
+}
+
+// saveTracks saves projects oldest first, a minute apart
+func saveTracks(t *testing.T, s *Store, projects ...*core.TrackProject) {
+	t.Helper()
+	start := time.Now().Add(-time.Hour)
+	for i, p := range projects {
+		p.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		if _, err := s.SaveTrack(p, RevisionInfo{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// trackIDs lists the IDs ListTracksWithFilters returns, checking total
+func trackIDs(t *testing.T, s *Store, f TrackFilters) []string {
+	t.Helper()
+	tracks, total, err := s.ListTracksWithFilters(1, 10, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != len(tracks) {
+		t.Errorf("%+v: total %d for %d tracks", f, total, len(tracks))
+	}
+	ids := []string{}
+	for _, track := range tracks {
+		ids = append(ids, track.ID)
+	}
+	return ids
+}
+
+func TestListTracks_Difficulty(t *testing.T) {
+	s := newTestStore(t)
+	unrated := straightTrack("unrated", 50)
+	unrated.Pieces[0].Type = "hovercraft-ramp"
+	saveTracks(t, s,
+		loopTrack("tight", 30),     // 55
+		straightTrack("flat", 200), // 0
+		unrated,
+		loopTrack("wide", 100), // 27.8
+	)
+
+	tracks, _, err := s.ListTracksWithFilters(1, 10, TrackFilters{Sort: SortDifficulty})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, track := range tracks {
+		if (track.Difficulty == nil) != (track.ID == "unrated") {
+			t.Errorf("%s: difficulty %v", track.ID, track.Difficulty)
+		}
+	}
+
+	tests := []struct {
+		f    TrackFilters
+		want []string
+	}{
+		{TrackFilters{Sort: SortDifficulty}, []string{"flat", "wide", "tight", "unrated"}},
+		{TrackFilters{Sort: SortDifficultyDesc}, []string{"tight", "wide", "flat", "unrated"}},
+		{TrackFilters{}, []string{"wide", "unrated", "flat", "tight"}},
+		{TrackFilters{Sort: "likes; DROP TABLE tracks"}, []string{"wide", "unrated", "flat", "tight"}},
+		{TrackFilters{MinDifficulty: 20}, []string{"wide", "tight"}},
+		{TrackFilters{MaxDifficulty: 50, Sort: SortDifficultyDesc}, []string{"wide", "flat"}},
+		{TrackFilters{MinDifficulty: 20, MaxDifficulty: 50}, []string{"wide"}},
+		{TrackFilters{MinDifficulty: 90}, []string{}},
+	}
+	for _, tt := range tests {
+		if got := trackIDs(t, s, tt.f); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: %v, want %v", tt.f, got, tt.want)
+		}
+	}
+}
+
+func TestListTracks_Buildable(t *testing.T) {
+	s := newTestStore(t)
+	saveTracks(t, s,
+		straightTrack("short", 50),
+		&core.TrackProject{ID: "long", Name: "long", Pieces: []core.Piece{
+			{ID: 1, Type: "straight", Params: core.PieceParams{Length: 50}},
+			{ID: 2, Type: "straight", Params: core.PieceParams{Length: 50}},
+			{ID: 3, Type: "straight", Params: core.PieceParams{Length: 50}},
+		}},
+		loopTrack("loop", 50),
+		&core.TrackProject{ID: "blank", Name: "blank"},
+	)
+
+	if inv, err := s.GetInventory("u1"); err != nil || inv == nil || len(inv) != 0 {
+		t.Errorf("GetInventory before any = %v, %v, want empty", inv, err)
+	}
+	// Nothing is buildable from an empty inventory, not even a blank track
+	if got := trackIDs(t, s, TrackFilters{BuildableFor: "u1"}); len(got) != 0 {
+		t.Errorf("buildable from nothing: %v", got)
+	}
+
+	if err := s.SetInventory("u1", core.Inventory{"L50": 2, "R50-90": 4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetInventory("u2", core.Inventory{"L50": 10}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		f    TrackFilters
+		want []string
+	}{
+		{TrackFilters{BuildableFor: "u1"}, []string{"loop", "short"}},
+		{TrackFilters{BuildableFor: "u2"}, []string{"long", "short"}},
+		{TrackFilters{BuildableFor: "u1", Query: "loop"}, []string{"loop"}},
+		{TrackFilters{BuildableFor: "nobody"}, []string{}},
+		{TrackFilters{}, []string{"blank", "loop", "long", "short"}},
+	}
+	for _, tt := range tests {
+		if got := trackIDs(t, s, tt.f); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: %v, want %v", tt.f, got, tt.want)
+		}
+	}
+
+	// One curve short, then no straights at all
+	if err := s.SetInventoryItem("u1", "R50-90", 3); err != nil {
+		t.Fatal(err)
+	}
+	if got := trackIDs(t, s, TrackFilters{BuildableFor: "u1"}); !reflect.DeepEqual(got, []string{"short"}) {
+		t.Errorf("with 3 curves: %v", got)
+	}
+	if err := s.SetInventoryItem("u1", "L50", 0); err != nil {
+		t.Fatal(err)
+	}
+	if inv, err := s.GetInventory("u1"); err != nil || !reflect.DeepEqual(inv, core.Inventory{"R50-90": 3}) {
+		t.Errorf("GetInventory = %v, %v", inv, err)
+	}
+	if got := trackIDs(t, s, TrackFilters{BuildableFor: "u1"}); len(got) != 0 {
+		t.Errorf("without straights: %v", got)
+	}
+
+	// Replacing the inventory with an empty one clears it
+	if err := s.SetInventory("u2", core.Inventory{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := trackIDs(t, s, TrackFilters{BuildableFor: "u2"}); len(got) != 0 {
+		t.Errorf("after emptying: %v", got)
+	}
+}
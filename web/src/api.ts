@@ -1,4 +1,4 @@
-import { TrackProject, TrackMetadata, APIResponse, BOMSummary, User } from './types'
+import { TrackProject, TrackMetadata, APIResponse, BOMSummary, User, TrackRevision, TrackDiff, Catalog } from './types'
 
 const API_BASE = '/api'
 
@@ -16,18 +16,22 @@ export async function logout(): Promise<APIResponse> {
   return res.json()
 }
 
+// 元件目录（服务端为准，可在数据目录中自定义）
+export async function getCatalog(): Promise<APIResponse<Catalog>> {
+  const res = await fetch(`${API_BASE}/catalog`)
+  return res.json()
+}
+
 // 赛道相关
 export async function uploadTrack(
   project: TrackProject,
   name: string,
   description: string,
-  thumbnail: string,
   tags: string[] = []
 ): Promise<APIResponse> {
   const body = JSON.stringify({
     name,
     description,
-    thumbnail,
     project,
     tags,
   })
@@ -47,7 +51,13 @@ export async function listTracks(
   query = '',
   tags: string[] = [],
   minLength?: number,
-  maxLength?: number
+  maxLength?: number,
+  filters: {
+    minDifficulty?: number
+    maxDifficulty?: number
+    sort?: 'newest' | 'relevance' | 'difficulty' | '-difficulty' // relevance is the default with a query
+    buildable?: boolean // only tracks my inventory covers (signed in)
+  } = {}
 ): Promise<APIResponse<{
   items: TrackMetadata[]
   total: number
@@ -59,8 +69,12 @@ export async function listTracks(
   if (tags.length > 0) params.set('tags', tags.join(','))
   if (minLength !== undefined) params.set('minLength', String(minLength))
   if (maxLength !== undefined) params.set('maxLength', String(maxLength))
+  if (filters.minDifficulty !== undefined) params.set('minDifficulty', String(filters.minDifficulty))
+  if (filters.maxDifficulty !== undefined) params.set('maxDifficulty', String(filters.maxDifficulty))
+  if (filters.sort) params.set('sort', filters.sort)
+  if (filters.buildable) params.set('buildable', 'true')
 
-  const res = await fetch(`${API_BASE}/tracks?${params}`)
+  const res = await fetch(`${API_BASE}/tracks?${params}`, { credentials: 'include' })
   return res.json()
 }
 
@@ -81,3 +95,53 @@ export async function deleteTrack(id: string): Promise<APIResponse> {
   const res = await fetch(`${API_BASE}/tracks/${id}`, { method: 'DELETE' })
   return res.json()
 }
+
+// 版本历史
+export async function updateTrack(
+  id: string,
+  project: TrackProject,
+  message = ''
+): Promise<APIResponse<{ id: string; revision: number }>> {
+  const res = await fetch(`${API_BASE}/tracks/${id}`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    credentials: 'include',
+    body: JSON.stringify({ project, message }),
+  })
+  return res.json()
+}
+
+export async function listRevisions(id: string): Promise<APIResponse<TrackRevision[]>> {
+  const res = await fetch(`${API_BASE}/tracks/${id}/revisions`)
+  return res.json()
+}
+
+export async function getRevision(id: string, revision: number): Promise<APIResponse<{
+  revision: TrackRevision
+  project: TrackProject
+  bom: BOMSummary
+}>> {
+  const res = await fetch(`${API_BASE}/tracks/${id}/revisions/${revision}`)
+  return res.json()
+}
+
+export async function diffRevisions(id: string, from: number, to?: number): Promise<APIResponse<{
+  from: TrackRevision
+  to: TrackRevision
+  diff: TrackDiff
+}>> {
+  const params = new URLSearchParams({ from: String(from) })
+  if (to !== undefined) params.set('to', String(to))
+  const res = await fetch(`${API_BASE}/tracks/${id}/diff?${params}`)
+  return res.json()
+}
+
+export async function rollbackTrack(id: string, revision: number, message = ''): Promise<APIResponse<TrackRevision>> {
+  const res = await fetch(`${API_BASE}/tracks/${id}/revisions/${revision}/rollback`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    credentials: 'include',
+    body: JSON.stringify({ message }),
+  })
+  return res.json()
+}
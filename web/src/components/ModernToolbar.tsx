@@ -1,6 +1,6 @@
 import React, { useState } from 'react'
 import { useEditorStore } from '../store'
-import { STRAIGHT_PIECES, CURVE_PIECES, createPieceInstance, TrackPieceDefinition } from '../trackPieces'
+import { useCatalogPieces, createPieceInstance, TrackPieceDefinition } from '../trackPieces'
 import { Piece, Boundary } from '../types'
 import { ShareDialog } from './ShareDialog'
 import { TrackLibrary } from './TrackLibrary'
@@ -9,6 +9,7 @@ import { GitHubLogin } from './GitHubLogin'
 
 export function ModernToolbar() {
   const { project, addPiece, deletePieces, selectedIds, setBoundary, saveToLocalStorage } = useEditorStore()
+  const pieces = useCatalogPieces()
   const [quickInput, setQuickInput] = useState('')
   const [showBOM, setShowBOM] = useState(false)
   const [showBoundary, setShowBoundary] = useState(false)
@@ -171,7 +172,7 @@ export function ModernToolbar() {
             <div style={styles.subsection}>
               <div style={styles.subsectionTitle}>直道</div>
               <div style={styles.miniGrid}>
-                {STRAIGHT_PIECES.slice(0, 6).map((piece) => (
+                {pieces.straight.slice(0, 6).map((piece) => (
                   <button
                     key={piece.id}
                     onClick={() => handleAddPiece(piece)}
@@ -186,7 +187,7 @@ export function ModernToolbar() {
             <div style={styles.subsection}>
               <div style={styles.subsectionTitle}>弯道</div>
               <div style={styles.miniGrid}>
-                {CURVE_PIECES.slice(0, 6).map((piece) => (
+                {pieces.curve.slice(0, 6).map((piece) => (
                   <button
                     key={piece.id}
                     onClick={() => handleAddPiece(piece)}
@@ -34,11 +34,8 @@ export function ShareDialog({ onClose }: ShareDialogProps) {
     setError('')
 
     try {
-      // 生成缩略图
-      const thumbnail = await generateThumbnail()
-
-      // 上传（包含标签）
-      const result = await uploadTrack(project, name, description, thumbnail, tags)
+      // 上传（包含标签，缩略图由服务端生成）
+      const result = await uploadTrack(project, name, description, tags)
 
       if (result.success) {
         setSuccess(true)
@@ -55,58 +52,6 @@ export function ShareDialog({ onClose }: ShareDialogProps) {
     }
   }
 
-  const generateThumbnail = async (): Promise<string> => {
-    // 获取Canvas的SVG元素
-    const svgElement = document.querySelector('svg') as SVGSVGElement
-    if (!svgElement) return ''
-
-    try {
-      // 克隆SVG避免影响原始元素
-      const clonedSvg = svgElement.cloneNode(true) as SVGSVGElement
-
-      // 设置固定尺寸（缩略图）
-      const width = 300
-      const height = 200
-      clonedSvg.setAttribute('width', String(width))
-      clonedSvg.setAttribute('height', String(height))
-
-      // 序列化SVG
-      const svgData = new XMLSerializer().serializeToString(clonedSvg)
-      const svgBlob = new Blob([svgData], { type: 'image/svg+xml;charset=utf-8' })
-
-      // 转换为Canvas
-      return new Promise((resolve) => {
-        const img = new Image()
-        const url = URL.createObjectURL(svgBlob)
-
-        img.onload = () => {
-          const canvas = document.createElement('canvas')
-          canvas.width = width
-          canvas.height = height
-          const ctx = canvas.getContext('2d')
-          if (ctx) {
-            ctx.drawImage(img, 0, 0, width, height)
-            const base64 = canvas.toDataURL('image/png')
-            resolve(base64)
-          } else {
-            resolve('')
-          }
-          URL.revokeObjectURL(url)
-        }
-
-        img.onerror = () => {
-          resolve('')
-          URL.revokeObjectURL(url)
-        }
-
-        img.src = url
-      })
-    } catch (err) {
-      console.error('生成缩略图失败:', err)
-      return ''
-    }
-  }
-
   return (
     <div style={styles.overlay} onClick={onClose}>
       <div style={styles.dialog} onClick={(e) => e.stopPropagation()}>
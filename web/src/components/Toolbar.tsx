@@ -1,12 +1,13 @@
 import React, { useState } from 'react'
 import { useEditorStore } from '../store'
-import { STRAIGHT_PIECES, CURVE_PIECES, createPieceInstance, TrackPieceDefinition } from '../trackPieces'
+import { useCatalogPieces, createPieceInstance, TrackPieceDefinition } from '../trackPieces'
 import { Piece, Boundary } from '../types'
 import { ShareDialog } from './ShareDialog'
 import { TrackLibrary } from './TrackLibrary'
 
 export function Toolbar() {
   const { project, addPiece, deletePieces, selectedIds, setBoundary, saveToLocalStorage } = useEditorStore()
+  const pieces = useCatalogPieces()
   const [expandedSection, setExpandedSection] = useState<'straight' | 'curve' | null>('straight')
   const [quickInput, setQuickInput] = useState('')
   const [showBOM, setShowBOM] = useState(false)
@@ -136,11 +137,11 @@ export function Toolbar() {
             onClick={() => toggleSection('straight')}
             style={{...styles.categoryButton, ...(expandedSection === 'straight' ? styles.categoryButtonActive : {})}}
           >
-            📏 直道系列 ({STRAIGHT_PIECES.length})
+            📏 直道系列 ({pieces.straight.length})
           </button>
           {expandedSection === 'straight' && (
             <div style={styles.pieceGrid}>
-              {STRAIGHT_PIECES.map((piece) => (
+              {pieces.straight.map((piece) => (
                 <button
                   key={piece.id}
                   onClick={() => handleAddPiece(piece)}
@@ -160,11 +161,11 @@ export function Toolbar() {
             onClick={() => toggleSection('curve')}
             style={{...styles.categoryButton, ...(expandedSection === 'curve' ? styles.categoryButtonActive : {})}}
           >
-            🔄 弯道系列 ({CURVE_PIECES.length})
+            🔄 弯道系列 ({pieces.curve.length})
           </button>
           {expandedSection === 'curve' && (
             <div style={styles.pieceGrid}>
-              {CURVE_PIECES.map((piece) => (
+              {pieces.curve.map((piece) => (
                 <button
                   key={piece.id}
                   onClick={() => handleAddPiece(piece)}
@@ -226,7 +226,13 @@ export function TrackLibrary({ onClose }: TrackLibraryProps) {
                       </div>
                     )}
 
-                    {track.description && (
+                    {/* 搜索结果显示匹配片段（服务端已转义） */}
+                    {track.snippet ? (
+                      <p
+                        style={styles.description}
+                        dangerouslySetInnerHTML={{ __html: track.snippet }}
+                      />
+                    ) : track.description && (
                       <p style={styles.description}>{track.description}</p>
                     )}
 
@@ -1,8 +1,15 @@
 /**
  * 智能车竞赛标准赛道元件库
  * 符合  官方规格标准
+ *
+ * 元件以服务端目录（GET /api/catalog）为准，见 useCatalogPieces。
+ * 下面的内置列表只在目录加载完成前或服务端不可用时使用。
  */
 
+import { useEffect, useState } from 'react'
+import { getCatalog } from './api'
+import { Catalog } from './types'
+
 export interface TrackPieceDefinition {
   id: string
   name: string
@@ -16,7 +23,7 @@ export interface TrackPieceDefinition {
 }
 
 /**
- * 标准直道元件
+ * 内置直道元件
  * 命名规则：L{长度cm}
  */
 export const STRAIGHT_PIECES: TrackPieceDefinition[] = [
@@ -28,7 +35,7 @@ export const STRAIGHT_PIECES: TrackPieceDefinition[] = [
 ]
 
 /**
- * 标准弯道元件
+ * 内置弯道元件
  * 命名规则：R{半径cm}-{角度°}
  */
 export const CURVE_PIECES: TrackPieceDefinition[] = [
@@ -77,3 +84,61 @@ export function createPieceInstance(definition: TrackPieceDefinition, x: number
     rotation: 0,
   }
 }
+
+export interface CatalogPieces {
+  straight: TrackPieceDefinition[]
+  curve: TrackPieceDefinition[]
+}
+
+/**
+ * 把服务端目录转换成编辑器可放置的元件（画布目前只支持直道和弯道）
+ */
+export function piecesFromCatalog(catalog: Catalog): CatalogPieces {
+  const pieces: CatalogPieces = { straight: [], curve: [] }
+  for (const entry of catalog.pieces) {
+    const { length, radius, angle } = entry.params
+    if (entry.type === 'straight') {
+      pieces.straight.push({ id: entry.code, name: entry.name, type: 'straight', params: { length }, category: 'straight' })
+    } else if (entry.type === 'curve') {
+      pieces.curve.push({ id: entry.code, name: entry.name, type: 'curve', params: { radius, angle }, category: 'curve' })
+    }
+  }
+  return pieces
+}
+
+let catalogRequest: Promise<CatalogPieces | null> | null = null
+
+/**
+ * 加载一次服务端目录，失败时返回 null
+ */
+function loadCatalogPieces(): Promise<CatalogPieces | null> {
+  if (!catalogRequest) {
+    catalogRequest = getCatalog()
+      .then((res) => (res.success && res.data ? piecesFromCatalog(res.data) : null))
+      .catch(() => null)
+      .then((pieces) => {
+        if (!pieces) catalogRequest = null // 下次再试
+        return pieces
+      })
+  }
+  return catalogRequest
+}
+
+/**
+ * 元件库：服务端目录加载前先显示内置元件
+ */
+export function useCatalogPieces(): CatalogPieces {
+  const [pieces, setPieces] = useState<CatalogPieces>({ straight: STRAIGHT_PIECES, curve: CURVE_PIECES })
+
+  useEffect(() => {
+    let active = true
+    loadCatalogPieces().then((loaded) => {
+      if (active && loaded) setPieces(loaded)
+    })
+    return () => {
+      active = false
+    }
+  }, [])
+
+  return pieces
+}
@@ -40,11 +40,57 @@ export interface Piece {
   rotation: number
 }
 
+// 服务端元件目录（GET /api/catalog）
+export interface ElementType {
+  type: string
+  name: string
+  geometry: string
+  codeFormat: string
+  marking?: boolean
+}
+
+export interface CatalogEntry {
+  code: string
+  name: string
+  type: string
+  params: {
+    length?: number
+    radius?: number
+    angle?: number
+    height?: number
+    direction?: string
+  }
+  category: string
+}
+
+export interface Catalog {
+  types: ElementType[]
+  pieces: CatalogEntry[]
+}
+
 export interface BOMSummary {
   totalPieces: number
   totalLength: string
   bom: Record<string, number>
   details?: Piece[]
+  cost?: BOMCost
+}
+
+export interface CostLine {
+  code?: string
+  name: string
+  quantity: number
+  unit: 'pcs' | 'm'
+  unitPrice: number
+  total: number
+  priced: boolean
+}
+
+export interface BOMCost {
+  currency: string
+  lines: CostLine[]
+  total: number
+  unpriced?: string[]
 }
 
 export interface User {
@@ -67,6 +113,8 @@ export interface TrackMetadata {
   totalLength: string
   thumbnail?: string
   tags?: string[] // 赛道标签
+  difficulty?: number // 0..100, computed from the geometry
+  snippet?: string // search results: escaped HTML with the matches in <mark>
 }
 
 export interface APIResponse<T = any> {
@@ -74,3 +122,28 @@ export interface APIResponse<T = any> {
   data?: T
   error?: string
 }
+
+export interface TrackRevision {
+  trackId: string
+  revision: number
+  hash: string
+  authorId?: string
+  authorName?: string
+  message?: string
+  createdAt: string
+}
+
+export interface PieceChange {
+  id: string | number
+  before: Piece
+  after: Piece
+  fields: ('type' | 'params' | 'position' | 'rotation')[]
+}
+
+export interface TrackDiff {
+  added: Piece[]
+  removed: Piece[]
+  changed: PieceChange[]
+  unchanged: number
+  metadata: string[]
+}